@@ -0,0 +1,311 @@
+// Package ais decodes AIS messages from !AIVDM and !AIVDO sentences,
+// including multi-sentence reassembly and 6-bit payload decoding. Position
+// reports (types 1, 2, 3, 18, and 19) and static data (types 5, 19, and 24)
+// are decoded.
+package ais
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/ctberthiaume/cruisemic/nmea"
+)
+
+// Message is a decoded AIS message. Position and motion fields are NaN if not
+// available or not in the message type. Static data fields are empty or -1 if
+// not in the message type.
+type Message struct {
+	Type     int
+	MMSI     uint32
+	Own      bool    // from an AIVDO sentence, i.e. own vessel
+	Lat      float64 // decimal degrees
+	Lon      float64 // decimal degrees
+	SOG      float64 // speed over ground, knots
+	COG      float64 // course over ground, degrees true
+	Heading  float64 // true heading, degrees
+	Second   int     // UTC second of the position report, -1 if not available
+	Name     string  // vessel name, trailing padding removed
+	CallSign string
+	ShipType int
+}
+
+// HasPosition returns true if m has a valid position.
+func (m Message) HasPosition() bool {
+	return !math.IsNaN(m.Lat) && !math.IsNaN(m.Lon)
+}
+
+// HasStatic returns true if m has static data: name, call sign, or ship type.
+func (m Message) HasStatic() bool {
+	return m.Name != "" || m.CallSign != "" || m.ShipType >= 0
+}
+
+// fragment is one sentence of a possibly multi-sentence message.
+type fragment struct {
+	count   int
+	number  int
+	key     string // sequential message ID and channel
+	payload string
+	fill    int
+}
+
+// parseFragment splits the fields of an AIVDM or AIVDO sentence.
+func parseFragment(s nmea.Sentence) (f fragment, err error) {
+	if s.Type != "VDM" && s.Type != "VDO" {
+		return f, fmt.Errorf("not a VDM or VDO sentence: %q", s.Raw)
+	}
+	if len(s.Fields) != 6 {
+		return f, fmt.Errorf("bad %s field count %d", s.Type, len(s.Fields))
+	}
+	if f.count, err = strconv.Atoi(s.Fields[0]); err != nil || f.count < 1 || f.count > 9 {
+		return f, fmt.Errorf("bad fragment count %q", s.Fields[0])
+	}
+	if f.number, err = strconv.Atoi(s.Fields[1]); err != nil || f.number < 1 || f.number > f.count {
+		return f, fmt.Errorf("bad fragment number %q", s.Fields[1])
+	}
+	f.key = s.Talker + s.Type + "," + s.Fields[2] + "," + s.Fields[3]
+	f.payload = s.Fields[4]
+	if f.fill, err = strconv.Atoi(s.Fields[5]); err != nil || f.fill < 0 || f.fill > 5 {
+		return f, fmt.Errorf("bad fill bits %q", s.Fields[5])
+	}
+	return f, nil
+}
+
+// group is a partly received multi-sentence message.
+type group struct {
+	count   int
+	next    int // next expected fragment number
+	payload strings.Builder
+}
+
+// Assembler reassembles multi-sentence AIS messages and decodes them.
+// Fragments of a message must arrive in order. Fragments are matched by
+// talker, sentence type, sequential message ID, and channel.
+type Assembler struct {
+	pending map[string]*group
+}
+
+// NewAssembler returns a pointer to an Assembler struct.
+func NewAssembler() *Assembler {
+	return &Assembler{pending: make(map[string]*group)}
+}
+
+// Add adds an AIVDM or AIVDO sentence. It returns the decoded message and
+// true when the last sentence of a message is added. Out of order fragments
+// discard the partly received message and return an error. Unsupported
+// message types are not an error, the returned message has only Type and
+// MMSI set.
+func (a *Assembler) Add(s nmea.Sentence) (m Message, ok bool, err error) {
+	f, err := parseFragment(s)
+	if err != nil {
+		return m, false, err
+	}
+	if f.count == 1 {
+		delete(a.pending, f.key)
+		m, err = Decode(f.payload, f.fill)
+		m.Own = s.Type == "VDO"
+		return m, err == nil, err
+	}
+	g := a.pending[f.key]
+	if f.number == 1 {
+		g = &group{count: f.count, next: 1}
+		a.pending[f.key] = g
+	}
+	if g == nil || f.number != g.next || f.count != g.count {
+		delete(a.pending, f.key)
+		return m, false, fmt.Errorf("out of order fragment %d of %d", f.number, f.count)
+	}
+	g.payload.WriteString(f.payload)
+	g.next++
+	if f.number < f.count {
+		return m, false, nil
+	}
+	delete(a.pending, f.key)
+	m, err = Decode(g.payload.String(), f.fill)
+	m.Own = s.Type == "VDO"
+	return m, err == nil, err
+}
+
+// Decode decodes a complete 6-bit armored AIS payload. fill is the number of
+// fill bits at the end of the payload.
+func Decode(payload string, fill int) (m Message, err error) {
+	b, err := unarmor(payload, fill)
+	if err != nil {
+		return m, err
+	}
+	nan := math.NaN()
+	m = Message{Lat: nan, Lon: nan, SOG: nan, COG: nan, Heading: nan, Second: -1, ShipType: -1}
+	if len(b) < 38 {
+		return m, fmt.Errorf("short AIS message, %d bits", len(b))
+	}
+	m.Type = int(b.uint(0, 6))
+	m.MMSI = uint32(b.uint(8, 30))
+	switch m.Type {
+	case 1, 2, 3:
+		if err = b.need(m.Type, 143); err != nil {
+			return m, err
+		}
+		m.SOG = speed(b.uint(50, 10))
+		m.Lon, m.Lat = position(b, 61)
+		m.COG = course(b.uint(116, 12))
+		m.Heading = heading(b.uint(128, 9))
+		m.Second = second(b.uint(137, 6))
+	case 18, 19:
+		n := 139
+		if m.Type == 19 {
+			n = 271
+		}
+		if err = b.need(m.Type, n); err != nil {
+			return m, err
+		}
+		m.SOG = speed(b.uint(46, 10))
+		m.Lon, m.Lat = position(b, 57)
+		m.COG = course(b.uint(112, 12))
+		m.Heading = heading(b.uint(124, 9))
+		m.Second = second(b.uint(133, 6))
+		if m.Type == 19 {
+			m.Name = b.text(143, 20)
+			m.ShipType = int(b.uint(263, 8))
+		}
+	case 5:
+		if err = b.need(m.Type, 240); err != nil {
+			return m, err
+		}
+		m.CallSign = b.text(70, 7)
+		m.Name = b.text(112, 20)
+		m.ShipType = int(b.uint(232, 8))
+	case 24:
+		if err = b.need(m.Type, 40); err != nil {
+			return m, err
+		}
+		switch b.uint(38, 2) {
+		case 0:
+			if err = b.need(m.Type, 160); err != nil {
+				return m, err
+			}
+			m.Name = b.text(40, 20)
+		case 1:
+			if err = b.need(m.Type, 132); err != nil {
+				return m, err
+			}
+			m.ShipType = int(b.uint(40, 8))
+			m.CallSign = b.text(90, 7)
+		}
+	}
+	return m, nil
+}
+
+// bits is an unpacked AIS message with one bit per byte.
+type bits []byte
+
+// unarmor unpacks a 6-bit armored payload, dropping fill bits.
+func unarmor(payload string, fill int) (bits, error) {
+	b := make(bits, 0, len(payload)*6)
+	for i := 0; i < len(payload); i++ {
+		c := payload[i]
+		if c < '0' || c > 'w' || (c > 'W' && c < '`') {
+			return nil, fmt.Errorf("bad AIS payload character %q", c)
+		}
+		v := c - '0'
+		if v > 40 {
+			v -= 8
+		}
+		for j := 5; j >= 0; j-- {
+			b = append(b, (v>>uint(j))&1)
+		}
+	}
+	if fill > len(b) {
+		return nil, fmt.Errorf("bad AIS fill bits %d", fill)
+	}
+	return b[:len(b)-fill], nil
+}
+
+// need returns an error if b has fewer than n bits for message type typ.
+func (b bits) need(typ, n int) error {
+	if len(b) < n {
+		return fmt.Errorf("short AIS type %d message, %d bits", typ, len(b))
+	}
+	return nil
+}
+
+// uint returns n bits starting at start as an unsigned integer.
+func (b bits) uint(start, n int) uint64 {
+	var v uint64
+	for _, bit := range b[start : start+n] {
+		v = v<<1 | uint64(bit)
+	}
+	return v
+}
+
+// int returns n bits starting at start as a two's complement signed integer.
+func (b bits) int(start, n int) int64 {
+	v := int64(b.uint(start, n))
+	if b[start] == 1 {
+		v -= 1 << uint(n)
+	}
+	return v
+}
+
+// text returns n 6-bit characters starting at start, with trailing @ padding
+// and spaces removed.
+func (b bits) text(start, n int) string {
+	var s strings.Builder
+	for i := 0; i < n; i++ {
+		v := byte(b.uint(start+i*6, 6))
+		if v < 32 {
+			v += 64
+		}
+		s.WriteByte(v)
+	}
+	str := s.String()
+	if i := strings.IndexByte(str, '@'); i >= 0 {
+		str = str[:i]
+	}
+	return strings.TrimRight(str, " ")
+}
+
+// position returns longitude and latitude from 28 and 27 bit fields in
+// 1/10000 minutes starting at start, NaN if not available.
+func position(b bits, start int) (lon, lat float64) {
+	lon = float64(b.int(start, 28)) / 600000
+	lat = float64(b.int(start+28, 27)) / 600000
+	if lon < -180 || lon > 180 || lat < -90 || lat > 90 {
+		return math.NaN(), math.NaN()
+	}
+	return lon, lat
+}
+
+// speed returns speed over ground in knots from a 1/10 knot field, NaN if not
+// available.
+func speed(v uint64) float64 {
+	if v == 1023 {
+		return math.NaN()
+	}
+	return float64(v) / 10
+}
+
+// course returns course over ground in degrees from a 1/10 degree field, NaN
+// if not available.
+func course(v uint64) float64 {
+	if v >= 3600 {
+		return math.NaN()
+	}
+	return float64(v) / 10
+}
+
+// heading returns true heading in degrees, NaN if not available.
+func heading(v uint64) float64 {
+	if v >= 360 {
+		return math.NaN()
+	}
+	return float64(v)
+}
+
+// second returns the UTC second of a position report, -1 if not available.
+func second(v uint64) int {
+	if v >= 60 {
+		return -1
+	}
+	return int(v)
+}
@@ -0,0 +1,193 @@
+package ais
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/ctberthiaume/cruisemic/nmea"
+	"github.com/stretchr/testify/assert"
+)
+
+// armor encodes b as a 6-bit armored payload with fill bits.
+func armor(b bits) (payload string, fill int) {
+	for len(b)%6 != 0 {
+		b = append(b, 0)
+		fill++
+	}
+	var s strings.Builder
+	for i := 0; i < len(b); i += 6 {
+		v := byte(b.uint(i, 6)) + '0'
+		if v > 'W' {
+			v += 8
+		}
+		s.WriteByte(v)
+	}
+	return s.String(), fill
+}
+
+// set sets n bits starting at start to v.
+func (b bits) set(start, n int, v uint64) {
+	for i := 0; i < n; i++ {
+		b[start+i] = byte(v>>uint(n-1-i)) & 1
+	}
+}
+
+// setText sets n 6-bit characters starting at start to s, padded with @.
+func (b bits) setText(start, n int, s string) {
+	for i := 0; i < n; i++ {
+		var v byte // @
+		if i < len(s) {
+			v = s[i]
+			if v >= 64 {
+				v -= 64
+			}
+		}
+		b.set(start+i*6, 6, uint64(v))
+	}
+}
+
+func addLines(a *Assembler, lines ...string) (m Message, ok bool, err error) {
+	for _, line := range lines {
+		s, _ := nmea.Parse(line)
+		if m, ok, err = a.Add(s); err != nil || ok {
+			return
+		}
+	}
+	return
+}
+
+func TestPositionReports(t *testing.T) {
+	assert := assert.New(t)
+	a := NewAssembler()
+
+	m, ok, err := addLines(a, "!AIVDM,1,1,,B,177KQJ5000G?tO`K>RA1wUbN0TKH,0*5C")
+	assert.Nil(err)
+	assert.True(ok)
+	assert.Equal(1, m.Type)
+	assert.Equal(uint32(477553000), m.MMSI)
+	assert.False(m.Own)
+	assert.True(m.HasPosition())
+	assert.InDelta(47.582833, m.Lat, 1e-6)
+	assert.InDelta(-122.345833, m.Lon, 1e-6)
+	assert.Equal(0.0, m.SOG)
+	assert.Equal(51.0, m.COG)
+	assert.Equal(181.0, m.Heading)
+	assert.Equal(15, m.Second)
+	assert.False(m.HasStatic())
+
+	// Class B, heading not available
+	m, ok, err = addLines(a, "!AIVDO,1,1,,A,B52K>;h00Fc>jpUlNV@ikwpUoP06,0*4E")
+	assert.Nil(err)
+	assert.True(ok)
+	assert.Equal(18, m.Type)
+	assert.True(m.Own)
+	assert.Equal(uint32(338087471), m.MMSI)
+	assert.InDelta(40.684540, m.Lat, 1e-6)
+	assert.InDelta(-74.072132, m.Lon, 1e-6)
+	assert.Equal(0.1, m.SOG)
+	assert.Equal(79.6, m.COG)
+	assert.True(math.IsNaN(m.Heading))
+	assert.Equal(49, m.Second)
+}
+
+func TestStaticData(t *testing.T) {
+	assert := assert.New(t)
+	a := NewAssembler()
+
+	m, ok, err := addLines(a,
+		"!AIVDM,2,1,1,A,55?MbV02;H;s<HtKR20EHE:0@T4@Dn2222222216L961O5Gf0NSQEp6ClRp8,0*1C",
+		"!AIVDM,2,2,1,A,88888888880,2*25",
+	)
+	assert.Nil(err)
+	assert.True(ok)
+	assert.Equal(5, m.Type)
+	assert.Equal(uint32(351759000), m.MMSI)
+	assert.Equal("EVER DIADEM", m.Name)
+	assert.Equal("3FOF8", m.CallSign)
+	assert.Equal(70, m.ShipType)
+	assert.False(m.HasPosition())
+
+	// Class B static data part A and part B
+	b := make(bits, 168)
+	b.set(0, 6, 24)
+	b.set(8, 30, 367000001)
+	b.setText(40, 20, "KILO MOANA")
+	payload, fill := armor(b)
+	m, err = Decode(payload, fill)
+	assert.Nil(err)
+	assert.Equal("KILO MOANA", m.Name)
+	assert.Equal(-1, m.ShipType)
+	b.set(38, 2, 1)
+	b.set(40, 8, 52)
+	b.setText(90, 7, "WDK2")
+	payload, fill = armor(b)
+	m, err = Decode(payload, fill)
+	assert.Nil(err)
+	assert.Equal("", m.Name)
+	assert.Equal(52, m.ShipType)
+	assert.Equal("WDK2", m.CallSign)
+
+	// Extended class B with position and name
+	b = make(bits, 312)
+	b.set(0, 6, 19)
+	b.set(8, 30, 367000002)
+	b.set(46, 10, 123)
+	b.set(57, 28, uint64(int64(-157*600000)&(1<<28-1)))
+	b.set(85, 27, 21*600000)
+	b.set(112, 12, 3600)
+	b.set(124, 9, 90)
+	b.set(133, 6, 61)
+	b.setText(143, 20, "TARA")
+	payload, fill = armor(b)
+	m, err = Decode(payload, fill)
+	assert.Nil(err)
+	assert.Equal(12.3, m.SOG)
+	assert.Equal(-157.0, m.Lon)
+	assert.Equal(21.0, m.Lat)
+	assert.True(math.IsNaN(m.COG))
+	assert.Equal(90.0, m.Heading)
+	assert.Equal(-1, m.Second)
+	assert.Equal("TARA", m.Name)
+}
+
+func TestAssembler(t *testing.T) {
+	assert := assert.New(t)
+	a := NewAssembler()
+	first := "!AIVDM,2,1,1,A,55?MbV02;H;s<HtKR20EHE:0@T4@Dn2222222216L961O5Gf0NSQEp6ClRp8,0*1C"
+	second := "!AIVDM,2,2,1,A,88888888880,2*25"
+
+	// Second fragment without first
+	_, ok, err := addLines(a, second)
+	assert.False(ok)
+	assert.NotNil(err)
+
+	// Interleaved messages on another channel
+	_, ok, err = addLines(a, first, "!AIVDM,1,1,,B,177KQJ5000G?tO`K>RA1wUbN0TKH,0*5C")
+	assert.True(ok)
+	assert.Nil(err)
+	m, ok, err := addLines(a, second)
+	assert.True(ok)
+	assert.Nil(err)
+	assert.Equal("EVER DIADEM", m.Name)
+
+	// First fragment repeated restarts the message
+	m, ok, err = addLines(a, first, first, second)
+	assert.True(ok)
+	assert.Nil(err)
+	assert.Equal(5, m.Type)
+
+	for _, bad := range []string{
+		"!AIVDM,1,1,,B,177KQJ5000G?tO`K>RA1wUbN0TKH*5C",
+		"!AIVDM,x,1,,B,177KQJ5000G?tO`K>RA1wUbN0TKH,0*5C",
+		"!AIVDM,1,2,,B,177KQJ5000G?tO`K>RA1wUbN0TKH,0*5C",
+		"!AIVDM,1,1,,B,177KQJ5000G?tO`K>RA1wUbN0TKH,6*5C",
+		"!AIVDM,1,1,,B,177KQJ5000G?tO`K>RA1wUbN0TK~,0*5C",
+		"!AIVDM,1,1,,B,177KQJ5000,0*5C",
+		"$GPHDT,123.4,T*00",
+	} {
+		_, ok, err = addLines(a, bad)
+		assert.False(ok, bad)
+		assert.NotNil(err, bad)
+	}
+}
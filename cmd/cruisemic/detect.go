@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/ctberthiaume/cruisemic/parse"
+)
+
+// detectCommand implements "cruisemic detect", which prints parsers ranked by
+// how well they parse lines from STDIN.
+func detectCommand(args []string) int {
+	fs := flag.NewFlagSet("detect", flag.ExitOnError)
+	linesFlag := fs.Int("lines", 0, "Number of input lines to sample, 0 for all")
+	noClean := fs.Bool("noclean", false, "Don't filter for whitelisted ASCII characters: Space to ~, TAB, LF, CR")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: cruisemic detect [options] < sample.txt\n")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	var r io.Reader = bufio.NewReader(os.Stdin)
+	if *linesFlag > 0 {
+		sample, _, err := readLines(r, *linesFlag)
+		if err != nil {
+			log.Printf("error: %v\n", err)
+			return 1
+		}
+		r = bytes.NewReader(sample)
+	}
+	name, candidates, err := parse.DetectParser(r, *noClean)
+	for _, c := range candidates {
+		fmt.Println(c)
+	}
+	if err != nil {
+		log.Printf("error: %v\n", err)
+		return 1
+	}
+	log.Printf("best parser: %s", name)
+	return 0
+}
+
+// readLines reads up to n lines from r. It returns the lines read and
+// whether the end of r was reached.
+func readLines(r io.Reader, n int) (sample []byte, eof bool, err error) {
+	br := bufio.NewReader(r)
+	for i := 0; i < n; i++ {
+		b, err := br.ReadBytes('\n')
+		sample = append(sample, b...)
+		if err == io.EOF {
+			return sample, true, nil
+		}
+		if err != nil {
+			return sample, false, err
+		}
+	}
+	return sample, false, nil
+}
+
+// detectReader samples lines from r for parser detection for up to
+// -detectlines lines or -detecttime. It returns the chosen parser name and a
+// reader that replays the sample followed by the rest of r.
+func detectReader(r io.Reader) (string, io.Reader, error) {
+	type chunk struct {
+		b   []byte
+		err error
+	}
+	chunks := make(chan chunk)
+	go func() {
+		defer close(chunks)
+		br := bufio.NewReader(r)
+		for {
+			b, err := br.ReadBytes('\n')
+			if len(b) > 0 || err != nil {
+				chunks <- chunk{b, err}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	var sample []byte
+	var readErr error
+	lines := 0
+	timer := time.NewTimer(*detectTimeFlag)
+	defer timer.Stop()
+sampling:
+	for lines < *detectLinesFlag {
+		select {
+		case c, ok := <-chunks:
+			if !ok {
+				break sampling
+			}
+			sample = append(sample, c.b...)
+			lines++
+			if c.err != nil {
+				readErr = c.err
+				break sampling
+			}
+		case <-timer.C:
+			break sampling
+		}
+	}
+
+	name, err := detect(sample)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Replay the sample, then pass along the rest of the input
+	pr, pw := io.Pipe()
+	go func() {
+		if readErr != nil {
+			pw.CloseWithError(readErr)
+			return
+		}
+		for c := range chunks {
+			if _, err := pw.Write(c.b); err != nil {
+				return
+			}
+			if c.err != nil {
+				pw.CloseWithError(c.err)
+				return
+			}
+		}
+		pw.Close()
+	}()
+	return name, io.MultiReader(bytes.NewReader(sample), pr), nil
+}
+
+// detectDatagrams samples UDP datagrams for parser detection for up to
+// -detectlines lines or -detecttime. If no parser matches, the sample is
+// discarded and sampling starts over. It returns the chosen parser name, the
+// sampled datagrams, and the datagrams discarded by failed detections, which
+// are only kept for -raw storage.
+func detectDatagrams(dataChan <-chan datagram) (string, []datagram, []datagram) {
+	closed := false
+	var discarded []datagram
+	for {
+		var buffered []datagram
+		var sample []byte
+		var reassembler *parse.Reassembler
+		if *reassembleFlag > 0 {
+			reassembler = parse.NewReassembler(*reassembleFlag)
+		}
+		timer := time.NewTimer(*detectTimeFlag)
+	sampling:
+		for bytes.Count(sample, []byte{'\n'}) < *detectLinesFlag {
+			select {
+			case dg, ok := <-dataChan:
+				if !ok {
+					closed = true
+					break sampling
+				}
+				buffered = append(buffered, dg)
+				if reassembler != nil {
+					lines, _ := reassembler.Add(dg.source, dg.data, dg.t)
+					sample = append(sample, lines...)
+				} else {
+					// Each datagram is parsed as its own line, see
+					// ParseLinesRecv, so end unterminated datagrams
+					// with a newline.
+					sample = append(sample, dg.data...)
+					if len(dg.data) > 0 && dg.data[len(dg.data)-1] != '\n' {
+						sample = append(sample, '\n')
+					}
+				}
+			case <-timer.C:
+				break sampling
+			}
+		}
+		timer.Stop()
+
+		name, err := detect(sample)
+		if err == nil {
+			return name, buffered, discarded
+		}
+		if closed {
+			log.Fatalf("error: %v\n", err)
+		}
+		log.Printf("error: %v, discarding %d datagrams and sampling again", err, len(buffered))
+		discarded = append(discarded, buffered...)
+	}
+}
+
+// detect chooses a parser for sample and logs the decision.
+func detect(sample []byte) (string, error) {
+	name, candidates, err := parse.DetectParser(bytes.NewReader(sample), *noCleanFlag)
+	for _, c := range candidates {
+		log.Printf("parser candidate: %v", c)
+	}
+	if err != nil {
+		return "", fmt.Errorf("parser detection failed: %w", err)
+	}
+	log.Printf("detected parser %s", name)
+	return name, nil
+}
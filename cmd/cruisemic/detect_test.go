@@ -0,0 +1,45 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectDatagramsUnterminated(t *testing.T) {
+	assert := assert.New(t)
+	*detectLinesFlag = 3
+	*detectTimeFlag = time.Minute
+	*reassembleFlag = 0
+
+	// TN448 datagrams have no trailing newline
+	lines := []string{
+		"$SEAFLOW::$GNZDA,192824.00,08,01,2026,00,00*73::$GNGGA,192824.00,0959.090566,N,13112.849121,E,5,18,0.62,72.764,M,0.000,M,75,0000*43:: 29.6849,  5.64749,  33.9515, 1543.859::-0.005",
+		"$SEAFLOW::$GNZDA,192844.00,08,01,2026,00,00*75::$GNGGA,192844.00,0959.129627,N,13112.900471,E,5,18,0.62,71.503,M,0.000,M,35,0000*48:: 29.6866,  5.64754,  33.9507, 1543.861::-0.002",
+		"$SEAFLOW::$GNZDA,192845.00,08,01,2026,00,00*74::$GNGGA,192845.00,0959.131607,N,13112.903197,E,5,18,0.62,72.097,M,0.000,M,36,0000*44:: 29.6866,  5.64757,  33.9509, 1543.862::-0.001",
+	}
+	dataChan := make(chan datagram, len(lines))
+	for _, l := range lines {
+		dataChan <- datagram{data: []byte(l), source: "10.0.0.1:5000", t: time.Now()}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		name, buffered, discarded := detectDatagrams(dataChan)
+		assert.Equal("TN448", name)
+		assert.Len(buffered, len(lines))
+		assert.Len(discarded, 0)
+		for i, dg := range buffered {
+			// Datagrams are passed on unchanged
+			assert.False(strings.HasSuffix(string(dg.data), "\n"), i)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("detection didn't stop after -detectlines datagrams")
+	}
+}
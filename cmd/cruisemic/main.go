@@ -28,18 +28,72 @@ var rawFlag = flag.Bool("raw", false, "Save raw, unparsed, but possibly cleaned,
 var dirFlag = flag.String("dir", "", "Append received data to files in this directory (required)")
 var copyDirFlag = flag.String("copy", "", "Periodically (1m) copy parsed data to this directory")
 var intervalFlag = flag.Duration("interval", 0, "Per-feed throttling interval as duration parsed by time.ParseDuration, e.g. 300ms, 1s, 1m")
-var parserFlag = flag.String("parser", "", "Parser to use, use -choices to see valid choices (required)")
+var parserFlag = flag.String("parser", "", "Parser to use, use -choices to see valid choices, file:<path> for a YAML/JSON feed definition, or auto to detect from input (required)")
+var detectLinesFlag = flag.Int("detectlines", 100, "With -parser auto, number of input lines to sample before choosing a parser")
+var detectTimeFlag = flag.Duration("detecttime", time.Minute, "With -parser auto, maximum time to sample input before choosing a parser")
+var checksumFlag = flag.String("checksum", "flag", "NMEA checksum policy: reject drops bad sentences, flag keeps them and logs an error, ignore skips verification")
+var optionFlags stringList
+var flagsFlag = flag.Bool("flags", false, "Write a QARTOD quality flag column after each data column, and keep records with bad or suspect values")
+var gpsFixFlag = flag.Bool("gpsfix", false, "Write GPS fix columns: fix_quality, satellites, hdop, and altitude from GGA, or fix_status from RMC")
+var fixPolicyFlag = flag.String("fixpolicy", "keep", "GPS fix policy for GGA fix quality 0, RMC status V, or HDOP above -maxhdop: keep doesn't check, flag keeps records and flags lat and lon, drop drops the position")
+var maxHDOPFlag = flag.Float64("maxhdop", 0, "With -fixpolicy flag or drop, GGA HDOP above which a fix is poor. 0 disables HDOP checks")
+var attitudeFlag = flag.Bool("attitude", false, "Write heading, pitch, roll, and rate_of_turn columns from HDT, HDG, PASHR, and ROT sentences in the feed")
+var depthFlag = flag.Bool("depth", false, "Write a depth column from DBT, DPT, Kongsberg PSKPDPT, and Knudsen PKEL99 echosounder sentences in the feed")
+var depthOffsetFlag = flag.Float64("depthoffset", 0, "With -depth, transducer depth below the waterline in meters, added to echosounder depths")
+var windFlag = flag.Bool("wind", false, "Write relative and true wind columns from MWV sentences in the feed")
+var aisFlag = flag.Bool("ais", false, "Decode !AIVDM and !AIVDO sentences in the feed into an ais feed of nearby vessel positions and names")
+var recvTimeFlag = flag.Bool("recvtime", false, "Write a recv_time column after time with the host clock time each record's last line was received")
+var clockWarnFlag = flag.Duration("clockwarn", 5*time.Second, "With -udp, log a warning when the host clock differs from feed GPS time by more than this duration. 0 disables warnings")
+var clockFeedFlag = flag.Bool("clockfeed", false, "With -udp, write a clock feed with the host clock offset from feed GPS time")
 var choicesFlag = flag.Bool("choices", false, "Print Parser choices and exit")
 var udpFlag = flag.Bool("udp", false, "Read from UDP, not STDIN")
 var hostFlag = flag.String("host", "0.0.0.0", "Interface IP to bind to for UDP")
 var portFlag = flag.String("port", "1234", "Comma-separated list of UDP ports to bind to")
 var bufferFlag = flag.Uint("buffer", 1500, "Max UDP receive buffer size")
+var reassembleFlag = flag.Duration("reassemble", 0, "Reassemble lines split across UDP datagrams from the same sender, discarding fragments older than this duration, e.g. 2s. 0 disables reassembly")
 var quietFlag = flag.Bool("quiet", false, "Suppress UDP informational status on stderr")
 var versionFlag = flag.Bool("version", false, "Print version and exit")
 var flushFlag = flag.Bool("flush", false, "Flush data to disk after every parsed feed line")
 var wrappedFlag = flag.Bool("wrapped", false, "STDIN UDP stream payloads are wrapped with RAWUDP headers")
 
+// datagram is a UDP payload with its sender address and receive time.
+type datagram struct {
+	data   []byte
+	source string
+	t      time.Time
+}
+
+// recvClock is a rawudp.TimeSource that returns a datagram receive time.
+type recvClock time.Time
+
+// Now returns the receive time.
+func (c recvClock) Now() time.Time {
+	return time.Time(c)
+}
+
+// stringList is a flag.Value for repeatable string flags.
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "detect":
+			os.Exit(detectCommand(os.Args[2:]))
+		case "selftest":
+			os.Exit(selftestCommand())
+		}
+	}
+
+	flag.Var(&optionFlags, "option", "Parser specific key=value option, may be repeated")
 	flag.Parse()
 
 	if *versionFlag {
@@ -47,7 +101,9 @@ func main() {
 		os.Exit(0)
 	}
 	if *choicesFlag {
-		fmt.Printf("Choices for -parser option are:\n%v\n", parse.RegistryChoices())
+		fmt.Printf("Choices for -parser option are:\n\n%v\n\n", parse.RegistryChoices())
+		fmt.Printf("or file:<path> to use a YAML or JSON feed definition\n")
+		fmt.Printf("or auto to detect the parser from input\n")
 		os.Exit(0)
 	}
 	if *nameFlag == "" {
@@ -59,19 +115,135 @@ func main() {
 	if *wrappedFlag && *udpFlag {
 		log.Fatalln("-wrapped and -udp cannot both be set")
 	}
+	if *clockFeedFlag && !*udpFlag {
+		log.Fatalln("-clockfeed requires -udp")
+	}
 
-	parserFact, ok := parse.ParserRegistry[*parserFlag]
-	if !ok {
-		log.Fatalln("-parser must be one of the choices listed by -choices")
+	checksumPolicy, err := parse.ParseChecksumPolicy(*checksumFlag)
+	if err != nil {
+		log.Fatalf("error: %v\n", err)
+	}
+	fixPolicy, err := parse.ParseFixPolicy(*fixPolicyFlag)
+	if err != nil {
+		log.Fatalf("error: %v\n", err)
+	}
+	opts, err := parse.ParseOptions(optionFlags)
+	if err != nil {
+		log.Fatalf("error: %v\n", err)
+	}
+
+	// Start reading input before creating the parser so that -parser auto can
+	// sample it.
+	var r io.Reader
+	lineTime := time.Now // receive time of each line read from r
+	var dataChan chan datagram
+	var wg sync.WaitGroup
+	if *udpFlag {
+		log.Printf("Starting cruisemic, listening at %v on ports %v", *hostFlag, *portFlag)
+		dataChan = listenUDP(strings.Split(*portFlag, ","), &wg)
+	} else if *wrappedFlag {
+		// Read from STDIN with RAWUDP-wrapped payloads, received when their
+		// headers say
+		rr := rawudp.NewRawUDPReader(bufio.NewReader(os.Stdin))
+		rr.RequireTime = *recvTimeFlag
+		r = rr
+		lineTime = rr.LineTime
+	} else {
+		r = bufio.NewReader(os.Stdin)
+	}
+
+	parserName := *parserFlag
+	var buffered []datagram  // datagrams read during parser detection
+	var discarded []datagram // datagrams from failed detections, for -raw
+	if parserName == parse.AutoParser {
+		if *udpFlag {
+			parserName, buffered, discarded = detectDatagrams(dataChan)
+		} else {
+			parserName, r, err = detectReader(r)
+			if err != nil {
+				log.Fatalf("error: %v\n", err)
+			}
+		}
+	}
+
+	parser, err := newParser(parserName)
+	if err != nil {
+		log.Fatalf("error: %v\n", err)
+	}
+	if cp, ok := parser.(parse.ChecksumPolicySetter); ok {
+		cp.SetChecksumPolicy(checksumPolicy)
+	}
+	if cp, ok := parser.(parse.Configurable); ok {
+		if err := cp.Configure(opts); err != nil {
+			log.Fatalf("error: %v\n", err)
+		}
+	} else if len(opts) > 0 {
+		log.Fatalf("error: parser %q does not accept -option\n", parserName)
+	}
+	if *flagsFlag {
+		fs, ok := parser.(parse.FlagColumnsSetter)
+		if !ok {
+			log.Fatalf("error: parser %q does not support -flags\n", parserName)
+		}
+		fs.SetFlagColumns(true)
+	}
+	if *gpsFixFlag || fixPolicy != parse.FixKeep {
+		gs, ok := parser.(parse.GPSFixSetter)
+		if !ok {
+			log.Fatalf("error: parser %q does not support -gpsfix or -fixpolicy\n", parserName)
+		}
+		gs.SetGPSFix(*gpsFixFlag, fixPolicy, *maxHDOPFlag)
+	}
+	if *attitudeFlag {
+		as, ok := parser.(parse.AttitudeSetter)
+		if !ok {
+			log.Fatalf("error: parser %q does not support -attitude\n", parserName)
+		}
+		as.SetAttitude(true)
+	}
+	if *depthFlag {
+		ds, ok := parser.(parse.DepthSetter)
+		if !ok {
+			log.Fatalf("error: parser %q does not support -depth\n", parserName)
+		}
+		ds.SetDepth(true, *depthOffsetFlag)
+	}
+	if *windFlag {
+		ws, ok := parser.(parse.WindSetter)
+		if !ok {
+			log.Fatalf("error: parser %q does not support -wind\n", parserName)
+		}
+		ws.SetWind(true)
+	}
+	if *aisFlag {
+		parser = parse.NewAISMonitor(parser, *nameFlag, *intervalFlag, checksumPolicy)
 	}
-	parser := parserFact(*nameFlag, *intervalFlag, time.Now)
 	outPrefix := *nameFlag + "-"
 	outSuffix := ".tab"
 
 	// Set header for parsed underway data file and raw data file. If not UDP,
 	// then don't write raw data, assuming we are already reading a raw data
 	// file.
-	feedHeaders := map[string]string{parse.UnderwayName: parser.Header()}
+	var recvTime time.Time // receive time of the UDP datagram being parsed
+	if *udpFlag && (*clockWarnFlag > 0 || *clockFeedFlag) {
+		if parse.UsesHostClock(parser) {
+			if *clockFeedFlag {
+				log.Fatalf("error: parser %q timestamps records with the host clock, -clockfeed is not supported\n", parserName)
+			}
+		} else {
+			// Compare GPS time to when the datagram arrived, not when it's
+			// parsed, which may be much later for datagrams buffered during
+			// parser detection.
+			parser = parse.NewClockMonitor(parser, *nameFlag, *intervalFlag, *clockWarnFlag, *clockFeedFlag, func() time.Time { return recvTime })
+		}
+	}
+	feedHeaders := parse.FeedHeaders(parser)
+	if *recvTimeFlag {
+		feedHeaders, err = parse.RecvTimeHeaders(feedHeaders)
+		if err != nil {
+			log.Fatalf("error: %v\n", err)
+		}
+	}
 	if *rawFlag && *udpFlag {
 		feedHeaders[parse.RawName] = ""
 	}
@@ -104,64 +276,48 @@ func main() {
 	log.Printf("Writing to %q", *dirFlag)
 	exitcode := 0
 	if *udpFlag {
-		log.Printf("Starting cruisemic, listening at %v on ports %v", *hostFlag, *portFlag)
-
-		ports := strings.Split(*portFlag, ",")
-		dataChan := make(chan []byte)
-		var wg sync.WaitGroup
-
-		// Read from UDP ports, write to channel
-		for _, p := range ports {
-			wg.Add(1)
-			go func(port string) {
-				defer wg.Done()
-				addr, err := net.ResolveUDPAddr("udp", fmt.Sprintf("%v:%s", *hostFlag, port))
-				if err != nil {
-					log.Printf("Error resolving UDP address for port %v: %v", port, err)
-					return
+		// Process data from channel
+		var reassembler *parse.Reassembler
+		if *reassembleFlag > 0 {
+			reassembler = parse.NewReassembler(*reassembleFlag)
+		}
+		go func() {
+			// Write UDP payload wrapped with RAWUDP header. The header has the
+			// receive time, since datagrams from detection are written late.
+			writeRaw := func(dg datagram) {
+				wrapped := rawudp.WrapUDPPayload(recvClock(dg.t), dg.data)
+				if err := storer.WriteString(parse.RawName, string(wrapped)); err != nil {
+					log.Printf("error writing raw UDP: %v", err)
 				}
-
-				l, err := net.ListenUDP("udp", addr)
-				if err != nil {
-					log.Printf("Error listening on port %s: %v", port, err)
-					return
+			}
+			if *rawFlag {
+				for _, dg := range discarded {
+					writeRaw(dg)
 				}
-				defer l.Close()
-
-				if !*quietFlag {
-					log.Printf("Listening on UDP port %s", port)
+			}
+			for dg := range replay(buffered, dataChan) {
+				data := dg.data
+				if *rawFlag {
+					writeRaw(dg)
 				}
-
-				b := make([]byte, *bufferFlag)
-				for {
-					n, addr, err := l.ReadFromUDP(b)
-					if err != nil {
-						log.Printf("read from UDP port %s failed, err: %v", port, err)
-						break
+				if reassembler != nil {
+					// Only pass complete lines to the parser
+					var discarded [][]byte
+					data, discarded = reassembler.Add(dg.source, data, dg.t)
+					for _, frag := range discarded {
+						log.Printf("discarded stale fragment: %q", frag)
 					}
-					if !*quietFlag {
-						log.Printf("Read from client(%v:%v) on port %s, len: %v\n", addr.IP, addr.Port, port, n)
+					if len(data) == 0 {
+						continue
 					}
-					// Copy data to avoid race conditions on buffer 'b'
-					data := make([]byte, n)
-					copy(data, b[:n])
-					dataChan <- data
 				}
-			}(strings.TrimSpace(p))
-		}
 
-		// Process data from channel
-		go func() {
-			for data := range dataChan {
-				if *rawFlag {
-					// Write UDP payload wrapped with RAWUDP header
-					wrapped := rawudp.WrapUDPPayload(rawudp.RealTime{}, data)
-					if err := storer.WriteString(parse.RawName, string(wrapped)); err != nil {
-						log.Printf("error writing raw UDP: %v", err)
-					}
+				recvTime = dg.t
+				var recv func() time.Time
+				if *recvTimeFlag {
+					recv = func() time.Time { return recvTime }
 				}
-
-				err = parse.ParseLines(parser, strings.NewReader(string(data)), storer, *flushFlag, *noCleanFlag)
+				err = parse.ParseLinesRecv(parser, strings.NewReader(string(data)), storer, *flushFlag, *noCleanFlag, recv)
 				if err != nil {
 					log.Println(err)
 					exitcode = 1
@@ -207,15 +363,11 @@ func main() {
 		wg.Wait()
 		close(dataChan)
 	} else {
-		var r io.Reader
-		if *wrappedFlag {
-			// Read from STDIN with RAWUDP-wrapped payloads
-			r = rawudp.NewRawUDPReader(bufio.NewReader(os.Stdin))
-		} else {
-			r = bufio.NewReader(os.Stdin)
+		var recv func() time.Time
+		if *recvTimeFlag {
+			recv = lineTime
 		}
-
-		err := parse.ParseLines(parser, r, storer, *flushFlag, *noCleanFlag)
+		err := parse.ParseLinesRecv(parser, r, storer, *flushFlag, *noCleanFlag, recv)
 		if err != nil {
 			log.Println(err)
 			exitcode = 1
@@ -231,3 +383,91 @@ func main() {
 	mut.Unlock()
 	os.Exit(exitcode)
 }
+
+// newParser returns the registered parser name, or a feed definition parser
+// for file:<path>.
+func newParser(name string) (parse.Parser, error) {
+	if strings.HasPrefix(name, parse.DefinitionPrefix) {
+		path := strings.TrimPrefix(name, parse.DefinitionPrefix)
+		return parse.NewDefinitionParserFromFile(path, *nameFlag, *intervalFlag, time.Now)
+	}
+	parserFact, ok := parse.ParserRegistry[name]
+	if !ok {
+		return nil, fmt.Errorf("-parser must be one of the choices listed by -choices, file:<path>, or auto")
+	}
+	return parserFact(*nameFlag, *intervalFlag, time.Now), nil
+}
+
+// listenUDP reads from UDP ports and writes to the returned channel. wg is
+// done when all readers have stopped.
+func listenUDP(ports []string, wg *sync.WaitGroup) chan datagram {
+	dataChan := make(chan datagram)
+	for _, p := range ports {
+		wg.Add(1)
+		go func(port string) {
+			defer wg.Done()
+			addr, err := net.ResolveUDPAddr("udp", fmt.Sprintf("%v:%s", *hostFlag, port))
+			if err != nil {
+				log.Printf("Error resolving UDP address for port %v: %v", port, err)
+				return
+			}
+
+			l, err := net.ListenUDP("udp", addr)
+			if err != nil {
+				log.Printf("Error listening on port %s: %v", port, err)
+				return
+			}
+			defer l.Close()
+
+			if !*quietFlag {
+				log.Printf("Listening on UDP port %s", port)
+			}
+
+			b := make([]byte, *bufferFlag)
+			for {
+				n, addr, err := l.ReadFromUDP(b)
+				if err != nil {
+					log.Printf("read from UDP port %s failed, err: %v", port, err)
+					break
+				}
+				if !*quietFlag {
+					log.Printf("Read from client(%v:%v) on port %s, len: %v\n", addr.IP, addr.Port, port, n)
+				}
+				// Copy data to avoid race conditions on buffer 'b'
+				data := make([]byte, n)
+				copy(data, b[:n])
+				dataChan <- datagram{data: data, source: addr.String(), t: time.Now()}
+			}
+		}(strings.TrimSpace(p))
+	}
+	return dataChan
+}
+
+// selftestCommand implements "cruisemic selftest", which runs every
+// registered parser against its example input.
+func selftestCommand() int {
+	exitcode := 0
+	for _, r := range parse.SelfTest() {
+		fmt.Println(r)
+		if r.Err != nil {
+			exitcode = 1
+		}
+	}
+	return exitcode
+}
+
+// replay returns a channel that yields buffered datagrams followed by
+// datagrams from dataChan.
+func replay(buffered []datagram, dataChan <-chan datagram) <-chan datagram {
+	out := make(chan datagram)
+	go func() {
+		defer close(out)
+		for _, dg := range buffered {
+			out <- dg
+		}
+		for dg := range dataChan {
+			out <- dg
+		}
+	}()
+	return out
+}
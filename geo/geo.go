@@ -1,4 +1,5 @@
-// Package geo provides functions to convert GGA coordinates to decimal degree.
+// Package geo provides functions to convert GGA coordinates to decimal degree
+// and to compute true wind from ship relative wind.
 package geo
 
 import (
@@ -13,34 +14,44 @@ import (
 // returned with precision to 4 decimal places (11.132 m).
 // e.g. "2116.6922" -> "21.2782"
 func GGALat2DD(lat string, ns string) (string, error) {
+	deg, err := GGALat2Deg(lat, ns)
+	if err != nil {
+		return "", err
+	}
+	return FormatDD(deg), nil
+}
+
+// GGALat2Deg converts a GGA latitude to signed decimal degrees without
+// rounding. Arguments are the same as for GGALat2DD.
+func GGALat2Deg(lat string, ns string) (float64, error) {
 	if len(lat) > 0 && (string(lat[0]) == "-" || string(lat[0]) == "+") {
-		return "", fmt.Errorf("+/- should be passed as N/S")
+		return 0, fmt.Errorf("+/- should be passed as N/S")
 	}
 	if len(lat) < 4 {
-		return "", fmt.Errorf("bad GGA latitude, len < 4: %v", lat)
+		return 0, fmt.Errorf("bad GGA latitude, len < 4: %v", lat)
 	}
 	deg, err := strconv.ParseFloat(lat[:2], 64)
 	if err != nil {
-		return "", fmt.Errorf("bad GGA latitude, deg not numeric: %v", lat)
+		return 0, fmt.Errorf("bad GGA latitude, deg not numeric: %v", lat)
 	}
 	min, err := strconv.ParseFloat(lat[2:], 64)
 	if err != nil {
-		return "", fmt.Errorf("bad GGA latitude, min not numeric: %v", lat)
+		return 0, fmt.Errorf("bad GGA latitude, min not numeric: %v", lat)
 	}
 	if deg > 90 {
-		return "", fmt.Errorf("bad GGA latitude, gga=%v,%v deg=%v", lat, ns, deg)
+		return 0, fmt.Errorf("bad GGA latitude, gga=%v,%v deg=%v", lat, ns, deg)
 	}
 	if min > 60 {
-		return "", fmt.Errorf("bad GGA latitude, gga=%v,%v min=%v", lat, ns, min)
+		return 0, fmt.Errorf("bad GGA latitude, gga=%v,%v min=%v", lat, ns, min)
 	}
 
 	switch strings.ToUpper(ns) {
 	case "N":
-		return fmt.Sprintf("%.4f", deg+(min/60.0)), nil
+		return deg + (min / 60.0), nil
 	case "S":
-		return fmt.Sprintf("%.4f", -1*(deg+(min/60.0))), nil
+		return -1 * (deg + (min / 60.0)), nil
 	default:
-		return "", fmt.Errorf("bad GGA latitude, bad north/south char: %v", lat)
+		return 0, fmt.Errorf("bad GGA latitude, bad north/south char: %v", lat)
 	}
 }
 
@@ -50,37 +61,53 @@ func GGALat2DD(lat string, ns string) (string, error) {
 // returned with precision to 4 decimal places (11.132 m).
 // e.g. "15752.6526" -> "157.8775"
 func GGALon2DD(lon string, ew string) (string, error) {
+	deg, err := GGALon2Deg(lon, ew)
+	if err != nil {
+		return "", err
+	}
+	return FormatDD(deg), nil
+}
+
+// GGALon2Deg converts a GGA longitude to signed decimal degrees without
+// rounding. Arguments are the same as for GGALon2DD.
+func GGALon2Deg(lon string, ew string) (float64, error) {
 	if len(lon) > 0 && (string(lon[0]) == "-" || string(lon[0]) == "+") {
-		return "", fmt.Errorf("+/- should be passed as E/W")
+		return 0, fmt.Errorf("+/- should be passed as E/W")
 	}
 	if len(lon) < 5 {
-		return "", fmt.Errorf("bad GGA longitude, len < 5: %v", lon)
+		return 0, fmt.Errorf("bad GGA longitude, len < 5: %v", lon)
 	}
 	deg, err := strconv.ParseFloat(lon[:3], 64)
 	if err != nil {
-		return "", fmt.Errorf("bad GGA longitude, deg not numeric: %v", lon)
+		return 0, fmt.Errorf("bad GGA longitude, deg not numeric: %v", lon)
 	}
 	min, err := strconv.ParseFloat(lon[3:], 64)
 	if err != nil {
-		return "", fmt.Errorf("bad GGA longitude, min not numeric: %v", lon)
+		return 0, fmt.Errorf("bad GGA longitude, min not numeric: %v", lon)
 	}
 	if deg > 180 {
-		return "", fmt.Errorf("bad GGA longitude, deg > 180: gga=%v,%v deg=%v", lon, ew, deg)
+		return 0, fmt.Errorf("bad GGA longitude, deg > 180: gga=%v,%v deg=%v", lon, ew, deg)
 	}
 	if min > 60 {
-		return "", fmt.Errorf("bad GGA longitude, min > 60: gga=%v,%v min=%v", lon, ew, min)
+		return 0, fmt.Errorf("bad GGA longitude, min > 60: gga=%v,%v min=%v", lon, ew, min)
 	}
 
 	switch strings.ToUpper(ew) {
 	case "E":
-		return fmt.Sprintf("%.4f", deg+(min/60.0)), nil
+		return deg + (min / 60.0), nil
 	case "W":
-		return fmt.Sprintf("%.4f", -1*(deg+(min/60.0))), nil
+		return -1 * (deg + (min / 60.0)), nil
 	default:
-		return "", fmt.Errorf("bad GGA longitude, bad east/west char: %v", lon)
+		return 0, fmt.Errorf("bad GGA longitude, bad east/west char: %v", lon)
 	}
 }
 
+// FormatDD formats decimal degrees with precision to 4 decimal places
+// (11.132 m).
+func FormatDD(deg float64) string {
+	return fmt.Sprintf("%.4f", deg)
+}
+
 // CheckLat checks if the decimal degree longitude string is valid.
 func CheckLat(lat string) error {
 	val, err := strconv.ParseFloat(lat, 64)
@@ -0,0 +1,24 @@
+package geo
+
+import "math"
+
+// TrueWind returns true wind speed and direction from relative wind measured
+// on a moving ship. relSpeed is relative wind speed, relDir is the direction
+// relative wind comes from in degrees clockwise from the bow, heading is the
+// ship's true heading in degrees, and sog and cog are the ship's speed and
+// course over ground. Speeds are in the same unit, e.g. knots. The returned
+// direction is the direction true wind comes from in degrees true, 0 to 360.
+func TrueWind(relSpeed, relDir, heading, sog, cog float64) (speed, dir float64) {
+	rad := math.Pi / 180
+	app := (heading + relDir) * rad
+	// Earth frame velocity the wind blows toward is apparent wind plus ship
+	// velocity.
+	u := -relSpeed*math.Sin(app) + sog*math.Sin(cog*rad)
+	v := -relSpeed*math.Cos(app) + sog*math.Cos(cog*rad)
+	speed = math.Hypot(u, v)
+	if speed == 0 {
+		return 0, 0
+	}
+	dir = math.Mod(math.Atan2(-u, -v)/rad+360, 360)
+	return speed, dir
+}
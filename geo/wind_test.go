@@ -0,0 +1,31 @@
+package geo
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrueWind(t *testing.T) {
+	testData := []struct {
+		name                                string
+		relSpeed, relDir, heading, sog, cog float64
+		speed, dir                          float64
+	}{
+		{"stationary", 10, 90, 45, 0, 0, 10, 135},
+		{"calm, steaming north", 10, 0, 0, 10, 0, 0, 0},
+		{"head wind, steaming east", 15, 0, 90, 5, 90, 10, 90},
+		{"beam wind, steaming north", 10, 90, 0, 10, 0, 10 * math.Sqrt2, 135},
+		{"crabbing", 10, 0, 0, 10, 90, 10 * math.Sqrt2, 315},
+		{"wraps past north", 10, 350, 20, 0, 0, 10, 10},
+	}
+	for _, tt := range testData {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			speed, dir := TrueWind(tt.relSpeed, tt.relDir, tt.heading, tt.sog, tt.cog)
+			assert.InDelta(tt.speed, speed, 1e-9)
+			assert.InDelta(tt.dir, dir, 1e-9)
+		})
+	}
+}
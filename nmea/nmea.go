@@ -0,0 +1,837 @@
+// Package nmea decodes NMEA 0183 sentences into typed structs independent of
+// talker ID.
+package nmea
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ctberthiaume/cruisemic/geo"
+)
+
+// Sentence is a split NMEA 0183 sentence.
+type Sentence struct {
+	Raw      string   // original sentence text, whitespace trimmed
+	Start    byte     // '$' or '!'
+	Talker   string   // talker ID, e.g. "GP", or "P" for proprietary sentences
+	Type     string   // sentence type without talker, e.g. "GGA"
+	Fields   []string // data fields after the address field
+	Checksum string   // hex checksum after '*', empty if not present
+}
+
+// Parse splits an NMEA 0183 sentence into address, data fields and checksum.
+// The checksum is not verified.
+func Parse(s string) (Sentence, error) {
+	raw := strings.TrimSpace(s)
+	if len(raw) < 2 || (raw[0] != '$' && raw[0] != '!') {
+		return Sentence{}, fmt.Errorf("bad NMEA start: %q", s)
+	}
+	sen := Sentence{Raw: raw, Start: raw[0]}
+	body := raw[1:]
+	if i := strings.LastIndexByte(body, '*'); i >= 0 {
+		sen.Checksum = body[i+1:]
+		body = body[:i]
+	}
+	parts := strings.Split(body, ",")
+	address := parts[0]
+	switch {
+	case len(address) > 1 && address[0] == 'P':
+		sen.Talker, sen.Type = "P", address[1:]
+	case len(address) >= 5:
+		sen.Talker, sen.Type = address[:2], address[2:]
+	default:
+		return Sentence{}, fmt.Errorf("bad NMEA address: %q", s)
+	}
+	sen.Fields = parts[1:]
+	return sen, nil
+}
+
+// ChecksumError reports a missing or incorrect sentence checksum.
+type ChecksumError struct {
+	Sentence string // sentence text
+	Expected string // checksum computed from sentence contents
+	Got      string // checksum in sentence, empty if missing
+}
+
+func (e *ChecksumError) Error() string {
+	if e.Got == "" {
+		return fmt.Sprintf("missing NMEA checksum, expected %s: sentence=%q", e.Expected, e.Sentence)
+	}
+	return fmt.Sprintf("bad NMEA checksum %s, expected %s: sentence=%q", e.Got, e.Expected, e.Sentence)
+}
+
+// ComputeChecksum returns the two digit hex checksum of the sentence
+// contents, the XOR of all bytes between the start character and '*'.
+func (s Sentence) ComputeChecksum() string {
+	body := s.Raw[1:]
+	if i := strings.LastIndexByte(body, '*'); i >= 0 {
+		body = body[:i]
+	}
+	var sum byte
+	for i := 0; i < len(body); i++ {
+		sum ^= body[i]
+	}
+	return fmt.Sprintf("%02X", sum)
+}
+
+// VerifyChecksum returns a *ChecksumError if the sentence checksum is missing
+// or does not match its contents.
+func (s Sentence) VerifyChecksum() error {
+	expected := s.ComputeChecksum()
+	if !strings.EqualFold(s.Checksum, expected) {
+		return &ChecksumError{Sentence: s.Raw, Expected: expected, Got: s.Checksum}
+	}
+	return nil
+}
+
+// Float is a numeric field that keeps its original text. Empty fields are not
+// Valid and have Value NaN.
+type Float struct {
+	Value float64
+	Text  string
+	Valid bool
+}
+
+// String returns the original text of the field.
+func (f Float) String() string {
+	return f.Text
+}
+
+// parseFloat parses an optional numeric field. Empty fields are not an error.
+func parseFloat(s string) (Float, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Float{Value: math.NaN()}, nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return Float{Value: math.NaN()}, fmt.Errorf("bad number %q", s)
+	}
+	return Float{Value: v, Text: s, Valid: true}, nil
+}
+
+// parseInt parses an optional integer field. Empty fields return -1.
+func parseInt(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return -1, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return -1, fmt.Errorf("bad integer %q", s)
+	}
+	return v, nil
+}
+
+// ParseTimeOfDay parses an NMEA hhmmss or hhmmss.sss UTC time of day into a
+// duration since midnight.
+func ParseTimeOfDay(s string) (time.Duration, error) {
+	whole, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		whole, frac = s[:i], s[i:]
+	}
+	if len(whole) != 6 {
+		return 0, fmt.Errorf("bad time %q", s)
+	}
+	parts := make([]int, 3)
+	for i := range parts {
+		v, err := strconv.Atoi(whole[i*2 : i*2+2])
+		if err != nil || v < 0 {
+			return 0, fmt.Errorf("bad time %q", s)
+		}
+		parts[i] = v
+	}
+	if parts[0] > 23 || parts[1] > 59 || parts[2] > 59 {
+		return 0, fmt.Errorf("bad time %q", s)
+	}
+	d := time.Duration(parts[0])*time.Hour + time.Duration(parts[1])*time.Minute + time.Duration(parts[2])*time.Second
+	if frac != "" {
+		if len(frac) == 1 {
+			return 0, fmt.Errorf("bad time %q", s)
+		}
+		f, err := strconv.ParseFloat("0"+frac, 64)
+		if err != nil || strings.ContainsAny(frac[1:], "+-eE") {
+			return 0, fmt.Errorf("bad time %q", s)
+		}
+		d += time.Duration(math.Round(f * float64(time.Second)))
+	}
+	return d, nil
+}
+
+// date returns a UTC time for year, month, day plus time of day tod. The date
+// must be valid.
+func date(year, month, day int, tod time.Duration) (time.Time, error) {
+	t := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	if t.Year() != year || int(t.Month()) != month || t.Day() != day {
+		return time.Time{}, fmt.Errorf("bad date %04d-%02d-%02d", year, month, day)
+	}
+	return t.Add(tod), nil
+}
+
+// latLon parses a pair of NMEA coordinate and hemisphere fields starting at
+// fields[i].
+func latLon(fields []string, i int) (lat, lon float64, err error) {
+	lat, err = geo.GGALat2Deg(fields[i], fields[i+1])
+	if err != nil {
+		return 0, 0, err
+	}
+	lon, err = geo.GGALon2Deg(fields[i+2], fields[i+3])
+	if err != nil {
+		return 0, 0, err
+	}
+	return lat, lon, nil
+}
+
+// checkType returns an error if s is not of type typ with n data fields. If
+// max > n, any count from n to max is accepted.
+func checkType(s Sentence, typ string, n, max int) error {
+	if s.Type != typ {
+		return fmt.Errorf("not a %s sentence: %q", typ, s.Raw)
+	}
+	if max < n {
+		max = n
+	}
+	if len(s.Fields) < n || len(s.Fields) > max {
+		return fmt.Errorf("bad %s field count %d", typ, len(s.Fields))
+	}
+	return nil
+}
+
+// GGA is a Global Positioning System fix data sentence.
+type GGA struct {
+	Sentence
+	TimeOfDay  time.Duration // UTC time since midnight
+	Lat        float64       // decimal degrees
+	Lon        float64       // decimal degrees
+	Quality    int           // fix quality, 0 = invalid
+	Satellites int           // satellites in use, -1 if empty
+	HDOP       Float         // horizontal dilution of precision
+	Altitude   Float         // antenna altitude above mean sea level
+}
+
+// ParseGGA decodes a GGA sentence.
+func ParseGGA(s Sentence) (g GGA, err error) {
+	if err = checkType(s, "GGA", 14, 0); err != nil {
+		return g, err
+	}
+	f := s.Fields
+	g.Sentence = s
+	if g.TimeOfDay, err = ParseTimeOfDay(f[0]); err != nil {
+		return g, err
+	}
+	if g.Lat, g.Lon, err = latLon(f, 1); err != nil {
+		return g, err
+	}
+	if g.Quality, err = parseInt(f[5]); err != nil {
+		return g, err
+	}
+	if g.Satellites, err = parseInt(f[6]); err != nil {
+		return g, err
+	}
+	if g.HDOP, err = parseFloat(f[7]); err != nil {
+		return g, err
+	}
+	if g.Altitude, err = parseFloat(f[8]); err != nil {
+		return g, err
+	}
+	return g, nil
+}
+
+// RMC is a recommended minimum specific GNSS data sentence.
+type RMC struct {
+	Sentence
+	Time       time.Time // UTC date and time
+	Status     string    // A = valid, V = warning
+	Lat        float64   // decimal degrees
+	Lon        float64   // decimal degrees
+	SpeedKnots Float     // speed over ground
+	Course     Float     // course over ground, degrees true
+	Mode       string    // mode indicator, empty before NMEA 2.3
+}
+
+// ParseRMC decodes an RMC sentence. Two digit years are in the 2000s.
+func ParseRMC(s Sentence) (r RMC, err error) {
+	if err = checkType(s, "RMC", 11, 13); err != nil {
+		return r, err
+	}
+	f := s.Fields
+	r.Sentence = s
+	tod, err := ParseTimeOfDay(f[0])
+	if err != nil {
+		return r, err
+	}
+	r.Status = f[1]
+	if r.Lat, r.Lon, err = latLon(f, 2); err != nil {
+		return r, err
+	}
+	if r.SpeedKnots, err = parseFloat(f[6]); err != nil {
+		return r, err
+	}
+	if r.Course, err = parseFloat(f[7]); err != nil {
+		return r, err
+	}
+	d := f[8]
+	if len(d) != 6 {
+		return r, fmt.Errorf("bad date %q", d)
+	}
+	dmy := make([]int, 3)
+	for i := range dmy {
+		if dmy[i], err = strconv.Atoi(d[i*2 : i*2+2]); err != nil {
+			return r, fmt.Errorf("bad date %q", d)
+		}
+	}
+	if r.Time, err = date(2000+dmy[2], dmy[1], dmy[0], tod); err != nil {
+		return r, err
+	}
+	if len(f) > 11 {
+		r.Mode = f[11]
+	}
+	return r, nil
+}
+
+// ZDA is a time and date sentence.
+type ZDA struct {
+	Sentence
+	Time time.Time // UTC date and time
+}
+
+// ParseZDA decodes a ZDA sentence. Local zone fields are ignored.
+func ParseZDA(s Sentence) (z ZDA, err error) {
+	if err = checkType(s, "ZDA", 6, 0); err != nil {
+		return z, err
+	}
+	f := s.Fields
+	z.Sentence = s
+	tod, err := ParseTimeOfDay(f[0])
+	if err != nil {
+		return z, err
+	}
+	if len(f[1]) != 2 || len(f[2]) != 2 || len(f[3]) != 4 {
+		return z, fmt.Errorf("bad date %s-%s-%s", f[3], f[2], f[1])
+	}
+	ymd := make([]int, 3)
+	for i, v := range []string{f[3], f[2], f[1]} {
+		if ymd[i], err = strconv.Atoi(v); err != nil {
+			return z, fmt.Errorf("bad date %s-%s-%s", f[3], f[2], f[1])
+		}
+	}
+	if z.Time, err = date(ymd[0], ymd[1], ymd[2], tod); err != nil {
+		return z, err
+	}
+	return z, nil
+}
+
+// VTG is a course over ground and ground speed sentence.
+type VTG struct {
+	Sentence
+	CourseTrue     Float // degrees true
+	CourseMagnetic Float // degrees magnetic
+	SpeedKnots     Float
+	SpeedKmh       Float
+	Mode           string // mode indicator, empty before NMEA 2.3
+}
+
+// ParseVTG decodes a VTG sentence.
+func ParseVTG(s Sentence) (v VTG, err error) {
+	if err = checkType(s, "VTG", 8, 9); err != nil {
+		return v, err
+	}
+	f := s.Fields
+	v.Sentence = s
+	if v.CourseTrue, err = parseFloat(f[0]); err != nil {
+		return v, err
+	}
+	if v.CourseMagnetic, err = parseFloat(f[2]); err != nil {
+		return v, err
+	}
+	if v.SpeedKnots, err = parseFloat(f[4]); err != nil {
+		return v, err
+	}
+	if v.SpeedKmh, err = parseFloat(f[6]); err != nil {
+		return v, err
+	}
+	if len(f) > 8 {
+		v.Mode = f[8]
+	}
+	return v, nil
+}
+
+// GLL is a geographic position sentence.
+type GLL struct {
+	Sentence
+	Lat       float64       // decimal degrees
+	Lon       float64       // decimal degrees
+	TimeOfDay time.Duration // UTC time since midnight
+	Status    string        // A = valid, V = invalid
+	Mode      string        // mode indicator, empty before NMEA 2.3
+}
+
+// ParseGLL decodes a GLL sentence.
+func ParseGLL(s Sentence) (g GLL, err error) {
+	if err = checkType(s, "GLL", 6, 7); err != nil {
+		return g, err
+	}
+	f := s.Fields
+	g.Sentence = s
+	if g.Lat, g.Lon, err = latLon(f, 0); err != nil {
+		return g, err
+	}
+	if g.TimeOfDay, err = ParseTimeOfDay(f[4]); err != nil {
+		return g, err
+	}
+	g.Status = f[5]
+	if len(f) > 6 {
+		g.Mode = f[6]
+	}
+	return g, nil
+}
+
+// DTM is a datum reference sentence.
+type DTM struct {
+	Sentence
+	Datum          string // local datum code, e.g. W84
+	SubDatum       string
+	LatOffset      Float // minutes, north positive
+	LonOffset      Float // minutes, east positive
+	AltitudeOffset Float // meters
+	ReferenceDatum string
+}
+
+// ParseDTM decodes a DTM sentence.
+func ParseDTM(s Sentence) (d DTM, err error) {
+	if err = checkType(s, "DTM", 8, 0); err != nil {
+		return d, err
+	}
+	f := s.Fields
+	d.Sentence = s
+	d.Datum = f[0]
+	d.SubDatum = f[1]
+	if d.LatOffset, err = parseFloat(f[2]); err != nil {
+		return d, err
+	}
+	if f[3] == "S" {
+		d.LatOffset.Value = -d.LatOffset.Value
+	}
+	if d.LonOffset, err = parseFloat(f[4]); err != nil {
+		return d, err
+	}
+	if f[5] == "W" {
+		d.LonOffset.Value = -d.LonOffset.Value
+	}
+	if d.AltitudeOffset, err = parseFloat(f[6]); err != nil {
+		return d, err
+	}
+	d.ReferenceDatum = f[7]
+	return d, nil
+}
+
+// HDT is a true heading sentence.
+type HDT struct {
+	Sentence
+	Heading Float // degrees true
+}
+
+// ParseHDT decodes an HDT sentence.
+func ParseHDT(s Sentence) (h HDT, err error) {
+	if err = checkType(s, "HDT", 2, 0); err != nil {
+		return h, err
+	}
+	h.Sentence = s
+	if h.Heading, err = parseFloat(s.Fields[0]); err != nil {
+		return h, err
+	}
+	return h, nil
+}
+
+// HDG is a magnetic heading, deviation, and variation sentence.
+type HDG struct {
+	Sentence
+	Heading   Float // magnetic sensor heading, degrees
+	Deviation Float // magnetic deviation, degrees, east positive
+	Variation Float // magnetic variation, degrees, east positive
+}
+
+// ParseHDG decodes an HDG sentence.
+func ParseHDG(s Sentence) (h HDG, err error) {
+	if err = checkType(s, "HDG", 5, 0); err != nil {
+		return h, err
+	}
+	f := s.Fields
+	h.Sentence = s
+	if h.Heading, err = parseFloat(f[0]); err != nil {
+		return h, err
+	}
+	if h.Deviation, err = parseFloat(f[1]); err != nil {
+		return h, err
+	}
+	if f[2] == "W" {
+		h.Deviation.Value = -h.Deviation.Value
+	}
+	if h.Variation, err = parseFloat(f[3]); err != nil {
+		return h, err
+	}
+	if f[4] == "W" {
+		h.Variation.Value = -h.Variation.Value
+	}
+	return h, nil
+}
+
+// TrueHeading returns the true heading in degrees from 0 to 360, the sensor
+// heading corrected for deviation and variation. An empty deviation is 0. It
+// returns false if heading or variation are empty.
+func (h HDG) TrueHeading() (float64, bool) {
+	if !h.Heading.Valid || !h.Variation.Valid {
+		return 0, false
+	}
+	heading := h.Heading.Value + h.Variation.Value
+	if h.Deviation.Valid {
+		heading += h.Deviation.Value
+	}
+	heading = math.Mod(heading, 360)
+	if heading < 0 {
+		heading += 360
+	}
+	return heading, true
+}
+
+// ROT is a rate of turn sentence.
+type ROT struct {
+	Sentence
+	Rate   Float  // degrees per minute, negative to port
+	Status string // A = valid, V = invalid
+}
+
+// ParseROT decodes a ROT sentence.
+func ParseROT(s Sentence) (r ROT, err error) {
+	if err = checkType(s, "ROT", 2, 0); err != nil {
+		return r, err
+	}
+	r.Sentence = s
+	if r.Rate, err = parseFloat(s.Fields[0]); err != nil {
+		return r, err
+	}
+	r.Status = s.Fields[1]
+	return r, nil
+}
+
+// PASHR is a proprietary attitude sentence from inertial and GNSS heading
+// systems, e.g. Applanix POS MV, with true heading, roll, pitch, and heave.
+// The sentence type without the P prefix is ASHR.
+type PASHR struct {
+	Sentence
+	TimeOfDay time.Duration // UTC time since midnight
+	Heading   Float         // degrees true
+	Roll      Float         // degrees, positive port side up
+	Pitch     Float         // degrees, positive bow up
+	Heave     Float         // meters
+}
+
+// ParsePASHR decodes a PASHR sentence. Accuracy and status fields after heave
+// are ignored.
+func ParsePASHR(s Sentence) (a PASHR, err error) {
+	if err = checkType(s, "ASHR", 6, 11); err != nil {
+		return a, err
+	}
+	f := s.Fields
+	a.Sentence = s
+	if a.TimeOfDay, err = ParseTimeOfDay(f[0]); err != nil {
+		return a, err
+	}
+	if a.Heading, err = parseFloat(f[1]); err != nil {
+		return a, err
+	}
+	if a.Roll, err = parseFloat(f[3]); err != nil {
+		return a, err
+	}
+	if a.Pitch, err = parseFloat(f[4]); err != nil {
+		return a, err
+	}
+	if a.Heave, err = parseFloat(f[5]); err != nil {
+		return a, err
+	}
+	return a, nil
+}
+
+// Meters per foot and per fathom for depth conversions.
+const (
+	metersPerFoot   = 0.3048
+	metersPerFathom = 1.8288
+)
+
+// DBT is a depth below transducer sentence.
+type DBT struct {
+	Sentence
+	Feet    Float
+	Meters  Float
+	Fathoms Float
+}
+
+// ParseDBT decodes a DBT sentence.
+func ParseDBT(s Sentence) (d DBT, err error) {
+	if err = checkType(s, "DBT", 6, 0); err != nil {
+		return d, err
+	}
+	f := s.Fields
+	d.Sentence = s
+	if d.Feet, err = parseFloat(f[0]); err != nil {
+		return d, err
+	}
+	if d.Meters, err = parseFloat(f[2]); err != nil {
+		return d, err
+	}
+	if d.Fathoms, err = parseFloat(f[4]); err != nil {
+		return d, err
+	}
+	return d, nil
+}
+
+// DepthMeters returns depth below transducer in meters, converted from feet
+// or fathoms if the meters field is empty. It returns false if all depth
+// fields are empty.
+func (d DBT) DepthMeters() (float64, bool) {
+	switch {
+	case d.Meters.Valid:
+		return d.Meters.Value, true
+	case d.Feet.Valid:
+		return d.Feet.Value * metersPerFoot, true
+	case d.Fathoms.Valid:
+		return d.Fathoms.Value * metersPerFathom, true
+	}
+	return 0, false
+}
+
+// DPT is a depth sentence.
+type DPT struct {
+	Sentence
+	Depth    Float // meters below transducer
+	Offset   Float // meters from transducer, positive to waterline, negative to keel
+	MaxRange Float // meters, empty before NMEA 3.0
+}
+
+// ParseDPT decodes a DPT sentence.
+func ParseDPT(s Sentence) (d DPT, err error) {
+	if err = checkType(s, "DPT", 2, 3); err != nil {
+		return d, err
+	}
+	f := s.Fields
+	d.Sentence = s
+	if d.Depth, err = parseFloat(f[0]); err != nil {
+		return d, err
+	}
+	if d.Offset, err = parseFloat(f[1]); err != nil {
+		return d, err
+	}
+	if len(f) > 2 {
+		if d.MaxRange, err = parseFloat(f[2]); err != nil {
+			return d, err
+		}
+	}
+	return d, nil
+}
+
+// PSKPDPT is a Kongsberg proprietary depth sentence from EA and EK
+// echosounders and EM multibeam center beams. The sentence type without the P
+// prefix is SKPDPT.
+type PSKPDPT struct {
+	Sentence
+	Depth    Float  // meters below transducer
+	Offset   Float  // meters from transducer, positive to waterline, negative to keel
+	MaxRange Float  // meters
+	Quality  int    // bottom detection quality, -1 if empty
+	Channel  int    // transceiver channel, -1 if empty
+	Name     string // transceiver name
+}
+
+// ParsePSKPDPT decodes a PSKPDPT sentence. Trailing quality, channel, and
+// name fields are optional.
+func ParsePSKPDPT(s Sentence) (d PSKPDPT, err error) {
+	if err = checkType(s, "SKPDPT", 3, 6); err != nil {
+		return d, err
+	}
+	f := s.Fields
+	d.Sentence = s
+	d.Quality, d.Channel = -1, -1
+	if d.Depth, err = parseFloat(f[0]); err != nil {
+		return d, err
+	}
+	if d.Offset, err = parseFloat(f[1]); err != nil {
+		return d, err
+	}
+	if d.MaxRange, err = parseFloat(f[2]); err != nil {
+		return d, err
+	}
+	if len(f) > 3 {
+		if d.Quality, err = parseInt(f[3]); err != nil {
+			return d, err
+		}
+	}
+	if len(f) > 4 {
+		if d.Channel, err = parseInt(f[4]); err != nil {
+			return d, err
+		}
+	}
+	if len(f) > 5 {
+		d.Name = f[5]
+	}
+	return d, nil
+}
+
+// PKEL99 is a Knudsen proprietary depth sentence from Chirp echosounders,
+// with a frequency, depth, and bottom detection flag for the low and high
+// frequency channels, then sound speed. The sentence type without the P
+// prefix is KEL99.
+type PKEL99 struct {
+	Sentence
+	LFFrequency Float // kHz
+	LFDepth     Float // meters below transducer
+	LFValid     bool  // low frequency bottom detected
+	HFFrequency Float // kHz
+	HFDepth     Float // meters below transducer
+	HFValid     bool  // high frequency bottom detected
+	SoundSpeed  Float // m/s
+}
+
+// ParsePKEL99 decodes a PKEL99 sentence. Fields after sound speed are
+// ignored.
+func ParsePKEL99(s Sentence) (d PKEL99, err error) {
+	if err = checkType(s, "KEL99", 7, len(s.Fields)); err != nil {
+		return d, err
+	}
+	f := s.Fields
+	d.Sentence = s
+	for i, ch := range []struct {
+		freq, depth *Float
+		valid       *bool
+	}{
+		{&d.LFFrequency, &d.LFDepth, &d.LFValid},
+		{&d.HFFrequency, &d.HFDepth, &d.HFValid},
+	} {
+		if *ch.freq, err = parseFloat(f[i*3]); err != nil {
+			return d, err
+		}
+		if *ch.depth, err = parseFloat(f[i*3+1]); err != nil {
+			return d, err
+		}
+		valid, err := parseInt(f[i*3+2])
+		if err != nil {
+			return d, err
+		}
+		*ch.valid = valid == 1
+	}
+	if d.SoundSpeed, err = parseFloat(f[6]); err != nil {
+		return d, err
+	}
+	return d, nil
+}
+
+// Depth returns the high frequency depth if the high frequency channel
+// detected the bottom, or else the low frequency depth if the low frequency
+// channel did. It returns false if neither did.
+func (d PKEL99) Depth() (Float, bool) {
+	switch {
+	case d.HFValid && d.HFDepth.Valid:
+		return d.HFDepth, true
+	case d.LFValid && d.LFDepth.Valid:
+		return d.LFDepth, true
+	}
+	return Float{Value: math.NaN()}, false
+}
+
+// MWV is a wind speed and angle sentence.
+type MWV struct {
+	Sentence
+	Angle     Float  // degrees clockwise from the bow
+	Reference string // R = relative, T = theoretical (true)
+	Speed     Float
+	Unit      string // K = km/h, M = m/s, N = knots, S = statute mph
+	Status    string // A = valid, V = invalid
+}
+
+// ParseMWV decodes an MWV sentence.
+func ParseMWV(s Sentence) (m MWV, err error) {
+	if err = checkType(s, "MWV", 5, 0); err != nil {
+		return m, err
+	}
+	f := s.Fields
+	m.Sentence = s
+	if m.Angle, err = parseFloat(f[0]); err != nil {
+		return m, err
+	}
+	m.Reference = f[1]
+	if m.Speed, err = parseFloat(f[2]); err != nil {
+		return m, err
+	}
+	m.Unit = f[3]
+	m.Status = f[4]
+	return m, nil
+}
+
+// SpeedKnots returns wind speed in knots. It returns false if speed is empty
+// or the unit is unknown.
+func (m MWV) SpeedKnots() (float64, bool) {
+	if !m.Speed.Valid {
+		return 0, false
+	}
+	switch m.Unit {
+	case "N":
+		return m.Speed.Value, true
+	case "K":
+		return m.Speed.Value / 1.852, true
+	case "M":
+		return m.Speed.Value * 3600 / 1852, true
+	case "S":
+		return m.Speed.Value * 1609.344 / 1852, true
+	}
+	return 0, false
+}
+
+// Decode parses a sentence and decodes it to a typed struct if its type is
+// supported. Unsupported sentence types are returned as a Sentence.
+func Decode(line string) (interface{}, error) {
+	s, err := Parse(line)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeSentence(s)
+}
+
+// DecodeSentence decodes a parsed sentence to a typed struct if its type is
+// supported. Unsupported sentence types are returned as a Sentence.
+func DecodeSentence(s Sentence) (interface{}, error) {
+	switch s.Type {
+	case "GGA":
+		return ParseGGA(s)
+	case "RMC":
+		return ParseRMC(s)
+	case "ZDA":
+		return ParseZDA(s)
+	case "VTG":
+		return ParseVTG(s)
+	case "GLL":
+		return ParseGLL(s)
+	case "DTM":
+		return ParseDTM(s)
+	case "HDT":
+		return ParseHDT(s)
+	case "HDG":
+		return ParseHDG(s)
+	case "ROT":
+		return ParseROT(s)
+	case "ASHR":
+		return ParsePASHR(s)
+	case "DBT":
+		return ParseDBT(s)
+	case "DPT":
+		return ParseDPT(s)
+	case "SKPDPT":
+		return ParsePSKPDPT(s)
+	case "KEL99":
+		return ParsePKEL99(s)
+	case "MWV":
+		return ParseMWV(s)
+	}
+	return s, nil
+}
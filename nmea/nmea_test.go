@@ -0,0 +1,436 @@
+package nmea
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testParseData struct {
+	name        string
+	input       string
+	talker      string
+	typ         string
+	fieldCount  int
+	checksum    string
+	expectError bool
+}
+
+func TestParse(t *testing.T) {
+	testData := []testParseData{
+		{"GPGGA", "$GPGGA,003029.00,2118.9043,N,15752.6526,W,2,7,0.8,27,M,,M,,*78", "GP", "GGA", 14, "78", false},
+		{"GNZDA with whitespace", " $GNZDA,192824.00,08,01,2026,00,00*73\r\n", "GN", "ZDA", 6, "73", false},
+		{"proprietary", "$PPAR, 157.580, 6.10, 5", "P", "PAR", 3, "", false},
+		{"AIS", "!AIVDM,1,1,,A,13aEOK?P00PD2wVMdLDRhgvL289?,0*26", "AI", "VDM", 6, "26", false},
+		{"no start", "GPGGA,003029.00*78", "", "", 0, "", true},
+		{"short address", "$GP,1,2", "", "", 0, "", true},
+		{"empty", "", "", "", 0, "", true},
+	}
+	for _, tt := range testData {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			s, err := Parse(tt.input)
+			if tt.expectError {
+				assert.NotNil(err, tt.name)
+				return
+			}
+			assert.Nil(err, tt.name)
+			assert.Equal(tt.talker, s.Talker, tt.name)
+			assert.Equal(tt.typ, s.Type, tt.name)
+			assert.Equal(tt.fieldCount, len(s.Fields), tt.name)
+			assert.Equal(tt.checksum, s.Checksum, tt.name)
+		})
+	}
+}
+
+func TestParseTimeOfDay(t *testing.T) {
+	assert := assert.New(t)
+	d, err := ParseTimeOfDay("213309.50")
+	assert.Nil(err)
+	assert.Equal(21*time.Hour+33*time.Minute+9*time.Second+500*time.Millisecond, d)
+	d, err = ParseTimeOfDay("160332")
+	assert.Nil(err)
+	assert.Equal(16*time.Hour+3*time.Minute+32*time.Second, d)
+	for _, bad := range []string{"", "16033", "1603322", "240000", "166000", "160361", "16a332", "160332.", "160332.-1"} {
+		_, err = ParseTimeOfDay(bad)
+		assert.NotNil(err, bad)
+	}
+}
+
+func TestParseGGA(t *testing.T) {
+	assert := assert.New(t)
+	for _, line := range []string{
+		"$GPGGA,003029.00,2118.9043,N,15752.6526,W,2,7,0.8,27,M,,M,,*78",
+		"$GNGGA,003029.00,2118.9043,N,15752.6526,W,2,7,0.8,27,M,0.000,M,75,0000*43",
+	} {
+		s, _ := Parse(line)
+		g, err := ParseGGA(s)
+		assert.Nil(err, line)
+		assert.Equal(30*time.Minute+29*time.Second, g.TimeOfDay)
+		assert.InDelta(21.315072, g.Lat, 1e-6)
+		assert.InDelta(-157.877543, g.Lon, 1e-6)
+		assert.Equal(2, g.Quality)
+		assert.Equal(7, g.Satellites)
+		assert.Equal("0.8", g.HDOP.String())
+		assert.InDelta(27.0, g.Altitude.Value, 1e-9)
+	}
+
+	for _, line := range []string{
+		"$GPGGA,003029.00,2118.9043,N,15752.6526,W,2,7,0.8,27,M,X,,M,,*78",
+		"$GPGGA,003029.00,21a8.9043,N,15752.6526,W,2,7,0.8,27,M,,M,,*78",
+		"$GPGGA,003029.00,2118.9043,N,15752.6526,D,2,7,0.8,27,M,,M,,*78",
+		"$GPGGA,003029.00,2118.9043,N,15752.6526,W,a,7,0.8,27,M,,M,,*78",
+		"$GPRMC,160332,A,4743.7694,N,00322.4405,W,0.0,182.6,071225,0.2,W,D*19",
+	} {
+		s, _ := Parse(line)
+		_, err := ParseGGA(s)
+		assert.NotNil(err, line)
+	}
+}
+
+func TestParseRMC(t *testing.T) {
+	assert := assert.New(t)
+	s, _ := Parse("$GPRMC,160332,A,4743.7694,N,00322.4405,W,0.0,182.6,071225,0.2,W,D*19")
+	r, err := ParseRMC(s)
+	assert.Nil(err)
+	assert.Equal(time.Date(2025, 12, 7, 16, 3, 32, 0, time.UTC), r.Time)
+	assert.Equal("A", r.Status)
+	assert.InDelta(47.729490, r.Lat, 1e-6)
+	assert.InDelta(-3.374008, r.Lon, 1e-6)
+	assert.Equal("0.0", r.SpeedKnots.String())
+	assert.Equal("182.6", r.Course.String())
+	assert.Equal("D", r.Mode)
+
+	for _, line := range []string{
+		"$GPRMC,160332,A,4743.7694,N,00322.4405,W,0.0,182.6,401425,0.2,W,D*19",
+		"$GPRMC,160332,A,4743.7694,N,00322.4405,W,0.0,182.6,07122,0.2,W,D*19",
+		"$GPRMC,160332,A,4743.7694,N,00322.4405,W,0.0,182.6,0712AB,0.2,W,D*19",
+		"$GPRMC,300332,A,4743.7694,N,00322.4405,W,0.0,182.6,071225,0.2,W,D*19",
+		"$GPRMC,160332,A,4743.7694,N,00322.4405,W,0.0,182.6",
+	} {
+		s, _ := Parse(line)
+		_, err := ParseRMC(s)
+		assert.NotNil(err, line)
+	}
+}
+
+func TestParseZDA(t *testing.T) {
+	assert := assert.New(t)
+	s, _ := Parse("$GPZDA,213309.25,12,01,2023,00,00*6D")
+	z, err := ParseZDA(s)
+	assert.Nil(err)
+	assert.Equal(time.Date(2023, 1, 12, 21, 33, 9, 250000000, time.UTC), z.Time)
+
+	for _, line := range []string{
+		"$GPZDA,213309.00,12,2023,00,00*6D",
+		"$GPZDA,21a309.00,12,01,2023,00,00*6D",
+		"$GPZDA,213309.00,30,02,2023,00,00*6D",
+		"$GPZDA,213309.00,12,1,2023,00,00*6D",
+	} {
+		s, _ := Parse(line)
+		_, err := ParseZDA(s)
+		assert.NotNil(err, line)
+	}
+}
+
+func TestParseVTG(t *testing.T) {
+	assert := assert.New(t)
+	s, _ := Parse("$GPVTG,47.3,T,37.7,M,0.0,N,0.0,K,D*25")
+	v, err := ParseVTG(s)
+	assert.Nil(err)
+	assert.Equal("47.3", v.CourseTrue.String())
+	assert.Equal("37.7", v.CourseMagnetic.String())
+	assert.Equal("0.0", v.SpeedKnots.String())
+	assert.Equal("D", v.Mode)
+
+	s, _ = Parse("$GPVTG,,T,,M,0.0,N,0.0,K*25")
+	v, err = ParseVTG(s)
+	assert.Nil(err)
+	assert.False(v.CourseTrue.Valid)
+
+	s, _ = Parse("$GPVTG,4a7.3,T,37.7,M,0.0,N,0.0,K,D*25")
+	_, err = ParseVTG(s)
+	assert.NotNil(err)
+}
+
+func TestParseGLL(t *testing.T) {
+	assert := assert.New(t)
+	s, _ := Parse("$GPGLL,4743.7696,N,00322.4403,W,160333,A,D*5E")
+	g, err := ParseGLL(s)
+	assert.Nil(err)
+	assert.InDelta(47.729493, g.Lat, 1e-6)
+	assert.Equal(16*time.Hour+3*time.Minute+33*time.Second, g.TimeOfDay)
+	assert.Equal("A", g.Status)
+	assert.Equal("D", g.Mode)
+
+	// Torn sentence
+	s, _ = Parse("$GPGLL,4743.7694,N,00322.4405,W,160332,")
+	_, err = ParseGLL(s)
+	assert.Nil(err)
+	s, _ = Parse("$GPGLL,4743.7694,N,00322.4405,W")
+	_, err = ParseGLL(s)
+	assert.NotNil(err)
+}
+
+func TestParseDTM(t *testing.T) {
+	assert := assert.New(t)
+	s, _ := Parse("$GPDTM,W84,,00.0000,N,00.0000,E,,W84*41")
+	d, err := ParseDTM(s)
+	assert.Nil(err)
+	assert.Equal("W84", d.Datum)
+	assert.Equal("W84", d.ReferenceDatum)
+	assert.Equal(0.0, d.LatOffset.Value)
+	assert.False(d.AltitudeOffset.Valid)
+}
+
+func TestParseHDT(t *testing.T) {
+	assert := assert.New(t)
+	s, _ := Parse("$HEHDT,123.4,T*2F")
+	h, err := ParseHDT(s)
+	assert.Nil(err)
+	assert.Equal("123.4", h.Heading.String())
+
+	s, _ = Parse("$HEHDT,12a3.4,T*2F")
+	_, err = ParseHDT(s)
+	assert.NotNil(err)
+}
+
+func TestParseHDG(t *testing.T) {
+	assert := assert.New(t)
+	s, _ := Parse("$HCHDG,358.5,1.0,W,3.5,E*00")
+	h, err := ParseHDG(s)
+	assert.Nil(err)
+	assert.Equal(-1.0, h.Deviation.Value)
+	assert.Equal(3.5, h.Variation.Value)
+	heading, ok := h.TrueHeading()
+	assert.True(ok)
+	assert.InDelta(1.0, heading, 1e-9)
+
+	// No variation, no deviation
+	s, _ = Parse("$HCHDG,98.3,,,,*00")
+	h, err = ParseHDG(s)
+	assert.Nil(err)
+	_, ok = h.TrueHeading()
+	assert.False(ok)
+	s, _ = Parse("$HCHDG,98.3,,,7.1,W*00")
+	h, _ = ParseHDG(s)
+	heading, ok = h.TrueHeading()
+	assert.True(ok)
+	assert.InDelta(91.2, heading, 1e-9)
+}
+
+func TestParseROT(t *testing.T) {
+	assert := assert.New(t)
+	s, _ := Parse("$HEROT,-12.5,A*00")
+	r, err := ParseROT(s)
+	assert.Nil(err)
+	assert.Equal(-12.5, r.Rate.Value)
+	assert.Equal("A", r.Status)
+
+	s, _ = Parse("$HEROT,-12.5*00")
+	_, err = ParseROT(s)
+	assert.NotNil(err)
+}
+
+func TestParsePASHR(t *testing.T) {
+	assert := assert.New(t)
+	s, _ := Parse("$PASHR,160332.000,123.40,T,-0.52,1.20,0.03,0.011,0.011,0.028,2,1*00")
+	a, err := ParsePASHR(s)
+	assert.Nil(err)
+	assert.Equal(16*time.Hour+3*time.Minute+32*time.Second, a.TimeOfDay)
+	assert.Equal("123.40", a.Heading.String())
+	assert.Equal("-0.52", a.Roll.String())
+	assert.Equal("1.20", a.Pitch.String())
+	assert.Equal("0.03", a.Heave.String())
+
+	// Short form without accuracy and status fields
+	s, _ = Parse("$PASHR,160332.000,123.40,T,-0.52,1.20,*00")
+	a, err = ParsePASHR(s)
+	assert.Nil(err)
+	assert.False(a.Heave.Valid)
+
+	s, _ = Parse("$PASHR,160332.000,123.40,T,-0.52*00")
+	_, err = ParsePASHR(s)
+	assert.NotNil(err)
+}
+
+func TestParseDBT(t *testing.T) {
+	assert := assert.New(t)
+	s, _ := Parse("$SDDBT,15197.2,f,4632.1,M,2532.9,F*00")
+	d, err := ParseDBT(s)
+	assert.Nil(err)
+	depth, ok := d.DepthMeters()
+	assert.True(ok)
+	assert.Equal(4632.1, depth)
+
+	s, _ = Parse("$SDDBT,100.0,f,,M,,F*00")
+	d, _ = ParseDBT(s)
+	depth, ok = d.DepthMeters()
+	assert.True(ok)
+	assert.InDelta(30.48, depth, 1e-9)
+
+	s, _ = Parse("$SDDBT,,f,,M,,F*00")
+	d, _ = ParseDBT(s)
+	_, ok = d.DepthMeters()
+	assert.False(ok)
+}
+
+func TestParseDPT(t *testing.T) {
+	assert := assert.New(t)
+	s, _ := Parse("$SDDPT,4632.1,-0.5,12000*00")
+	d, err := ParseDPT(s)
+	assert.Nil(err)
+	assert.Equal("4632.1", d.Depth.String())
+	assert.Equal(-0.5, d.Offset.Value)
+	assert.Equal(12000.0, d.MaxRange.Value)
+
+	// NMEA 2.x, no max range
+	s, _ = Parse("$SDDPT,4632.1,0.0*00")
+	d, err = ParseDPT(s)
+	assert.Nil(err)
+	assert.False(d.MaxRange.Valid)
+
+	s, _ = Parse("$SDDPT,46a32.1,0.0*00")
+	_, err = ParseDPT(s)
+	assert.NotNil(err)
+}
+
+func TestParsePSKPDPT(t *testing.T) {
+	assert := assert.New(t)
+	s, _ := Parse("$PSKPDPT,4632.10,6.90,12000,10,1,EK80*00")
+	d, err := ParsePSKPDPT(s)
+	assert.Nil(err)
+	assert.Equal("4632.10", d.Depth.String())
+	assert.Equal(6.9, d.Offset.Value)
+	assert.Equal(10, d.Quality)
+	assert.Equal(1, d.Channel)
+	assert.Equal("EK80", d.Name)
+
+	s, _ = Parse("$PSKPDPT,4632.10,6.90,12000*00")
+	d, err = ParsePSKPDPT(s)
+	assert.Nil(err)
+	assert.Equal(-1, d.Quality)
+}
+
+func TestParsePKEL99(t *testing.T) {
+	assert := assert.New(t)
+	s, _ := Parse("$PKEL99,3.5,4633.52,1,12.0,4632.10,1,1500*00")
+	d, err := ParsePKEL99(s)
+	assert.Nil(err)
+	assert.Equal(3.5, d.LFFrequency.Value)
+	assert.Equal(12.0, d.HFFrequency.Value)
+	assert.Equal(1500.0, d.SoundSpeed.Value)
+	depth, ok := d.Depth()
+	assert.True(ok)
+	assert.Equal("4632.10", depth.String())
+
+	// High frequency lost the bottom
+	s, _ = Parse("$PKEL99,3.5,4633.52,1,12.0,,0,1500,47.6497,-122.3134*00")
+	d, err = ParsePKEL99(s)
+	assert.Nil(err)
+	depth, ok = d.Depth()
+	assert.True(ok)
+	assert.Equal("4633.52", depth.String())
+
+	s, _ = Parse("$PKEL99,3.5,0.00,0,12.0,0.00,0,1500*00")
+	d, err = ParsePKEL99(s)
+	assert.Nil(err)
+	_, ok = d.Depth()
+	assert.False(ok)
+
+	for _, bad := range []string{
+		"$PKEL99,3.5,4633.52,1,12.0,4632.10,1*00",
+		"$PKEL99,3.5,46a33.52,1,12.0,4632.10,1,1500*00",
+		"$PKEL99,3.5,4633.52,x,12.0,4632.10,1,1500*00",
+	} {
+		s, _ = Parse(bad)
+		_, err = ParsePKEL99(s)
+		assert.NotNil(err, bad)
+	}
+}
+
+func TestParseMWV(t *testing.T) {
+	assert := assert.New(t)
+	s, _ := Parse("$WIMWV,233.0,R,10.0,N,A*00")
+	m, err := ParseMWV(s)
+	assert.Nil(err)
+	assert.Equal(233.0, m.Angle.Value)
+	assert.Equal("R", m.Reference)
+	assert.Equal("A", m.Status)
+	speed, ok := m.SpeedKnots()
+	assert.True(ok)
+	assert.Equal(10.0, speed)
+
+	for _, tt := range []struct {
+		line  string
+		knots float64
+	}{
+		{"$WIMWV,233.0,R,18.52,K,A*00", 10},
+		{"$WIMWV,233.0,R,5.144444,M,A*00", 10},
+		{"$WIMWV,233.0,R,11.50779,S,A*00", 10},
+	} {
+		s, _ = Parse(tt.line)
+		m, _ = ParseMWV(s)
+		speed, ok = m.SpeedKnots()
+		assert.True(ok, tt.line)
+		assert.InDelta(tt.knots, speed, 1e-4, tt.line)
+	}
+
+	s, _ = Parse("$WIMWV,233.0,R,10.0,X,A*00")
+	m, _ = ParseMWV(s)
+	_, ok = m.SpeedKnots()
+	assert.False(ok, "unknown unit")
+
+	s, _ = Parse("$WIMWV,233.0,R,10.0*00")
+	_, err = ParseMWV(s)
+	assert.NotNil(err)
+}
+
+func TestDecode(t *testing.T) {
+	assert := assert.New(t)
+	v, err := Decode("$GNZDA,192824.00,08,01,2026,00,00*73")
+	assert.Nil(err)
+	_, ok := v.(ZDA)
+	assert.True(ok)
+	v, err = Decode("$HEHDT,123.4,T*00")
+	assert.Nil(err)
+	_, ok = v.(HDT)
+	assert.True(ok)
+	v, err = Decode("$PASHR,160332.000,123.40,T,-0.52,1.20,0.03,0.011,0.011,0.028,2,1*00")
+	assert.Nil(err)
+	_, ok = v.(PASHR)
+	assert.True(ok)
+	v, err = Decode("$GPGSA,A,3,04,05,,09,12,,,24,,,,,2.5,1.3,2.1*39")
+	assert.Nil(err)
+	_, ok = v.(Sentence)
+	assert.True(ok)
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	assert := assert.New(t)
+	for _, line := range []string{
+		"$GPRMC,160332,A,4743.7694,N,00322.4405,W,0.0,182.6,071225,0.2,W,D*19",
+		"$GNZDA,192824.00,08,01,2026,00,00*73",
+		"$GPDTM,W84,,00.0000,N,00.0000,E,,W84*41",
+		"$gpdtm,W84,,00.0000,N,00.0000,E,,W84*61",
+	} {
+		s, _ := Parse(line)
+		assert.Nil(s.VerifyChecksum(), line)
+	}
+
+	s, _ := Parse("$GPRMC,160332,A,4743.7694,N,00322.4405,W,0.0,182.6,071225,0.2,W,D*18")
+	err := s.VerifyChecksum()
+	if assert.IsType(&ChecksumError{}, err) {
+		cerr := err.(*ChecksumError)
+		assert.Equal("19", cerr.Expected)
+		assert.Equal("18", cerr.Got)
+	}
+
+	// Torn sentence without checksum
+	s, _ = Parse("$GPGLL,4743.7694,N,00322.4405,W,160332,")
+	err = s.VerifyChecksum()
+	if assert.IsType(&ChecksumError{}, err) {
+		assert.Equal("", err.(*ChecksumError).Got)
+	}
+}
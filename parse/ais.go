@@ -0,0 +1,207 @@
+package parse
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ctberthiaume/cruisemic/ais"
+	"github.com/ctberthiaume/cruisemic/geo"
+	"github.com/ctberthiaume/cruisemic/nmea"
+	"github.com/ctberthiaume/tsdata"
+)
+
+// AISName is the string designator for AIS vessel data sent to storage
+const AISName = "ais"
+
+// AISMonitor wraps a Parser to decode !AIVDM and !AIVDO lines into an AISName
+// feed of nearby vessel positions and names. Other lines are passed to the
+// wrapped parser. AIS messages only carry the UTC second, so AIS records are
+// timed by the latest underway record time, adjusted to the message's second
+// when available. AIS lines before the first underway record are decoded but
+// not written. Rate limiting is per vessel.
+type AISMonitor struct {
+	parser    Parser
+	assembler *ais.Assembler
+	checksum  ChecksumPolicy
+	interval  time.Duration
+	throttles map[uint32]*Throttle // rate limiting by MMSI
+	names     map[uint32]string    // latest vessel names by MMSI
+	last      time.Time            // time of the latest underway record
+	metadata  tsdata.Tsdata
+}
+
+// NewAISMonitor returns a pointer to an AISMonitor struct for parser. project
+// is the project or cruise name. interval is the per-vessel rate limiting
+// interval. checksum is the NMEA checksum policy for AIS sentences.
+func NewAISMonitor(parser Parser, project string, interval time.Duration, checksum ChecksumPolicy) *AISMonitor {
+	return &AISMonitor{
+		parser:    parser,
+		assembler: ais.NewAssembler(),
+		checksum:  checksum,
+		interval:  interval,
+		throttles: make(map[uint32]*Throttle),
+		names:     make(map[uint32]string),
+		metadata: tsdata.Tsdata{
+			Project:         project,
+			FileType:        AISName,
+			FileDescription: "AIS nearby vessel feed",
+			Comments: []string{
+				"RFC3339",
+				"Maritime Mobile Service Identity",
+				"AIS message type",
+				"Latitude Decimal format",
+				"Longitude Decimal format",
+				"Speed over ground",
+				"Course over ground",
+				"True heading",
+				"Vessel name from AIS static data",
+			},
+			Types:   []string{"time", "integer", "integer", "float", "float", "float", "float", "float", "text"},
+			Units:   []string{"NA", "NA", "NA", "deg", "deg", "kn", "deg", "deg", "NA"},
+			Headers: []string{"time", "mmsi", "message_type", "lat", "lon", "sog", "cog", "heading", "name"},
+		},
+	}
+}
+
+// ParseLine parses a single line and returns the underway record.
+func (a *AISMonitor) ParseLine(line string) Data {
+	for _, d := range a.ParseLineFeeds(line) {
+		if d.Feed == UnderwayName {
+			return d
+		}
+	}
+	return Data{}
+}
+
+// Header returns the underway Tsdata header paragraph.
+func (a *AISMonitor) Header() string {
+	return a.parser.Header()
+}
+
+// Limit applies the wrapped parser's rate limiting to d.
+func (a *AISMonitor) Limit(d *Data) {
+	a.parser.Limit(d)
+}
+
+// HostClock returns true if the wrapped parser timestamps records with the
+// host clock.
+func (a *AISMonitor) HostClock() bool {
+	return UsesHostClock(a.parser)
+}
+
+// FeedHeaders returns Tsdata headers for the wrapped parser's feeds plus the
+// AIS feed.
+func (a *AISMonitor) FeedHeaders() map[string]string {
+	headers := FeedHeaders(a.parser)
+	headers[AISName] = a.metadata.Header()
+	return headers
+}
+
+// ParseLineFeeds parses a single line. AIS lines return an AIS record, other
+// lines return the wrapped parser's records.
+func (a *AISMonitor) ParseLineFeeds(line string) []Data {
+	clean := strings.TrimSpace(line)
+	if strings.HasPrefix(clean, "!") {
+		if sen, err := nmea.Parse(clean); err == nil && (sen.Type == "VDM" || sen.Type == "VDO") {
+			return []Data{a.parseAIS(sen, clean)}
+		}
+	}
+	var records []Data
+	if mp, ok := a.parser.(MultiFeedParser); ok {
+		records = mp.ParseLineFeeds(line)
+	} else {
+		d := a.parser.ParseLine(line)
+		d.Feed = UnderwayName
+		records = []Data{d}
+	}
+	for _, d := range records {
+		if (d.Feed == UnderwayName || d.Feed == "") && !d.Time.IsZero() {
+			a.last = d.Time
+		}
+	}
+	return records
+}
+
+// parseAIS adds an AIS sentence and returns an AIS record when a message with
+// a position or static data is complete.
+func (a *AISMonitor) parseAIS(sen nmea.Sentence, line string) (d Data) {
+	d.Feed = AISName
+	if a.checksum != ChecksumIgnore {
+		if err := sen.VerifyChecksum(); err != nil {
+			d.Errors = append(d.Errors, fmt.Errorf("AISMonitor: bad %s%s: %w: line=%q", sen.Talker, sen.Type, err, line))
+			if a.checksum == ChecksumReject {
+				return
+			}
+		}
+	}
+	m, ok, err := a.assembler.Add(sen)
+	if err != nil {
+		d.Errors = append(d.Errors, fmt.Errorf("AISMonitor: bad %s%s: %w: line=%q", sen.Talker, sen.Type, err, line))
+		return
+	}
+	if !ok || (!m.HasPosition() && !m.HasStatic()) {
+		return
+	}
+	if m.Name != "" {
+		a.names[m.MMSI] = m.Name
+	}
+	if a.last.IsZero() {
+		return
+	}
+	name := tsdata.NA
+	if n, ok := a.names[m.MMSI]; ok {
+		name = n
+	}
+	d.Time = aisTime(a.last, m.Second)
+	d.Values = []Value{
+		NewValue("integer", strconv.FormatUint(uint64(m.MMSI), 10)),
+		NewValue("integer", strconv.Itoa(m.Type)),
+		NewValue("float", aisFloat(m.Lat, geo.FormatDD)),
+		NewValue("float", aisFloat(m.Lon, geo.FormatDD)),
+		NewValue("float", aisFloat(m.SOG, formatTenths)),
+		NewValue("float", aisFloat(m.COG, formatTenths)),
+		NewValue("float", aisFloat(m.Heading, func(v float64) string { return strconv.FormatFloat(v, 'f', 0, 64) })),
+		NewValue("text", name),
+	}
+	th, ok := a.throttles[m.MMSI]
+	if !ok {
+		t := NewThrottle(a.interval)
+		th = &t
+		a.throttles[m.MMSI] = th
+	}
+	th.Limit(&d)
+	return d
+}
+
+// aisFloat formats v with format, or returns tsdata.NA if v is NaN.
+func aisFloat(v float64, format func(float64) string) string {
+	if math.IsNaN(v) {
+		return tsdata.NA
+	}
+	return format(v)
+}
+
+// formatTenths formats an AIS speed or course, which have a resolution of
+// 0.1, with one decimal place.
+func formatTenths(v float64) string {
+	return strconv.FormatFloat(v, 'f', 1, 64)
+}
+
+// aisTime returns the time nearest to ref with UTC second sec, or ref if sec
+// is -1.
+func aisTime(ref time.Time, sec int) time.Time {
+	if sec < 0 {
+		return ref
+	}
+	t := ref.Truncate(time.Minute).Add(time.Duration(sec) * time.Second)
+	switch diff := t.Sub(ref); {
+	case diff > 30*time.Second:
+		t = t.Add(-time.Minute)
+	case diff <= -30*time.Second:
+		t = t.Add(time.Minute)
+	}
+	return t
+}
@@ -0,0 +1,52 @@
+package parse
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ctberthiaume/cruisemic/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAISMonitor(t *testing.T) {
+	assert := assert.New(t)
+	input := `!AIVDM,1,1,,B,177KQJ5000G?tO` + "`" + `K>RA1wUbN0TKH,0*5C
+$SEAFLOW::$GPZDA,213309.00,12,01,2023,00,00*6D::$GPGGA,213309.00,4738.983141,N,12218.805824,W,2,17,0.7,15.773,M,-22.2,M,7.0,0402*44:: 12.3719,  3.64868,  31.2816::157.580
+!AIVDM,2,1,1,A,55?MbV02;H;s<HtKR20EHE:0@T4@Dn2222222216L961O5Gf0NSQEp6ClRp8,0*1C
+!AIVDM,2,2,1,A,88888888880,2*25
+!AIVDM,1,1,,B,177KQJ5000G?tO` + "`" + `K>RA1wUbN0TKH,0*5C
+!AIVDO,1,1,,A,B52K>;h00Fc>jpUlNV@ikwpUoP06,0*4E
+!AIVDM,1,1,,B,177KQJ5000G?tO` + "`" + `K>RA1wUbN0TKH,0*00
+`
+	a := NewAISMonitor(NewTN427Parser("test", 0, time.Now), "test", 0, ChecksumReject)
+	assert.Contains(a.FeedHeaders(), UnderwayName)
+	assert.Contains(a.FeedHeaders()[AISName], "time\tmmsi\tmessage_type\tlat\tlon\tsog\tcog\theading\tname")
+	store, _ := storage.NewMemStorage()
+	err := ParseLines(a, strings.NewReader(input), store, true, false)
+	assert.Nil(err)
+	assert.Equal(map[string][]string{
+		"geo": {"2023-01-12T21:33:09Z\t47.6497\t-122.3134\t12.3719\t3.64868\t31.2816\t157.580\n"},
+		"ais": {
+			// Static data is timed by the last underway record
+			"2023-01-12T21:33:09Z\t351759000\t5\tNA\tNA\tNA\tNA\tNA\tEVER DIADEM\n",
+			// Position reports are timed by their UTC second
+			"2023-01-12T21:33:15Z\t477553000\t1\t47.5828\t-122.3458\t0.0\t51.0\t181\tNA\n",
+			"2023-01-12T21:32:49Z\t338087471\t18\t40.6845\t-74.0721\t0.1\t79.6\tNA\tNA\n",
+		},
+	}, store.Feeds)
+	d := a.ParseLineFeeds(strings.Split(input, "\n")[6])
+	assert.Len(d, 1)
+	assert.Len(d[0].Errors, 1)
+	assert.False(d[0].OK())
+}
+
+func TestAISTime(t *testing.T) {
+	assert := assert.New(t)
+	ref := time.Date(2023, 1, 12, 21, 33, 9, 0, time.UTC)
+	assert.Equal(ref, aisTime(ref, -1))
+	assert.Equal(time.Date(2023, 1, 12, 21, 33, 15, 0, time.UTC), aisTime(ref, 15))
+	assert.Equal(time.Date(2023, 1, 12, 21, 32, 50, 0, time.UTC), aisTime(ref, 50))
+	ref = time.Date(2023, 1, 12, 21, 33, 55, 0, time.UTC)
+	assert.Equal(time.Date(2023, 1, 12, 21, 34, 5, 0, time.UTC), aisTime(ref, 5))
+}
@@ -0,0 +1,107 @@
+package parse
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ctberthiaume/cruisemic/nmea"
+	"github.com/ctberthiaume/tsdata"
+)
+
+// AttitudeSetter is implemented by parsers that can write heading, pitch,
+// roll, and rate_of_turn columns from HDT, HDG, PASHR, and ROT sentences in
+// their feed. Attitude columns are added after all other columns.
+type AttitudeSetter interface {
+	SetAttitude(on bool)
+}
+
+// attitudeColumns are the attitude column names.
+var attitudeColumns = []string{"heading", "pitch", "roll", "rate_of_turn"}
+
+// setAttitude sets whether attitude columns are written.
+func (dm *DataManager) setAttitude(on bool) {
+	dm.attitude = on
+	dm.SetMetadata(dm.base)
+}
+
+// attitudeMetadata returns a copy of metadata with attitude columns appended.
+func attitudeMetadata(metadata tsdata.Tsdata) tsdata.Tsdata {
+	return appendColumns(metadata, attitudeColumns,
+		[]string{"float", "float", "float", "float"},
+		[]string{"deg", "deg", "deg", "deg/min"},
+		[]string{"True heading from HDT, PASHR, or HDG", "Pitch, positive bow up", "Roll, positive port side up", "Rate of turn, negative to port"})
+}
+
+// addAttitude adds attitude values from sen if it's an HDT, HDG, PASHR, or ROT
+// sentence and attitude columns are on. t is the measurement time for Merge.
+// It returns false if sen was not used. The checksum is checked according to
+// the checksum policy. The latest sentence with a value for a column wins.
+// HDG sentences without magnetic variation are not used, since true heading
+// can't be computed.
+func (dm *DataManager) addAttitude(sen nmea.Sentence, t time.Time) (bool, error) {
+	if !dm.attitude {
+		return false, nil
+	}
+	switch {
+	case sen.Type == "HDT", sen.Type == "HDG", sen.Type == "ROT":
+	case sen.Talker == "P" && sen.Type == "ASHR":
+	default:
+		return false, nil
+	}
+	if err := dm.checkSentence(sen); err != nil {
+		return true, err
+	}
+	switch sen.Type {
+	case "HDT":
+		hdt, err := nmea.ParseHDT(sen)
+		if err != nil {
+			return true, err
+		}
+		dm.addAttitudeValue("heading", hdt.Heading, t)
+	case "HDG":
+		hdg, err := nmea.ParseHDG(sen)
+		if err != nil {
+			return true, err
+		}
+		if heading, ok := hdg.TrueHeading(); ok {
+			dm.AddValueAt("heading", formatHeading(heading), t)
+		}
+	case "ASHR":
+		ashr, err := nmea.ParsePASHR(sen)
+		if err != nil {
+			return true, err
+		}
+		dm.addAttitudeValue("heading", ashr.Heading, t)
+		dm.addAttitudeValue("pitch", ashr.Pitch, t)
+		dm.addAttitudeValue("roll", ashr.Roll, t)
+	case "ROT":
+		rot, err := nmea.ParseROT(sen)
+		if err != nil {
+			return true, err
+		}
+		if strings.TrimSpace(rot.Status) == "V" {
+			dm.AddInvalid("rate_of_turn", rot.Rate.String())
+			return true, fmt.Errorf("rate of turn status V")
+		}
+		dm.addAttitudeValue("rate_of_turn", rot.Rate, t)
+	}
+	return true, nil
+}
+
+// addAttitudeValue adds an attitude value measured at time t. Empty values
+// don't replace an earlier value in the same record.
+func (dm *DataManager) addAttitudeValue(key string, f nmea.Float, t time.Time) {
+	if !f.Valid {
+		if _, ok := dm.values[key]; ok {
+			return
+		}
+	}
+	dm.AddValueAt(key, floatText(f), t)
+}
+
+// formatHeading formats a heading computed from HDG with two decimal places.
+func formatHeading(h float64) string {
+	return strconv.FormatFloat(h, 'f', 2, 64)
+}
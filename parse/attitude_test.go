@@ -0,0 +1,93 @@
+package parse
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ctberthiaume/cruisemic/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKMAttitude(t *testing.T) {
+	assert := assert.New(t)
+	input := `2017 168 00 30 28 990 bar1   1016.07 mbar
+2017 168 00 30 28 998 uthsl 19.968599 0.040550 0.217500 27.397800
+$GPGGA,003029.00,2118.9043,N,15752.6526,W,2,7,0.8,27,M,,M,,*78
+$HEHDT,123.4,T*2F
+$PASHR,003029.000,123.50,T,-0.52,1.20,0.03,0.011,0.011,0.028,2,1*00
+$HEROT,-12.5,A*00
+2017 168 00 30 29 365 flor 78.000000
+$GPVTG,47.3,T,37.7,M,0.0,N,0.0,K,D*25
+2017 168 00 30 29 909 met  0.000 28.680  50.900 28.470 24.766  3.758 -0.246  1.097  1.099  0.000 5040.000  1.016 11.9 235.0 11.9   83.3 R-  0.000  0.000
+2017 168 00 30 29 990 bar1   1016.05 mbar
+$GPGGA,003029.00,2118.9043,N,15752.6526,W,2,7,0.8,27,M,,M,,*78
+$HCHDG,358.5,1.0,W,3.5,E*00
+$HEROT,-12.5,V*00
+2017 168 00 30 30 990 bar1   1016.05 mbar
+`
+	p := NewKiloMoanaParser("test", 0, time.Now).(*KiloMoanaParser)
+	p.SetAttitude(true)
+	assert.Contains(p.Header(), "lon\theading\tpitch\troll\trate_of_turn")
+	store, _ := storage.NewMemStorage()
+	err := ParseLines(p, strings.NewReader(input), store, true, false)
+	assert.Nil(err)
+	assert.Equal(map[string][]string{
+		"geo": {
+			"2017-06-17T00:30:28.99Z\t19.968599\t0.040550\t0.217500\t27.397800\t47.3\t0.0\t78.000000\t1.016\t21.3151\t-157.8775\t123.50\t1.20\t-0.52\t-12.5\n",
+			"2017-06-17T00:30:29.99Z\tNA\tNA\tNA\tNA\tNA\tNA\tNA\tNA\t21.3151\t-157.8775\t1.00\tNA\tNA\tNA\n",
+		},
+	}, store.Feeds)
+
+	// Attitude sentences are ignored without attitude columns
+	p = NewKiloMoanaParser("test", 0, time.Now).(*KiloMoanaParser)
+	store, _ = storage.NewMemStorage()
+	err = ParseLines(p, strings.NewReader(input), store, true, false)
+	assert.Nil(err)
+	assert.Equal("2017-06-17T00:30:28.99Z\t19.968599\t0.040550\t0.217500\t27.397800\t47.3\t0.0\t78.000000\t1.016\t21.3151\t-157.8775\n", store.Feeds["geo"][0])
+}
+
+func TestTARAAttitude(t *testing.T) {
+	assert := assert.New(t)
+	input := `$GPRMC,160331,A,4743.7690,N,00322.4408,W,0.0,182.6,071225,0.2,W,D*13
+$HEHDT,182.1,T*2F
+$HEHDT,,T*2F
+$HEHDT,18a2.1,T*2F
+$GPRMC,160332,A,4743.7694,N,00322.4405,W,0.0,182.6,071225,0.2,W,D*19
+`
+	p := NewTARAParser("test", 0, time.Now).(*TARAParser)
+	p.SetAttitude(true)
+	var records []Data
+	for _, line := range strings.SplitAfter(input, "\n") {
+		if d := p.ParseLine(line); d.OK() {
+			records = append(records, d)
+		}
+	}
+	if assert.Len(records, 2) {
+		assert.Equal("2025-12-07T16:03:31Z\t47.7295\t-3.3740\tNA\tNA\tNA\tNA\tNA\tNA\tNA\tNA", records[0].Line("\t"))
+		assert.Len(records[1].Errors, 1, "bad HDT")
+		assert.Equal("2025-12-07T16:03:32Z\t47.7295\t-3.3740\tNA\tNA\tNA\tNA\t182.1\tNA\tNA\tNA", records[1].Line("\t"))
+	}
+}
+
+func TestSeaflowAttitude(t *testing.T) {
+	assert := assert.New(t)
+	input := `$HEHDT,123.4,T*2F
+$PASHR,213309.000,123.50,T,-0.52,1.20,0.03,0.011,0.011,0.028,2,1*00
+$SEAFLOW::$GPZDA,213309.00,12,01,2023,00,00*6D::$GPGGA,213309.00,4738.983141,N,12218.805824,W,2,17,0.7,15.773,M,-22.2,M,7.0,0402*44:: 12.3719,  3.64868,  31.2816::157.580
+$SEAFLOW::$GPZDA,213310.00,12,01,2023,00,00*65::$GPGGA,213310.00,4738.983143,N,12218.805821,W,2,17,0.7,15.774,M,-22.2,M,8.0,0402*43:: 12.3719,  3.64868,  31.2816::157.580
+`
+	p := NewTN427Parser("test", 0, time.Now).(*TN427Parser)
+	p.SetAttitude(true)
+	assert.Contains(p.Header(), "par\theading\tpitch\troll\trate_of_turn")
+	store, _ := storage.NewMemStorage()
+	err := ParseLines(p, strings.NewReader(input), store, true, false)
+	assert.Nil(err)
+	assert.Equal(map[string][]string{
+		"geo": {
+			"2023-01-12T21:33:09Z\t47.6497\t-122.3134\t12.3719\t3.64868\t31.2816\t157.580\t123.50\t1.20\t-0.52\tNA\n",
+			// No attitude sentences since the last record
+			"2023-01-12T21:33:10Z\t47.6497\t-122.3134\t12.3719\t3.64868\t31.2816\t157.580\tNA\tNA\tNA\tNA\n",
+		},
+	}, store.Feeds)
+}
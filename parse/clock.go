@@ -0,0 +1,147 @@
+package parse
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/ctberthiaume/tsdata"
+)
+
+// ClockName is the string designator for host clock offset data sent to storage
+const ClockName = "clock"
+
+// HostClockParser is implemented by parsers that may timestamp records with
+// the host clock rather than with GPS time from the feed. Host clock drift
+// can't be measured from these records.
+type HostClockParser interface {
+	HostClock() bool
+}
+
+// UsesHostClock returns true if parser timestamps records with the host
+// clock.
+func UsesHostClock(parser Parser) bool {
+	hp, ok := parser.(HostClockParser)
+	return ok && hp.HostClock()
+}
+
+// ClockMonitor wraps a Parser to compare the host clock time each line was
+// received to the GPS time of each underway record. The offset is host time
+// minus GPS time. Errors are added to records when the offset first exceeds a
+// threshold and when it returns within it. If the clock feed is enabled, a
+// ClockName record with host time and offset is written for every underway
+// record.
+type ClockMonitor struct {
+	parser    Parser
+	throttle  Throttle
+	now       func() time.Time
+	threshold time.Duration
+	feed      bool
+	metadata  tsdata.Tsdata
+	drifting  bool // offset currently exceeds threshold
+}
+
+// NewClockMonitor returns a pointer to a ClockMonitor struct for parser.
+// project is the project or cruise name. interval is the clock feed rate
+// limiting interval. threshold is the largest allowed absolute offset, 0 to
+// never warn. feed enables the ClockName feed. now returns the host time the
+// line being parsed was received.
+func NewClockMonitor(parser Parser, project string, interval time.Duration, threshold time.Duration, feed bool, now func() time.Time) *ClockMonitor {
+	return &ClockMonitor{
+		parser:    parser,
+		throttle:  NewThrottle(interval),
+		now:       now,
+		threshold: threshold,
+		feed:      feed,
+		metadata: tsdata.Tsdata{
+			Project:         project,
+			FileType:        ClockName,
+			FileDescription: "Host clock offset from GPS time",
+			Comments:        []string{"RFC3339 GPS time", "RFC3339 host time", "Host time minus GPS time"},
+			Types:           []string{"time", "time", "float"},
+			Units:           []string{"NA", "NA", "s"},
+			Headers:         []string{"time", "host_time", "offset"},
+		},
+	}
+}
+
+// ParseLine parses a single line and returns the underway record.
+func (c *ClockMonitor) ParseLine(line string) Data {
+	for _, d := range c.ParseLineFeeds(line) {
+		if d.Feed == UnderwayName {
+			return d
+		}
+	}
+	return Data{}
+}
+
+// Header returns the underway Tsdata header paragraph.
+func (c *ClockMonitor) Header() string {
+	return c.parser.Header()
+}
+
+// Limit applies the wrapped parser's rate limiting to d.
+func (c *ClockMonitor) Limit(d *Data) {
+	c.parser.Limit(d)
+}
+
+// FeedHeaders returns Tsdata headers for the wrapped parser's feeds, plus the
+// clock feed if enabled.
+func (c *ClockMonitor) FeedHeaders() map[string]string {
+	headers := FeedHeaders(c.parser)
+	if c.feed {
+		headers[ClockName] = c.metadata.Header()
+	}
+	return headers
+}
+
+// ParseLineFeeds parses a single line with the wrapped parser and returns its
+// records followed by any clock record.
+func (c *ClockMonitor) ParseLineFeeds(line string) []Data {
+	var records []Data
+	if mp, ok := c.parser.(MultiFeedParser); ok {
+		records = mp.ParseLineFeeds(line)
+	} else {
+		d := c.parser.ParseLine(line)
+		d.Feed = UnderwayName
+		records = []Data{d}
+	}
+	for _, d := range records {
+		if (d.Feed == UnderwayName || d.Feed == "") && !d.Time.IsZero() {
+			records = append(records, c.observe(d.Time))
+			break
+		}
+	}
+	return records
+}
+
+// observe compares the host receive time to gps and returns a clock record.
+// The record is empty if the clock feed is not enabled.
+func (c *ClockMonitor) observe(gps time.Time) (d Data) {
+	host := c.now().UTC()
+	offset := host.Sub(gps)
+	if c.threshold > 0 {
+		abs := offset
+		if abs < 0 {
+			abs = -abs
+		}
+		if abs > c.threshold && !c.drifting {
+			c.drifting = true
+			d.Errors = append(d.Errors, fmt.Errorf("ClockMonitor: host clock offset %v from GPS time exceeds %v", offset, c.threshold))
+		} else if abs <= c.threshold && c.drifting {
+			c.drifting = false
+			d.Errors = append(d.Errors, fmt.Errorf("ClockMonitor: host clock offset %v from GPS time is back within %v", offset, c.threshold))
+		}
+	}
+	if !c.feed {
+		return d
+	}
+	d.Feed = ClockName
+	d.Time = gps
+	d.Values = []Value{
+		NewValue("time", host.Format(time.RFC3339Nano)),
+		NewValue("float", strconv.FormatFloat(offset.Seconds(), 'f', 3, 64)),
+	}
+	c.throttle.Limit(&d)
+	return d
+}
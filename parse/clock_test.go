@@ -0,0 +1,60 @@
+package parse
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ctberthiaume/cruisemic/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClockMonitor(t *testing.T) {
+	assert := assert.New(t)
+	// GPS times are 21:33:09, 21:33:10, 21:33:11. The host clock is 1.5s
+	// fast, then 10.25s fast, then 2s slow.
+	hostTimes := []time.Time{
+		time.Date(2023, 1, 12, 21, 33, 10, 500000000, time.UTC),
+		time.Date(2023, 1, 12, 21, 33, 20, 250000000, time.UTC),
+		time.Date(2023, 1, 12, 21, 33, 9, 0, time.UTC),
+	}
+	now := func() time.Time {
+		t := hostTimes[0]
+		hostTimes = hostTimes[1:]
+		return t
+	}
+	c := NewClockMonitor(NewTN427Parser("test", 0, time.Now), "test", 0, 5*time.Second, true, now)
+	input := `$SEAFLOW::$GPZDA,213309.00,12,01,2023,00,00*6D::$GPGGA,213309.00,4738.983141,N,12218.805824,W,2,17,0.7,15.773,M,-22.2,M,7.0,0402*44:: 12.3719,  3.64868,  31.2816::157.580
+$SEAFLOW::$GPZDA,213310.00,12,01,2023,00,00*6D::$GPGGA,213309.00,4738.983141,N,12218.805824,W,2,17,0.7,15.773,M,-22.2,M,7.0,0402*44:: 12.3719,  3.64868,  31.2816::157.580
+$SEAFLOW::$GPZDA,213311.00,12,01,2023,00,00*6D::$GPGGA,213309.00,4738.983141,N,12218.805824,W,2,17,0.7,15.773,M,-22.2,M,7.0,0402*44:: 12.3719,  3.64868,  31.2816::157.580
+`
+	var errs []string
+	for _, line := range strings.SplitAfter(input, "\n") {
+		for _, d := range c.ParseLineFeeds(line) {
+			for _, err := range d.Errors {
+				errs = append(errs, err.Error())
+			}
+		}
+	}
+	assert.Equal([]string{
+		"ClockMonitor: host clock offset 10.25s from GPS time exceeds 5s",
+		"ClockMonitor: host clock offset -2s from GPS time is back within 5s",
+	}, errs)
+
+	hostTimes = []time.Time{time.Date(2023, 1, 12, 21, 33, 10, 500000000, time.UTC)}
+	c = NewClockMonitor(NewTN427Parser("test", 0, time.Now), "test", 0, 0, true, now)
+	assert.Contains(c.FeedHeaders(), ClockName)
+	store, _ := storage.NewMemStorage()
+	err := ParseLines(c, strings.NewReader(strings.SplitAfter(input, "\n")[0]), store, true, false)
+	assert.Nil(err)
+	assert.Equal(map[string][]string{
+		"geo":   {"2023-01-12T21:33:09Z\t47.6497\t-122.3134\t12.3719\t3.64868\t31.2816\t157.580\n"},
+		"clock": {"2023-01-12T21:33:09Z\t2023-01-12T21:33:10.5Z\t1.500\n"},
+	}, store.Feeds)
+}
+
+func TestUsesHostClock(t *testing.T) {
+	assert := assert.New(t)
+	assert.True(UsesHostClock(NewGradients4Parser("test", 0, time.Now)))
+	assert.False(UsesHostClock(NewTN427Parser("test", 0, time.Now)))
+}
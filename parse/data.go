@@ -7,9 +7,12 @@ import (
 
 // Data holds parsed data for a single time point of an underway feed
 type Data struct {
+	Feed      string // feed name, UnderwayName if empty
 	Time      time.Time
+	RecvTime  time.Time // host clock receive time, only written if not zero
 	Throttled bool
-	Values    []string
+	Values    []Value // all columns except time
+	Flagged   bool    // write a flag column after each value in Line
 	Errors    []error
 }
 
@@ -22,7 +25,7 @@ func (d Data) String() (s string) {
 		s += d.Time.Format(time.RFC3339Nano)
 	}
 	if d.Values != nil {
-		s = s + "," + strings.Join(d.Values, ",")
+		s = s + "," + strings.Join(d.Strings(), ",")
 	}
 	if d.Errors != nil {
 		errorStrings := []string{}
@@ -34,9 +37,20 @@ func (d Data) String() (s string) {
 	return s
 }
 
-// Line creates a delimited line of text, starting with RFC3339 timestamp.
+// Line creates a delimited line of text, starting with RFC3339 timestamp,
+// followed by the RFC3339 receive time if set. If d.Flagged is true each value
+// is followed by its quality flag.
 func (d Data) Line(sep string) string {
-	s := append([]string{d.Time.Format(time.RFC3339Nano)}, d.Values...)
+	s := []string{d.Time.Format(time.RFC3339Nano)}
+	if !d.RecvTime.IsZero() {
+		s = append(s, d.RecvTime.Format(time.RFC3339Nano))
+	}
+	for _, v := range d.Values {
+		s = append(s, v.String())
+		if d.Flagged {
+			s = append(s, v.Flag.String())
+		}
+	}
 	return strings.Join(s, sep)
 }
 
@@ -44,3 +58,13 @@ func (d Data) Line(sep string) string {
 func (d Data) OK() bool {
 	return !d.Time.IsZero() && (len(d.Values) > 0) && !d.Throttled
 }
+
+// Strings returns the output text for each value, with tsdata.NA for values
+// that are not present.
+func (d Data) Strings() []string {
+	s := make([]string, len(d.Values))
+	for i, v := range d.Values {
+		s[i] = v.String()
+	}
+	return s
+}
@@ -9,36 +9,220 @@ import (
 // DataManager supports adding and retrieving parsed data and metadata.
 type DataManager struct {
 	Throttle
-	t        time.Time         // latest time read
-	values   map[string]string // latest values by column name
-	errors   []error           // errors encountered when parsing latest values
-	metadata tsdata.Tsdata     // TSDATA output file metadata
+	t        time.Time            // latest time read
+	values   map[string]Value     // latest values by column name
+	stamps   map[string]time.Time // measurement times of latest values
+	held     map[string]Value     // values from earlier records, see Merge
+	heldAt   map[string]time.Time // measurement times of held values
+	maxAge   time.Duration        // max age of merged values, 0 for no holding
+	types    map[string]string    // TSDATA types by column name
+	errors   []error              // errors encountered when parsing latest values
+	metadata tsdata.Tsdata        // TSDATA output file metadata
+	base     tsdata.Tsdata        // metadata without GPS fix columns
+	checksum ChecksumPolicy       // NMEA checksum policy
+	flagged  bool                 // write quality flag columns
+	fix      gpsFix               // GPS fix columns and policy
+	attitude bool                 // write attitude columns
+	depth    depthConfig          // depth column and transducer offset
+	wind     bool                 // write wind columns
+	motion   motion               // latest ship heading and velocity for true wind
+}
+
+// FlagColumnsSetter is implemented by parsers that can write a quality flag
+// column after each data column.
+type FlagColumnsSetter interface {
+	SetFlagColumns(on bool)
 }
 
 // NewDataManager returns a pointer to a DataManager struct. metadata is the
 // Tsdata definition of all data values managed by this struct. interval is the
 // per-feed rate limiting interval in seconds.
 func NewDataManager(metadata tsdata.Tsdata, interval time.Duration) *DataManager {
-	return &DataManager{
+	dm := &DataManager{
 		Throttle: NewThrottle(interval),
-		values:   make(map[string]string),
-		metadata: metadata,
+		values:   make(map[string]Value),
+		stamps:   make(map[string]time.Time),
+		held:     make(map[string]Value),
+		heldAt:   make(map[string]time.Time),
 	}
+	dm.SetMetadata(metadata)
+	return dm
+}
+
+// SetMetadata replaces the Tsdata definition of all data values, e.g. when
+// parser options add columns. GPS fix, attitude, depth, and wind columns are
+// appended if configured.
+func (dm *DataManager) SetMetadata(metadata tsdata.Tsdata) {
+	dm.base = metadata
+	if dm.fix.columns {
+		metadata = gpsFixMetadata(metadata, dm.fix.source)
+	}
+	if dm.attitude {
+		metadata = attitudeMetadata(metadata)
+	}
+	if dm.depth.on {
+		metadata = depthMetadata(metadata)
+	}
+	if dm.wind {
+		metadata = windMetadata(metadata)
+	}
+	dm.types = make(map[string]string)
+	for i, header := range metadata.Headers {
+		if i < len(metadata.Types) {
+			dm.types[header] = metadata.Types[i]
+		}
+	}
+	dm.metadata = metadata
 }
 
 // Header returns a Tsdata header paragraph string.
 func (dm *DataManager) Header() string {
+	if dm.flagged {
+		md := flagMetadata(dm.metadata)
+		return md.Header()
+	}
 	return dm.metadata.Header()
 }
 
-// AddValue adds a parsed value to the DataManager.
+// SetFlagColumns sets whether a QARTOD quality flag column is written after
+// each data column. When flag columns are on, parsers should keep records with
+// bad or suspect values and flag them rather than reject the record.
+func (dm *DataManager) SetFlagColumns(on bool) {
+	dm.flagged = on
+}
+
+// FlagColumns returns true if quality flag columns are written.
+func (dm *DataManager) FlagColumns() bool {
+	return dm.flagged
+}
+
+// flagMetadata returns a copy of metadata with an integer flag column
+// following each data column.
+func flagMetadata(metadata tsdata.Tsdata) tsdata.Tsdata {
+	md := metadata
+	md.Headers, md.Types, md.Units, md.Comments = nil, nil, nil, nil
+	for i, header := range metadata.Headers {
+		md.Headers = append(md.Headers, header)
+		md.Types = append(md.Types, index(metadata.Types, i))
+		md.Units = append(md.Units, index(metadata.Units, i))
+		md.Comments = append(md.Comments, index(metadata.Comments, i))
+		if header == "time" {
+			continue
+		}
+		md.Headers = append(md.Headers, header+"_flag")
+		md.Types = append(md.Types, "integer")
+		md.Units = append(md.Units, tsdata.NA)
+		md.Comments = append(md.Comments, "QARTOD flag for "+header+": 1 good, 3 suspect, 4 bad, 9 missing")
+	}
+	return md
+}
+
+// appendColumns returns a copy of metadata with columns appended.
+func appendColumns(metadata tsdata.Tsdata, headers, types, units, comments []string) tsdata.Tsdata {
+	md := metadata
+	md.Headers = append(append([]string{}, metadata.Headers...), headers...)
+	md.Types = append(append([]string{}, metadata.Types...), types...)
+	md.Units = append(append([]string{}, metadata.Units...), units...)
+	md.Comments = append(append([]string{}, metadata.Comments...), comments...)
+	return md
+}
+
+// AddValue adds a parsed value to the DataManager. value is converted to the
+// TSDATA type of column key. tsdata.NA is stored as a Missing value.
 func (dm *DataManager) AddValue(key, value string) {
-	dm.values[key] = value
+	dm.values[key] = NewValue(dm.types[key], value)
+	delete(dm.stamps, key)
+}
+
+// AddValueAt adds a parsed value measured at time t. Measurement times are
+// used by Merge to drop stale values and to hold values for later records.
+func (dm *DataManager) AddValueAt(key, value string, t time.Time) {
+	dm.AddValue(key, value)
+	dm.stamps[key] = t
+}
+
+// SetMaxAge sets the maximum age of values merged into a record by Merge. 0
+// turns off age checks and holding values between records.
+func (dm *DataManager) SetMaxAge(maxAge time.Duration) {
+	if maxAge < 0 {
+		maxAge = 0
+	}
+	dm.maxAge = maxAge
+}
+
+// MaxAge returns the maximum age of values merged into a record.
+func (dm *DataManager) MaxAge() time.Duration {
+	return dm.maxAge
+}
+
+// Merge prepares the current record for GetData when values from
+// asynchronous sensors arrive at different times. Every column except time
+// and required is filled, so that GetData returns a record as long as time
+// and required columns are present.
+//
+// With a max age set, values added with AddValueAt that are more than max age
+// from the record time are dropped, and columns without a value are filled
+// with the most recent value from an earlier record that is within max age.
+// Columns still without a value are set to tsdata.NA.
+func (dm *DataManager) Merge(required ...string) {
+	isRequired := func(k string) bool {
+		for _, r := range required {
+			if k == r {
+				return true
+			}
+		}
+		return false
+	}
+	fresh := func(stamp time.Time) bool {
+		if dm.maxAge == 0 || dm.t.IsZero() || stamp.IsZero() {
+			return true
+		}
+		age := dm.t.Sub(stamp)
+		if age < 0 {
+			age = -age
+		}
+		return age <= dm.maxAge
+	}
+	for _, k := range dm.metadata.Headers {
+		if k == "time" {
+			continue
+		}
+		if _, ok := dm.values[k]; ok {
+			if fresh(dm.stamps[k]) {
+				continue
+			}
+			delete(dm.values, k)
+			delete(dm.stamps, k)
+		}
+		if v, ok := dm.held[k]; ok && dm.maxAge > 0 && fresh(dm.heldAt[k]) {
+			dm.values[k] = v
+			dm.stamps[k] = dm.heldAt[k]
+			continue
+		}
+		if !isRequired(k) {
+			dm.AddValue(k, tsdata.NA)
+		}
+	}
+}
+
+// AddSuspect adds a value for column key that was parsed but may be wrong.
+func (dm *DataManager) AddSuspect(key, value string) {
+	dm.values[key] = SuspectValue(dm.types[key], value)
+	delete(dm.stamps, key)
 }
 
+// AddInvalid adds a value for column key that was present in the feed as text
+// but could not be parsed. It is written as tsdata.NA.
+func (dm *DataManager) AddInvalid(key, text string) {
+	dm.values[key] = InvalidValue(dm.types[key], text)
+	delete(dm.stamps, key)
+}
+
+// GetValue returns the output text for column key, and whether it has been
+// added.
 func (dm *DataManager) GetValue(key string) (string, bool) {
 	val, ok := dm.values[key]
-	return val, ok
+	return val.String(), ok
 }
 
 // AddError adds a parsing error to the DataManager.
@@ -79,18 +263,61 @@ func (dm *DataManager) GetData() (d Data) {
 	if allValuesPresent {
 		// Prepare complete Data struct
 		d.Time = dm.t
-		d.Values = make([]string, 0, len(dm.metadata.Headers)-1) // All columns except time
+		d.Values = make([]Value, 0, len(dm.metadata.Headers)-1) // All columns except time
 		for _, k := range dm.metadata.Headers {
 			if k != "time" {
 				d.Values = append(d.Values, dm.values[k])
 			}
 		}
 		d.Errors = dm.errors
+		d.Flagged = dm.flagged
 		dm.Limit(&d)
 		// Reset state after creating populated Data
-		dm.t = time.Time{}
-		dm.values = make(map[string]string)
-		dm.errors = []error{}
+		if dm.maxAge > 0 {
+			// Hold good values with measurement times for Merge
+			for k, v := range dm.values {
+				if stamp, ok := dm.stamps[k]; ok && v.OK() && !stamp.IsZero() {
+					dm.held[k] = v
+					dm.heldAt[k] = stamp
+				}
+			}
+		}
+		dm.reset()
 	}
 	return
 }
+
+// reset clears the time, values, and errors of the current record.
+func (dm *DataManager) reset() {
+	dm.t = time.Time{}
+	dm.values = make(map[string]Value)
+	dm.stamps = make(map[string]time.Time)
+	dm.errors = []error{}
+}
+
+// discard drops the current record and returns its errors, e.g. for a
+// malformed stanza.
+func (dm *DataManager) discard() []error {
+	errs := dm.errors
+	dm.reset()
+	return errs
+}
+
+// flagSuspect flags all good values in the current record as suspect, e.g.
+// when a malformed stanza is kept.
+func (dm *DataManager) flagSuspect() {
+	for k, v := range dm.values {
+		if v.Flag == FlagGood {
+			v.Flag = FlagSuspect
+			dm.values[k] = v
+		}
+	}
+}
+
+// index returns s[i], or "" if i is out of range.
+func index(s []string, i int) string {
+	if i < len(s) {
+		return s[i]
+	}
+	return ""
+}
@@ -26,6 +26,7 @@ func TestDataManager(t *testing.T) {
 			name: "complete data",
 			metadata: tsdata.Tsdata{
 				Headers: []string{"time", "lat", "lon"},
+				Types:   []string{"time", "float", "float"},
 			},
 			interval: 0,
 			timeVal:  now,
@@ -36,7 +37,7 @@ func TestDataManager(t *testing.T) {
 			errs: nil,
 			expectedData: Data{
 				Time:      now,
-				Values:    []string{"47.5", "-122.3"},
+				Values:    []Value{NewValue("float", "47.5"), NewValue("float", "-122.3")},
 				Errors:    nil,
 				Throttled: false,
 			},
@@ -45,6 +46,7 @@ func TestDataManager(t *testing.T) {
 			name: "missing time",
 			metadata: tsdata.Tsdata{
 				Headers: []string{"time", "lat", "lon"},
+				Types:   []string{"time", "float", "float"},
 			},
 			interval: 0,
 			timeVal:  time.Time{},
@@ -59,6 +61,7 @@ func TestDataManager(t *testing.T) {
 			name: "missing value",
 			metadata: tsdata.Tsdata{
 				Headers: []string{"time", "lat", "lon"},
+				Types:   []string{"time", "float", "float"},
 			},
 			interval: 0,
 			timeVal:  now,
@@ -72,6 +75,7 @@ func TestDataManager(t *testing.T) {
 			name: "with errors",
 			metadata: tsdata.Tsdata{
 				Headers: []string{"time", "lat", "lon"},
+				Types:   []string{"time", "float", "float"},
 			},
 			interval: 0,
 			timeVal:  now,
@@ -82,7 +86,7 @@ func TestDataManager(t *testing.T) {
 			errs: []error{errors.New("error 1"), errors.New("error 2")},
 			expectedData: Data{
 				Time:      now,
-				Values:    []string{"47.5", "-122.3"},
+				Values:    []Value{NewValue("float", "47.5"), NewValue("float", "-122.3")},
 				Errors:    []error{errors.New("error 1"), errors.New("error 2")},
 				Throttled: false,
 			},
@@ -149,3 +153,45 @@ func createDataManagerTest(t *testing.T, tt testDataManagerData) func(*testing.T
 		}
 	}
 }
+
+func TestDataManagerMerge(t *testing.T) {
+	assert := assert.New(t)
+	t0 := time.Date(2023, 10, 27, 10, 0, 0, 0, time.UTC)
+	metadata := tsdata.Tsdata{
+		Headers: []string{"time", "lat", "temp", "par"},
+		Types:   []string{"time", "float", "float", "float"},
+	}
+
+	// Without max age missing values are NA and required values are not filled
+	dm := NewDataManager(metadata, 0)
+	dm.SetTime(t0)
+	dm.AddValueAt("temp", "12.5", t0.Add(-time.Hour))
+	dm.Merge("lat")
+	assert.False(dm.GetData().OK())
+	dm.AddValue("lat", "47.5")
+	dm.Merge("lat")
+	assert.Equal([]string{"47.5", "12.5", "NA"}, dm.GetData().Strings())
+
+	dm = NewDataManager(metadata, 0)
+	dm.SetMaxAge(2 * time.Second)
+	dm.SetTime(t0)
+	dm.AddValue("lat", "47.5")
+	dm.AddValueAt("temp", "12.5", t0.Add(-time.Second))
+	dm.AddValueAt("par", "100.1", t0.Add(-3*time.Second))
+	dm.Merge("lat")
+	assert.Equal([]string{"47.5", "12.5", "NA"}, dm.GetData().Strings(), "stale par dropped")
+
+	// temp is held from the last record while within max age
+	dm.SetTime(t0.Add(time.Second))
+	dm.AddValue("lat", "47.6")
+	dm.Merge("lat")
+	assert.Equal([]string{"47.6", "12.5", "NA"}, dm.GetData().Strings(), "temp held")
+
+	dm.SetTime(t0.Add(2 * time.Second))
+	dm.Merge("lat")
+	assert.False(dm.GetData().OK(), "lat is required and not held without a stamp")
+
+	dm.AddValue("lat", "47.7")
+	dm.Merge("lat")
+	assert.Equal([]string{"47.7", "NA", "NA"}, dm.GetData().Strings(), "temp too old")
+}
@@ -11,7 +11,7 @@ import (
 func TestFilledData(t *testing.T) {
 	assert := assert.New(t)
 	t0, _ := time.Parse(time.RFC3339, "2019-08-21T00:00:00.5Z")
-	d := Data{Time: t0, Values: []string{"a", "b"}, Errors: []error{fmt.Errorf("e1"), fmt.Errorf("e2")}}
+	d := Data{Time: t0, Values: []Value{NewValue("text", "a"), NewValue("text", "b")}, Errors: []error{fmt.Errorf("e1"), fmt.Errorf("e2")}}
 	assert.Equal("::2019-08-21T00:00:00.5Z,a,b::e1,e2", d.String(), "filled Data.String()")
 	assert.Equal("2019-08-21T00:00:00.5Z,a,b", d.Line(","), "filled Data.Line(',')")
 	assert.True(d.OK(), "filled Data.OK() == true")
@@ -28,7 +28,7 @@ func TestEmptyData(t *testing.T) {
 func TestThrottledData(t *testing.T) {
 	assert := assert.New(t)
 	t0, _ := time.Parse(time.RFC3339, "2019-08-21T00:00:00.5Z")
-	d := Data{Time: t0, Values: []string{"a", "b"}, Errors: []error{fmt.Errorf("e1"), fmt.Errorf("e2")}, Throttled: true}
+	d := Data{Time: t0, Values: []Value{NewValue("text", "a"), NewValue("text", "b")}, Errors: []error{fmt.Errorf("e1"), fmt.Errorf("e2")}, Throttled: true}
 	assert.Equal("(T)::2019-08-21T00:00:00.5Z,a,b::e1,e2", d.String(), "throttled Data.String()")
 	assert.Equal("2019-08-21T00:00:00.5Z,a,b", d.Line(","), "throttled Data.Line()")
 	assert.False(d.OK(), "throttled Data.OK() == false")
@@ -0,0 +1,513 @@
+package parse
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ctberthiaume/cruisemic/geo"
+	"github.com/ctberthiaume/cruisemic/nmea"
+	"github.com/ctberthiaume/tsdata"
+	"gopkg.in/yaml.v3"
+)
+
+// DefinitionPrefix marks a -parser value as a path to a feed definition file,
+// e.g. "file:ship.yaml".
+const DefinitionPrefix = "file:"
+
+// FeedDefinition describes an underway feed declaratively. It is normally
+// loaded from a YAML or JSON file with LoadFeedDefinition.
+type FeedDefinition struct {
+	Description string `yaml:"description"`
+	FillNA      bool   `yaml:"fill_na"` // fill missing columns with NA when a record is closed
+	// RequireNewline, if false, also parses a final line without a trailing
+	// newline. The default is true.
+	RequireNewline *bool              `yaml:"require_newline"`
+	Lines          []LineDefinition   `yaml:"lines"`
+	Columns        []ColumnDefinition `yaml:"columns"`
+}
+
+// LineDefinition describes how to recognize and split one kind of feed line.
+type LineDefinition struct {
+	Name string `yaml:"name"`
+	// Prefix the trimmed line must start with.
+	Prefix string `yaml:"prefix"`
+	// Match requires the field at a reference to equal Equals.
+	Match  string `yaml:"match"`
+	Equals string `yaml:"equals"`
+	// Separators used to split the line, one per level of field reference.
+	// An empty separator splits on runs of whitespace. Without separators the
+	// whole line is field 0.
+	Separators []string `yaml:"separators"`
+	// Position, if > 0, matches only the Nth line after the last line with
+	// Starts set.
+	Position int `yaml:"position"`
+	// Fields is the required number of top level fields, 0 for any.
+	Fields int `yaml:"fields"`
+	// Counts maps a top level field index to its required subfield count.
+	Counts map[int]int `yaml:"counts"`
+	// Checksum marks the whole line as an NMEA sentence, and Sentences lists
+	// references to fields that are NMEA sentences. Their checksums are
+	// verified according to the parser's checksum policy.
+	Checksum  bool     `yaml:"checksum"`
+	Sentences []string `yaml:"sentences"`
+	// Starts closes any current record before this line is parsed.
+	Starts bool `yaml:"starts"`
+	// Ends closes the current record after this line is parsed.
+	Ends bool `yaml:"ends"`
+}
+
+// ColumnDefinition describes how to fill one TSDATA column from a feed line.
+// The first column must be named "time".
+type ColumnDefinition struct {
+	Name    string `yaml:"name"`
+	Type    string `yaml:"type"`
+	Unit    string `yaml:"unit"`
+	Comment string `yaml:"comment"`
+	// Line is the name of the LineDefinition this column is read from.
+	Line string `yaml:"line"`
+	// Fields are dot separated field references, e.g. "2.3" is the fourth
+	// subfield of the third top level field.
+	Fields []string `yaml:"fields"`
+	// Convert selects a conversion: float, integer, text, lat, lon. The
+	// default is based on Type.
+	Convert string `yaml:"convert"`
+	// Layout is a Go time layout for the time column. Referenced fields are
+	// joined with a single space before parsing. The time column uses the host
+	// clock if Clock is "host".
+	Layout string `yaml:"layout"`
+	Clock  string `yaml:"clock"`
+	// Decimals, if > 0, is the exact number of decimal places required.
+	Decimals int `yaml:"decimals"`
+	// OnError is "na" (default), "reject", or "flag". "flag" keeps numbers
+	// that parse but fail Decimals as suspect values when flag columns are
+	// written, and other bad values as NA. OnEmpty defaults to OnError.
+	OnError string `yaml:"on_error"`
+	OnEmpty string `yaml:"on_empty"`
+}
+
+// LoadFeedDefinition reads a FeedDefinition from a YAML or JSON file.
+func LoadFeedDefinition(path string) (def FeedDefinition, err error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return def, err
+	}
+	if err = yaml.Unmarshal(b, &def); err != nil {
+		return def, fmt.Errorf("bad feed definition %q: %v", path, err)
+	}
+	if err = def.Validate(); err != nil {
+		return def, fmt.Errorf("bad feed definition %q: %v", path, err)
+	}
+	return def, nil
+}
+
+// Validate checks a FeedDefinition for errors.
+func (def FeedDefinition) Validate() error {
+	if len(def.Lines) == 0 {
+		return fmt.Errorf("no lines defined")
+	}
+	if len(def.Columns) < 2 {
+		return fmt.Errorf("at least two columns are required")
+	}
+	if def.Columns[0].Name != "time" {
+		return fmt.Errorf("first column must be time")
+	}
+	lines := map[string]bool{}
+	for _, l := range def.Lines {
+		if l.Name == "" {
+			return fmt.Errorf("line without name")
+		}
+		if l.Prefix == "" && l.Match == "" && l.Position == 0 {
+			return fmt.Errorf("line %q: prefix, match or position is required", l.Name)
+		}
+		refs := l.Sentences
+		if l.Match != "" {
+			refs = append([]string{l.Match}, refs...)
+		}
+		for _, ref := range refs {
+			if _, err := parseFieldRef(ref); err != nil {
+				return fmt.Errorf("line %q: %v", l.Name, err)
+			}
+		}
+		lines[l.Name] = true
+	}
+	for i, c := range def.Columns {
+		if c.Name == "" {
+			return fmt.Errorf("column %d without name", i+1)
+		}
+		if i == 0 && c.Clock == "host" {
+			continue
+		}
+		if !lines[c.Line] {
+			return fmt.Errorf("column %q: unknown line %q", c.Name, c.Line)
+		}
+		if len(c.Fields) == 0 {
+			return fmt.Errorf("column %q: no fields", c.Name)
+		}
+		for _, f := range c.Fields {
+			if _, err := parseFieldRef(f); err != nil {
+				return fmt.Errorf("column %q: %v", c.Name, err)
+			}
+		}
+		if i == 0 {
+			if c.Layout == "" {
+				return fmt.Errorf("time column requires layout or clock: host")
+			}
+			continue
+		}
+		switch c.conversion() {
+		case "float", "integer", "text", "lat", "lon":
+		default:
+			return fmt.Errorf("column %q: bad conversion %q", c.Name, c.conversion())
+		}
+		for _, p := range []string{c.OnError, c.OnEmpty} {
+			if p != "" && p != "na" && p != "reject" && p != "flag" {
+				return fmt.Errorf("column %q: bad error policy %q", c.Name, p)
+			}
+		}
+	}
+	return nil
+}
+
+// Metadata creates the TSDATA metadata for this definition.
+func (def FeedDefinition) Metadata(project string) tsdata.Tsdata {
+	metadata := tsdata.Tsdata{
+		Project:         project,
+		FileType:        UnderwayName,
+		FileDescription: def.Description,
+	}
+	for _, c := range def.Columns {
+		typ, unit, comment := c.Type, c.Unit, c.Comment
+		if c.Name == "time" {
+			typ = "time"
+			if comment == "" {
+				comment = "RFC3339"
+			}
+		}
+		if typ == "" {
+			typ = "float"
+		}
+		if unit == "" {
+			unit = tsdata.NA
+		}
+		if comment == "" {
+			comment = c.Name
+		}
+		metadata.Comments = append(metadata.Comments, comment)
+		metadata.Types = append(metadata.Types, typ)
+		metadata.Units = append(metadata.Units, unit)
+		metadata.Headers = append(metadata.Headers, c.Name)
+	}
+	return metadata
+}
+
+func (c ColumnDefinition) conversion() string {
+	if c.Convert != "" {
+		return c.Convert
+	}
+	switch c.Type {
+	case "", "float":
+		return "float"
+	case "integer":
+		return "integer"
+	default:
+		return "text"
+	}
+}
+
+// DefinitionParser is a parser configured by a FeedDefinition.
+type DefinitionParser struct {
+	DataManager
+	def FeedDefinition
+	i   int // line number since the last starting line
+	now func() time.Time
+}
+
+// NewDefinitionParser returns a pointer to a DefinitionParser struct. def
+// should already be validated. project is the project or cruise name.
+// interval is the per-feed rate limiting interval in seconds.
+func NewDefinitionParser(def FeedDefinition, project string, interval time.Duration, now func() time.Time) Parser {
+	return &DefinitionParser{
+		DataManager: *NewDataManager(def.Metadata(project), interval),
+		def:         def,
+		now:         now,
+	}
+}
+
+// NewDefinitionParserFromFile loads a feed definition file and returns a
+// parser for it.
+func NewDefinitionParserFromFile(path string, project string, interval time.Duration, now func() time.Time) (Parser, error) {
+	def, err := LoadFeedDefinition(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewDefinitionParser(def, project, interval, now), nil
+}
+
+// HostClock returns true if the definition's time column uses the host clock.
+func (p *DefinitionParser) HostClock() bool {
+	return p.def.Columns[0].Clock == "host"
+}
+
+// ParseLine parses a single underway feed line. Only lines ending with \n are
+// examined, unless the definition sets require_newline to false.
+func (p *DefinitionParser) ParseLine(line string) (d Data) {
+	if len(line) == 0 {
+		return
+	}
+	if line[len(line)-1] == '\n' {
+		// Remove trailing \n for parsing
+		line = line[:len(line)-1]
+	} else if p.def.RequireNewline == nil || *p.def.RequireNewline {
+		return
+	}
+
+	// Trim leading and trailing whitespace
+	clean := strings.TrimSpace(line)
+
+	p.i++
+	hostClock := p.def.Columns[0].Clock == "host"
+	for _, ld := range p.def.Lines {
+		if ld.Position > 0 && ld.Position != p.i {
+			continue
+		}
+		fields, ok := ld.split(clean)
+		if !ok {
+			continue
+		}
+		if err := p.checkSentences(ld, clean, fields); err != nil {
+			p.AddError(fmt.Errorf("DefinitionParser: bad %s: %w: line=%q", ld.Name, err, clean))
+			return
+		}
+		if ld.Starts {
+			d = p.closeRecord()
+			p.i = 0
+		}
+		if hostClock && (ld.Starts || p.t.IsZero()) {
+			p.SetTime(p.now().UTC())
+		}
+		if err := p.parseColumns(ld, fields); err != nil {
+			p.AddError(fmt.Errorf("DefinitionParser: bad %s: %v: line=%q", ld.Name, err, clean))
+			return
+		}
+		if ld.Ends {
+			d = p.closeRecord()
+		}
+		return
+	}
+	return
+}
+
+// closeRecord returns the current record, filling missing values with NA if
+// requested by the definition.
+func (p *DefinitionParser) closeRecord() Data {
+	if p.def.FillNA {
+		for _, k := range p.metadata.Headers[1:] {
+			if _, ok := p.GetValue(k); !ok {
+				p.AddValue(k, tsdata.NA)
+			}
+		}
+	}
+	return p.GetData()
+}
+
+// checkSentences verifies NMEA checksums for line definition ld according to
+// the checksum policy.
+func (p *DefinitionParser) checkSentences(ld LineDefinition, line string, f field) error {
+	var texts []string
+	if ld.Checksum {
+		texts = append(texts, line)
+	}
+	for _, ref := range ld.Sentences {
+		r, _ := parseFieldRef(ref)
+		if s, ok := f.lookup(r); ok {
+			texts = append(texts, s)
+		}
+	}
+	for _, s := range texts {
+		sen, err := nmea.Parse(s)
+		if err != nil {
+			continue
+		}
+		if err := p.checkSentence(sen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseColumns adds values for all columns read from line definition ld. A
+// non-nil error means the line should be rejected.
+func (p *DefinitionParser) parseColumns(ld LineDefinition, f field) error {
+	for i, c := range p.def.Columns {
+		if c.Line != ld.Name {
+			continue
+		}
+		strs := make([]string, len(c.Fields))
+		empty := false
+		for j, ref := range c.Fields {
+			r, _ := parseFieldRef(ref)
+			s, ok := f.lookup(r)
+			if !ok || s == "" {
+				empty = true
+			}
+			strs[j] = s
+		}
+
+		if i == 0 {
+			if empty {
+				return fmt.Errorf("empty time")
+			}
+			t, err := time.Parse(c.Layout, strings.Join(strs, " "))
+			if err != nil {
+				return err
+			}
+			p.SetTime(t.UTC())
+			continue
+		}
+
+		policy := c.OnError
+		if empty {
+			if c.OnEmpty != "" {
+				policy = c.OnEmpty
+			}
+			if policy == "reject" {
+				return fmt.Errorf("empty %s", c.Name)
+			}
+			p.AddValue(c.Name, tsdata.NA)
+			continue
+		}
+
+		val, err := c.convert(strs)
+		if err != nil {
+			if policy == "reject" {
+				return fmt.Errorf("%s: %v", c.Name, err)
+			}
+			p.AddError(fmt.Errorf("DefinitionParser: bad %s: %v", c.Name, err))
+			if _, ferr := strconv.ParseFloat(strs[0], 64); policy == "flag" && c.conversion() == "float" && ferr == nil && p.FlagColumns() {
+				p.AddSuspect(c.Name, strs[0])
+			} else {
+				p.AddInvalid(c.Name, strings.Join(strs, " "))
+			}
+			continue
+		}
+		p.AddValue(c.Name, val)
+	}
+	return nil
+}
+
+// convert converts field strings to a column value.
+func (c ColumnDefinition) convert(strs []string) (string, error) {
+	s := strs[0]
+	switch c.conversion() {
+	case "float":
+		if _, err := strconv.ParseFloat(s, 64); err != nil {
+			return "", err
+		}
+		if c.Decimals > 0 {
+			parts := strings.Split(s, ".")
+			if len(parts) != 2 || len(parts[1]) != c.Decimals {
+				return "", fmt.Errorf("expected %d decimal places: %q", c.Decimals, s)
+			}
+		}
+		return s, nil
+	case "integer":
+		if _, err := strconv.ParseInt(s, 10, 64); err != nil {
+			return "", err
+		}
+		return s, nil
+	case "lat", "lon":
+		// Hemisphere is either the second field or the last character
+		coord, hemi := s, ""
+		if len(strs) > 1 {
+			hemi = strs[1]
+		} else if len(s) > 1 {
+			coord, hemi = s[:len(s)-1], s[len(s)-1:]
+		}
+		if c.conversion() == "lat" {
+			return geo.GGALat2DD(coord, hemi)
+		}
+		return geo.GGALon2DD(coord, hemi)
+	default:
+		return s, nil
+	}
+}
+
+// split returns the line split into nested fields if it matches this line
+// definition.
+func (ld LineDefinition) split(line string) (field, bool) {
+	if !strings.HasPrefix(line, ld.Prefix) {
+		return field{}, false
+	}
+	f := splitField(line, ld.Separators)
+	if len(ld.Separators) == 0 {
+		// An unsplit line is the single field 0
+		f.sub = []field{{text: f.text}}
+	}
+	if ld.Fields > 0 && len(f.sub) != ld.Fields {
+		return field{}, false
+	}
+	if ld.Match != "" {
+		r, _ := parseFieldRef(ld.Match)
+		if s, ok := f.lookup(r); !ok || s != ld.Equals {
+			return field{}, false
+		}
+	}
+	for i, n := range ld.Counts {
+		if i < 0 || i >= len(f.sub) || len(f.sub[i].sub) != n {
+			return field{}, false
+		}
+	}
+	return f, true
+}
+
+// field is a node in a line split by nested separators.
+type field struct {
+	text string  // trimmed text of this field
+	sub  []field // subfields split by the next separator
+}
+
+// splitField splits s recursively by each separator in seps.
+func splitField(s string, seps []string) field {
+	f := field{text: strings.TrimSpace(s)}
+	if len(seps) == 0 {
+		return f
+	}
+	var parts []string
+	if seps[0] == "" {
+		parts = strings.Fields(s)
+	} else {
+		parts = strings.Split(s, seps[0])
+	}
+	f.sub = make([]field, len(parts))
+	for i, p := range parts {
+		f.sub[i] = splitField(p, seps[1:])
+	}
+	return f
+}
+
+// lookup returns the text at field reference r.
+func (f field) lookup(r []int) (string, bool) {
+	for _, i := range r {
+		if i >= len(f.sub) {
+			return "", false
+		}
+		f = f.sub[i]
+	}
+	return f.text, true
+}
+
+// parseFieldRef parses a dot separated field reference such as "2.3".
+func parseFieldRef(ref string) ([]int, error) {
+	var r []int
+	for _, part := range strings.Split(ref, ".") {
+		i, err := strconv.Atoi(part)
+		if err != nil || i < 0 {
+			return nil, fmt.Errorf("bad field reference %q", ref)
+		}
+		r = append(r, i)
+	}
+	return r, nil
+}
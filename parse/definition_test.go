@@ -0,0 +1,179 @@
+package parse
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ctberthiaume/cruisemic/storage"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+type testDefinitionParityData struct {
+	name       string
+	definition string
+	builtin    string
+}
+
+// Lines that exercise good, partial, and rejected SEAFLOW records.
+var seaflowParityInput = `$SEAFLOW::$GPZDA,213218.00,31,10,2023,00,00*6D::$GPGGA,213218.00,4737.578758,N,12222.827136,W,2,15,0.8,12.181,M,-22.0,M,4.0,0402*4F:: 15.0526,  3.78840,  30.4126, 1501.506::
+$SEAFLOW::$GPZDA,213309.00,12,01,2023,00,00*6D::$GPGGA,213309.00,4738.983141,N,12218.805824,W,2,17,0.7,15.773,M,-22.2,M,7.0,0402*44:: 12.3719,  3.64868,  31.2816::157.580
+$SEAFLOW::$GPZDA,213310.00,12,01,2023,00,00*6D::$GPGGA,213310.00,4738.983141,N,12218.805824,W,2,17,0.7,15.773,M,-22.2,M,7.0,0402*44:: 12.3719,  3.64868,  31.2816::$PPAR, 157.581, 6.10, 5
+$SEAFLOW::$GPZDA,213311.00,12,01,2023,00,00*6D::$GPGGA,213311.00,4738.983141,N,12218.805824,W,2,17,0.7,15.773,M,-22.2,M,7.0,0402*44::::$PPAR, 157.58
+$SEAFLOW::$GPZDA,213312.00,12,01,2023,00,00*6D::$GPGGA,213312.00,4738.983141,N,12218.805824,W,2,17,0.7,15.773,M,-22.2,M,7.0,0402*44::::157.58
+$SEAFLOW::$GPZDA,213313.00,12,01,2023,00,00*6D::$GPGGA,213313.00,4738.983141,N,12218.805824,W,2,17,0.7,15.773,M,-22.2,M,7.0,0402*44:: 12.371a9,  3.64868,  31.2816::1.000
+$SEAFLOW::$GPZDA,213314.00,12,01,2023,00,00*6D::$GPGGA,213314.00,47a38.983141,N,12218.805824,W,2,17,0.7,15.773,M,-22.2,M,7.0,0402*44::::157.580
+$SEAFLOW::$GPZDA,213315.00,12,01,2023,00,00*6D::$GPGGA,213315.00,4738.983141,N,12218.805824,W,2,17,0.7,15.773,M,X,-22.2,M,7.0,0402*44::::157.580
+$SEAFLOW::$GPZDA,21a316.00,12,01,2023,00,00*6D::$GPGGA,213316.00,4738.983141,N,12218.805824,W,2,17,0.7,15.773,M,-22.2,M,7.0,0402*44::::157.580
+$SEAFLOW::$GPZDA,213317.001,12,01,2023,00,00*6D::$GPGGA,213317.00,4738.983141,N,12218.805824,W,2,17,0.7,15.773,M,-22.2,M,7.0,0402*44::::157.580
+$SEAFLOW::$GPZDA,213318.00,12,01,2023,00,00*6D::::157.580
+$SEAFLOW::$GNZDA,192824.00,08,01,2026,00,00*73::$GNGGA,192824.00,0959.090566,N,13112.849121,E,5,18,0.62,72.764,M,0.000,M,75,0000*43:: 29.6849,  5.64749,  33.9515, 1543.859::-0.005
+`
+
+func TestDefinitionParity(t *testing.T) {
+	testData := []testDefinitionParityData{
+		{"TN427", "../example-feeds/TN427/TN427.yaml", "TN427"},
+		{"TN448", "../example-feeds/TN450/TN448.yaml", "TN448"},
+		{"Gradients5", "../example-feeds/Gradients 5/Gradients5.yaml", "Gradients5"},
+	}
+	for _, tt := range testData {
+		t.Run(tt.name, createDefinitionParityTest(t, tt))
+	}
+}
+
+func createDefinitionParityTest(t *testing.T, tt testDefinitionParityData) func(*testing.T) {
+	assert := assert.New(t)
+
+	return func(t *testing.T) {
+		p, err := NewDefinitionParserFromFile(tt.definition, "test", 0, time.Now)
+		assert.Nil(err, "loading definition for test: "+tt.name)
+		if err != nil {
+			return
+		}
+		builtin := ParserRegistry[tt.builtin]("test", 0, time.Now)
+		assert.Equal(builtin.Header(), p.Header(), tt.name)
+
+		store, _ := storage.NewMemStorage()
+		err = ParseLines(p, strings.NewReader(seaflowParityInput), store, true, false)
+		assert.Nil(err, "writing for test: "+tt.name)
+		builtinStore, _ := storage.NewMemStorage()
+		err = ParseLines(builtin, strings.NewReader(seaflowParityInput), builtinStore, true, false)
+		assert.Nil(err, "writing for test: "+tt.name)
+
+		assert.NotEmpty(store.Feeds, tt.name)
+		assert.Equal(builtinStore.Feeds, store.Feeds, tt.name)
+
+		// Without a final newline
+		p, _ = NewDefinitionParserFromFile(tt.definition, "test", 0, time.Now)
+		builtin = ParserRegistry[tt.builtin]("test", 0, time.Now)
+		unterminated := strings.TrimSuffix(seaflowParityInput, "\n")
+		store, _ = storage.NewMemStorage()
+		err = ParseLines(p, strings.NewReader(unterminated), store, true, false)
+		assert.Nil(err, "writing for test: "+tt.name)
+		builtinStore, _ = storage.NewMemStorage()
+		err = ParseLines(builtin, strings.NewReader(unterminated), builtinStore, true, false)
+		assert.Nil(err, "writing for test: "+tt.name)
+		assert.Equal(builtinStore.Feeds, store.Feeds, tt.name+" without final newline")
+	}
+}
+
+func TestDefinitionRequireNewline(t *testing.T) {
+	assert := assert.New(t)
+	input := strings.TrimSuffix(tn448GoodLine, "\n")
+	for _, tt := range []struct {
+		path    string
+		records int
+	}{
+		{"../example-feeds/TN427/TN427.yaml", 0},
+		{"../example-feeds/TN450/TN448.yaml", 1},
+	} {
+		p, err := NewDefinitionParserFromFile(tt.path, "test", 0, time.Now)
+		assert.Nil(err)
+		store, _ := storage.NewMemStorage()
+		assert.Nil(ParseLines(p, strings.NewReader(input), store, true, false))
+		assert.Len(store.Feeds["geo"], tt.records, tt.path)
+	}
+}
+
+func TestDefinitionStanza(t *testing.T) {
+	assert := assert.New(t)
+	t0 := time.Date(2022, 5, 27, 0, 0, 0, 0, time.UTC)
+	now := func() time.Time {
+		t := t0
+		t0 = t0.Add(time.Second)
+		return t
+	}
+	def := FeedDefinition{}
+	err := yaml.Unmarshal([]byte(`
+description: positional stanza feed
+lines:
+  - {name: start, match: "0", equals: "$SEAFLOW", separators: [""], starts: true}
+  - {name: lat, position: 1}
+  - {name: lon, position: 2}
+  - {name: temp, position: 3}
+columns:
+  - {name: time, clock: host}
+  - {name: lat, unit: deg, line: lat, fields: ["0"], convert: lat, on_error: reject}
+  - {name: lon, unit: deg, line: lon, fields: ["0"], convert: lon, on_error: reject}
+  - {name: temp, unit: C, line: temp, fields: ["0"]}
+`), &def)
+	assert.Nil(err)
+	assert.Nil(def.Validate())
+
+	p := NewDefinitionParser(def, "test", 0, now)
+	store, _ := storage.NewMemStorage()
+	input := `$SEAFLOW
+2118.9043N
+15752.6526W
+26.8
+$SEAFLOWWWW
+2118.9043N
+15752.6526W
+26.9
+$SEAFLOW
+2118.9043N
+15752.6526W
+2a6.8
+$SEAFLOW
+`
+	err = ParseLines(p, strings.NewReader(input), store, true, false)
+	assert.Nil(err)
+	assert.Equal(map[string][]string{
+		"geo": {
+			"2022-05-27T00:00:00Z\t21.3151\t-157.8775\t26.8\n",
+			"2022-05-27T00:00:01Z\t21.3151\t-157.8775\tNA\n",
+		},
+	}, store.Feeds)
+}
+
+func TestDefinitionValidate(t *testing.T) {
+	assert := assert.New(t)
+	good := FeedDefinition{
+		Lines: []LineDefinition{{Name: "a", Prefix: "$A", Separators: []string{","}}},
+		Columns: []ColumnDefinition{
+			{Name: "time", Line: "a", Fields: []string{"1"}, Layout: "150405"},
+			{Name: "x", Line: "a", Fields: []string{"2"}},
+		},
+	}
+	assert.Nil(good.Validate())
+
+	bad := good
+	bad.Columns = []ColumnDefinition{good.Columns[1], good.Columns[0]}
+	assert.NotNil(bad.Validate(), "time must be first")
+
+	bad = good
+	bad.Columns = []ColumnDefinition{good.Columns[0], {Name: "x", Line: "b", Fields: []string{"2"}}}
+	assert.NotNil(bad.Validate(), "unknown line")
+
+	bad = good
+	bad.Columns = []ColumnDefinition{good.Columns[0], {Name: "x", Line: "a", Fields: []string{"2.a"}}}
+	assert.NotNil(bad.Validate(), "bad field reference")
+
+	bad = good
+	bad.Columns = []ColumnDefinition{good.Columns[0], {Name: "x", Line: "a", Fields: []string{"2"}, OnError: "drop"}}
+	assert.NotNil(bad.Validate(), "bad error policy")
+
+	_, err := LoadFeedDefinition("does-not-exist.yaml")
+	assert.NotNil(err)
+}
@@ -0,0 +1,119 @@
+package parse
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ctberthiaume/cruisemic/nmea"
+	"github.com/ctberthiaume/tsdata"
+)
+
+// DepthSetter is implemented by parsers that can write a depth column from
+// DBT, DPT, Kongsberg PSKPDPT, and Knudsen PKEL99 echosounder sentences in
+// their feed. offset is the transducer depth below the waterline in meters,
+// added to depths below the transducer. The depth column is added after all
+// other columns.
+type DepthSetter interface {
+	SetDepth(on bool, offset float64)
+}
+
+// depthConfig is the depth configuration of a DataManager.
+type depthConfig struct {
+	on     bool    // write depth column
+	offset float64 // transducer depth below waterline, meters
+}
+
+// setDepth sets whether the depth column is written and the transducer
+// offset.
+func (dm *DataManager) setDepth(on bool, offset float64) {
+	dm.depth = depthConfig{on: on, offset: offset}
+	dm.SetMetadata(dm.base)
+}
+
+// depthMetadata returns a copy of metadata with a depth column appended.
+func depthMetadata(metadata tsdata.Tsdata) tsdata.Tsdata {
+	return appendColumns(metadata, []string{"depth"}, []string{"float"}, []string{"m"},
+		[]string{"Bottom depth below waterline from echosounder depth below transducer plus transducer offset"})
+}
+
+// addDepth adds depth from sen if it's a DBT, DPT, PSKPDPT, or PKEL99
+// sentence and the depth column is on. t is the measurement time for Merge.
+// It returns false if sen was not used. The checksum is checked according to
+// the checksum policy. Offset fields in DPT and PSKPDPT sentences are ignored
+// so that all sentences use the configured transducer offset. PKEL99 depth is
+// from the high frequency channel, or the low frequency channel if only it
+// detected the bottom.
+func (dm *DataManager) addDepth(sen nmea.Sentence, t time.Time) (bool, error) {
+	if !dm.depth.on {
+		return false, nil
+	}
+	switch {
+	case sen.Type == "DBT", sen.Type == "DPT":
+	case sen.Talker == "P" && (sen.Type == "SKPDPT" || sen.Type == "KEL99"):
+	default:
+		return false, nil
+	}
+	if err := dm.checkSentence(sen); err != nil {
+		return true, err
+	}
+	var depth nmea.Float
+	switch sen.Type {
+	case "DBT":
+		dbt, err := nmea.ParseDBT(sen)
+		if err != nil {
+			return true, err
+		}
+		depth = dbt.Meters
+		if meters, ok := dbt.DepthMeters(); ok && !depth.Valid {
+			// Converted from feet or fathoms, keep centimeters
+			depth = nmea.Float{Value: meters, Text: strconv.FormatFloat(meters, 'f', 2, 64), Valid: true}
+		}
+	case "DPT":
+		dpt, err := nmea.ParseDPT(sen)
+		if err != nil {
+			return true, err
+		}
+		depth = dpt.Depth
+	case "SKPDPT":
+		skpdpt, err := nmea.ParsePSKPDPT(sen)
+		if err != nil {
+			return true, err
+		}
+		depth = skpdpt.Depth
+	case "KEL99":
+		kel99, err := nmea.ParsePKEL99(sen)
+		if err != nil {
+			return true, err
+		}
+		depth, _ = kel99.Depth()
+	}
+	if !depth.Valid {
+		// No bottom detected, don't replace an earlier depth
+		if _, ok := dm.values["depth"]; !ok {
+			dm.AddValueAt("depth", tsdata.NA, t)
+		}
+		return true, nil
+	}
+	dm.AddValueAt("depth", offsetText(depth, dm.depth.offset), t)
+	return true, nil
+}
+
+// offsetText returns the text for f plus offset, with as many decimal places
+// as the more precise of f and offset.
+func offsetText(f nmea.Float, offset float64) string {
+	if offset == 0 {
+		return f.Text
+	}
+	decimals := func(s string) int {
+		if i := strings.IndexByte(s, '.'); i >= 0 {
+			return len(s) - i - 1
+		}
+		return 0
+	}
+	prec := decimals(f.Text)
+	if d := decimals(strconv.FormatFloat(offset, 'f', -1, 64)); d > prec {
+		prec = d
+	}
+	return strconv.FormatFloat(f.Value+offset, 'f', prec, 64)
+}
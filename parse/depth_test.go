@@ -0,0 +1,91 @@
+package parse
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ctberthiaume/cruisemic/nmea"
+	"github.com/ctberthiaume/cruisemic/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOffsetText(t *testing.T) {
+	assert := assert.New(t)
+	f := nmea.Float{Value: 4632.1, Text: "4632.1", Valid: true}
+	assert.Equal("4632.1", offsetText(f, 0))
+	assert.Equal("4639.0", offsetText(f, 6.9))
+	assert.Equal("4638.35", offsetText(f, 6.25))
+	assert.Equal("4626.1", offsetText(f, -6))
+}
+
+func TestKMDepth(t *testing.T) {
+	assert := assert.New(t)
+	input := `2017 168 00 30 28 990 bar1   1016.07 mbar
+$GPGGA,003029.00,2118.9043,N,15752.6526,W,2,7,0.8,27,M,,M,,*78
+$SDDPT,4632.1,0.0*00
+$PSKPDPT,4633.10,6.90,12000,10,1,EK80*00
+2017 168 00 30 29 990 bar1   1016.05 mbar
+$GPGGA,003029.00,2118.9043,N,15752.6526,W,2,7,0.8,27,M,,M,,*78
+$SDDBT,100.0,f,,M,,F*00
+2017 168 00 30 30 990 bar1   1016.05 mbar
+$GPGGA,003029.00,2118.9043,N,15752.6526,W,2,7,0.8,27,M,,M,,*78
+$SDDBT,,f,,M,,F*00
+$SDDPT,46a32.1,0.0*00
+2017 168 00 30 31 990 bar1   1016.05 mbar
+`
+	p := NewKiloMoanaParser("test", 0, time.Now).(*KiloMoanaParser)
+	p.SetDepth(true, 5)
+	assert.Contains(p.Header(), "lon\tdepth")
+	store, _ := storage.NewMemStorage()
+	err := ParseLines(p, strings.NewReader(input), store, true, false)
+	assert.Nil(err)
+	na := "NA\tNA\tNA\tNA\tNA\tNA\tNA\tNA"
+	assert.Equal(map[string][]string{
+		"geo": {
+			"2017-06-17T00:30:28.99Z\t" + na + "\t21.3151\t-157.8775\t4638.10\n",
+			"2017-06-17T00:30:29.99Z\t" + na + "\t21.3151\t-157.8775\t35.48\n",
+			"2017-06-17T00:30:30.99Z\t" + na + "\t21.3151\t-157.8775\tNA\n",
+		},
+	}, store.Feeds)
+}
+
+func TestTARADepth(t *testing.T) {
+	assert := assert.New(t)
+	input := `$SDDBT,15197.2,f,4632.1,M,2532.9,F*00
+$GPRMC,160332,A,4743.7694,N,00322.4405,W,0.0,182.6,071225,0.2,W,D*19
+`
+	p := NewTARAParser("test", 0, time.Now).(*TARAParser)
+	p.SetAttitude(true)
+	p.SetDepth(true, 0)
+	assert.Contains(p.Header(), "par\theading\tpitch\troll\trate_of_turn\tdepth")
+	store, _ := storage.NewMemStorage()
+	err := ParseLines(p, strings.NewReader(input), store, true, false)
+	assert.Nil(err)
+	assert.Equal(map[string][]string{
+		"geo": {"2025-12-07T16:03:32Z\t47.7295\t-3.3740\tNA\tNA\tNA\tNA\tNA\tNA\tNA\tNA\t4632.1\n"},
+	}, store.Feeds)
+}
+
+func TestSeaflowDepth(t *testing.T) {
+	assert := assert.New(t)
+	input := `$PKEL99,3.5,4633.52,1,12.0,4632.10,1,1500*00
+$SEAFLOW::$GPZDA,213309.00,12,01,2023,00,00*6D::$GPGGA,213309.00,4738.983141,N,12218.805824,W,2,17,0.7,15.773,M,-22.2,M,7.0,0402*44:: 12.3719,  3.64868,  31.2816::157.580
+$PKEL99,3.5,4633.52,1,12.0,,0,1500*00
+$SEAFLOW::$GPZDA,213310.00,12,01,2023,00,00*65::$GPGGA,213310.00,4738.983143,N,12218.805821,W,2,17,0.7,15.774,M,-22.2,M,8.0,0402*43:: 12.3719,  3.64868,  31.2816::157.580
+$SEAFLOW::$GPZDA,213311.00,12,01,2023,00,00*64::$GPGGA,213311.00,4738.983147,N,12218.805822,W,2,17,0.7,15.776,M,-22.2,M,5.0,0402*4A:: 12.3719,  3.64868,  31.2816::157.580
+`
+	p := NewTN427Parser("test", 0, time.Now).(*TN427Parser)
+	p.SetDepth(true, 5)
+	assert.Contains(p.Header(), "par\tdepth")
+	store, _ := storage.NewMemStorage()
+	err := ParseLines(p, strings.NewReader(input), store, true, false)
+	assert.Nil(err)
+	assert.Equal(map[string][]string{
+		"geo": {
+			"2023-01-12T21:33:09Z\t47.6497\t-122.3134\t12.3719\t3.64868\t31.2816\t157.580\t4637.10\n",
+			"2023-01-12T21:33:10Z\t47.6497\t-122.3134\t12.3719\t3.64868\t31.2816\t157.580\t4638.52\n",
+			"2023-01-12T21:33:11Z\t47.6497\t-122.3134\t12.3719\t3.64868\t31.2816\t157.580\tNA\n",
+		},
+	}, store.Feeds)
+}
@@ -0,0 +1,122 @@
+package parse
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AutoParser is the -parser value that selects a parser with Detect.
+const AutoParser = "auto"
+
+// Candidate is a registered parser ranked by Detect.
+type Candidate struct {
+	Name    string
+	Records int // valid records parsed
+	Errors  int // errors in valid records
+}
+
+func (c Candidate) String() string {
+	return fmt.Sprintf("%s\trecords=%d\terrors=%d", c.Name, c.Records, c.Errors)
+}
+
+// Detect runs every registered parser, except those with NoDetect set,
+// against the lines in r and returns candidates ranked by the most valid
+// records, then the fewest errors, then by name.
+func Detect(r io.Reader, noCleanFlag bool) ([]Candidate, error) {
+	lines, err := scanLines(r, noCleanFlag)
+	if err != nil {
+		return nil, err
+	}
+	var candidates []Candidate
+	for _, reg := range Registrations() {
+		if reg.NoDetect {
+			continue
+		}
+		candidates = append(candidates, scoreParser(reg.Name, reg.New, lines))
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.Records != b.Records {
+			return a.Records > b.Records
+		}
+		if a.Errors != b.Errors {
+			return a.Errors < b.Errors
+		}
+		return a.Name < b.Name
+	})
+	return candidates, nil
+}
+
+// scanLines reads all lines from r as ParseLines would pass them to a parser.
+func scanLines(r io.Reader, noCleanFlag bool) (lines []string, err error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Split(scanLinesWithLF)
+	for scanner.Scan() {
+		b := scanner.Bytes()
+		n := len(b)
+		if !noCleanFlag {
+			// Remove unwanted ASCII characters
+			n = Whitelist(b, n)
+		}
+		lines = append(lines, string(b[:n]))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading lines: %v", err)
+	}
+	return lines, nil
+}
+
+// scoreParser counts valid records and their errors for a new parser run
+// against lines.
+func scoreParser(name string, constructor Constructor, lines []string) Candidate {
+	// Use a fake clock for parsers that timestamp with the host clock, so
+	// that scores don't depend on how fast lines are read.
+	t := time.Now()
+	now := func() time.Time {
+		t = t.Add(time.Second)
+		return t
+	}
+	parser := constructor("detect", 0, now)
+	c := Candidate{Name: name}
+	for _, line := range lines {
+		d := parser.ParseLine(line)
+		if d.OK() {
+			c.Records++
+			c.Errors += len(d.Errors)
+		}
+	}
+	return c
+}
+
+// DetectParser returns the name of the best parser for the lines in r, along
+// with all ranked candidates. An error is returned if no parser produced a
+// valid record, or if the best parsers tie.
+func DetectParser(r io.Reader, noCleanFlag bool) (string, []Candidate, error) {
+	candidates, err := Detect(r, noCleanFlag)
+	if err != nil {
+		return "", candidates, err
+	}
+	if len(candidates) == 0 || candidates[0].Records == 0 {
+		return "", candidates, fmt.Errorf("no parser produced a valid record")
+	}
+	if ties := Ties(candidates); len(ties) > 1 {
+		return "", candidates, fmt.Errorf("parsers tied: %s, choose one with -parser", strings.Join(ties, ", "))
+	}
+	return candidates[0].Name, candidates, nil
+}
+
+// Ties returns the names of candidates ranked equal to the first candidate,
+// including the first candidate.
+func Ties(candidates []Candidate) (names []string) {
+	for _, c := range candidates {
+		if c.Records != candidates[0].Records || c.Errors != candidates[0].Errors {
+			break
+		}
+		names = append(names, c.Name)
+	}
+	return names
+}
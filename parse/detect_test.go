@@ -0,0 +1,76 @@
+package parse
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testDetectData struct {
+	name     string
+	path     string
+	expected string
+}
+
+func TestDetect(t *testing.T) {
+	testData := []testDetectData{
+		{"Gradients4", "../example-feeds/Gradients 4/gradients4-feed.txt", "Gradients4"},
+		{"Kilo Moana", "../example-feeds/Kilo-Moana/Kilo-Moana-underway-feed-example.crlf.onestanza.txt", "Kilo Moana"},
+		{"TARA", "../example-feeds/TARA2026/TARA2026-feed.txt", "TARA"},
+		// TN427 parses these lines too, but with an error for the GN talker
+		{"TN450", "../example-feeds/TN450/TN450-feed.txt", "TN448"},
+	}
+	for _, tt := range testData {
+		t.Run(tt.name, createDetectTest(t, tt))
+	}
+}
+
+// detectable returns the number of registered parsers used by Detect.
+func detectable() (n int) {
+	for _, r := range Registrations() {
+		if !r.NoDetect {
+			n++
+		}
+	}
+	return n
+}
+
+func createDetectTest(t *testing.T, tt testDetectData) func(*testing.T) {
+	assert := assert.New(t)
+
+	return func(t *testing.T) {
+		f, err := os.Open(tt.path)
+		if !assert.Nil(err, tt.name) {
+			return
+		}
+		defer f.Close()
+		name, candidates, err := DetectParser(f, false)
+		assert.Nil(err, tt.name)
+		assert.Equal(tt.expected, name, tt.name)
+		assert.Len(candidates, detectable(), tt.name)
+	}
+}
+
+func TestDetectTies(t *testing.T) {
+	assert := assert.New(t)
+	candidates := []Candidate{{"A", 2, 0}, {"B", 2, 0}, {"C", 2, 1}}
+	assert.Equal([]string{"A", "B"}, Ties(candidates))
+	assert.Equal([]string{"B"}, Ties(candidates[1:]))
+
+	// The GNSS talker ID tells TN427 and TN448 apart
+	name, candidates, err := DetectParser(strings.NewReader(tn448GoodLine), false)
+	assert.Nil(err)
+	assert.Equal("TN448", name)
+	assert.Equal([]string{"TN448"}, Ties(candidates))
+}
+
+func TestDetectNoMatch(t *testing.T) {
+	assert := assert.New(t)
+	_, candidates, err := DetectParser(strings.NewReader("hello\nworld\n"), false)
+	assert.NotNil(err)
+	assert.Len(candidates, detectable())
+	_, _, err = DetectParser(strings.NewReader(""), false)
+	assert.NotNil(err)
+}
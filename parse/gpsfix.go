@@ -0,0 +1,174 @@
+package parse
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ctberthiaume/cruisemic/nmea"
+	"github.com/ctberthiaume/tsdata"
+)
+
+// FixPolicy selects how parsers handle records with a poor GPS fix: GGA fix
+// quality 0, RMC status V, or GGA HDOP above a maximum.
+type FixPolicy int
+
+const (
+	// FixKeep keeps records without checking the fix.
+	FixKeep FixPolicy = iota
+	// FixFlag keeps records, flags lat and lon as bad for an invalid fix or
+	// suspect for high HDOP, and adds an error to the parsing errors.
+	FixFlag
+	// FixDrop drops lat and lon for a poor fix, so that no record is written
+	// for the fix, and adds an error to the parsing errors.
+	FixDrop
+)
+
+// FixPolicyNames maps command-line names to GPS fix policies.
+var FixPolicyNames = map[string]FixPolicy{
+	"keep": FixKeep,
+	"flag": FixFlag,
+	"drop": FixDrop,
+}
+
+// ParseFixPolicy returns the FixPolicy for a command-line name.
+func ParseFixPolicy(name string) (FixPolicy, error) {
+	policy, ok := FixPolicyNames[name]
+	if !ok {
+		return FixKeep, fmt.Errorf("bad GPS fix policy %q, must be one of keep, flag, drop", name)
+	}
+	return policy, nil
+}
+
+// GPSFixSetter is implemented by parsers that read positions from GGA or RMC
+// sentences. columns adds GPS fix columns after all other columns: fix_quality,
+// satellites, hdop, and altitude for GGA, or fix_status for RMC. maxHDOP is
+// the HDOP above which a fix is poor, 0 for no HDOP check.
+type GPSFixSetter interface {
+	SetGPSFix(columns bool, policy FixPolicy, maxHDOP float64)
+}
+
+// gpsFix is the GPS fix configuration of a DataManager.
+type gpsFix struct {
+	source  string // sentence type with fix information, GGA or RMC
+	columns bool   // write GPS fix columns
+	policy  FixPolicy
+	maxHDOP float64 // 0 for no HDOP check
+}
+
+// ggaFixColumns and rmcFixColumns are the GPS fix column names for each
+// source sentence type.
+var ggaFixColumns = []string{"fix_quality", "satellites", "hdop", "altitude"}
+var rmcFixColumns = []string{"fix_status"}
+
+// setGPSFix sets the GPS fix configuration for positions from source
+// sentences, GGA or RMC.
+func (dm *DataManager) setGPSFix(source string, columns bool, policy FixPolicy, maxHDOP float64) {
+	if maxHDOP < 0 {
+		maxHDOP = 0
+	}
+	dm.fix = gpsFix{source: source, columns: columns, policy: policy, maxHDOP: maxHDOP}
+	dm.SetMetadata(dm.base)
+}
+
+// gpsFixMetadata returns a copy of metadata with GPS fix columns for source
+// sentences appended.
+func gpsFixMetadata(metadata tsdata.Tsdata, source string) tsdata.Tsdata {
+	switch source {
+	case "GGA":
+		return appendColumns(metadata, ggaFixColumns,
+			[]string{"integer", "integer", "float", "float"},
+			[]string{tsdata.NA, tsdata.NA, tsdata.NA, "m"},
+			[]string{"GGA fix quality: 0 invalid, 1 GPS, 2 DGPS, 4 RTK fixed, 5 RTK float, 6 dead reckoning", "GGA satellites in use", "GGA horizontal dilution of precision", "GGA antenna altitude above mean sea level"})
+	case "RMC":
+		return appendColumns(metadata, rmcFixColumns, []string{"category"}, []string{tsdata.NA}, []string{"RMC status: A valid, V warning"})
+	}
+	return metadata
+}
+
+// fixColumns returns the GPS fix column names for source sentences.
+func fixColumns(source string) []string {
+	switch source {
+	case "GGA":
+		return ggaFixColumns
+	case "RMC":
+		return rmcFixColumns
+	}
+	return nil
+}
+
+// addGGAFix adds GPS fix columns from gga if configured, and applies the fix
+// policy to lat and lon, which must already be added. t is the measurement
+// time for Merge. An error is returned if the fix policy drops the fix, in
+// which case lat, lon, and fix columns are removed.
+func (dm *DataManager) addGGAFix(gga nmea.GGA, t time.Time) error {
+	if dm.fix.columns {
+		quality, satellites := tsdata.NA, tsdata.NA
+		if gga.Quality >= 0 {
+			quality = strconv.Itoa(gga.Quality)
+		}
+		if gga.Satellites >= 0 {
+			satellites = strconv.Itoa(gga.Satellites)
+		}
+		dm.AddValueAt("fix_quality", quality, t)
+		dm.AddValueAt("satellites", satellites, t)
+		dm.AddValueAt("hdop", floatText(gga.HDOP), t)
+		dm.AddValueAt("altitude", floatText(gga.Altitude), t)
+	}
+	switch {
+	case gga.Quality == 0:
+		return dm.poorFix(FlagBad, "fix quality 0")
+	case dm.fix.maxHDOP > 0 && gga.HDOP.Valid && gga.HDOP.Value > dm.fix.maxHDOP:
+		return dm.poorFix(FlagSuspect, fmt.Sprintf("HDOP %s > %g", gga.HDOP, dm.fix.maxHDOP))
+	}
+	return nil
+}
+
+// addRMCFix adds the GPS fix status column from rmc if configured, and
+// applies the fix policy to lat and lon as for addGGAFix.
+func (dm *DataManager) addRMCFix(rmc nmea.RMC, t time.Time) error {
+	if dm.fix.columns {
+		status := strings.TrimSpace(rmc.Status)
+		if status == "" {
+			status = tsdata.NA
+		}
+		dm.AddValueAt("fix_status", status, t)
+	}
+	if rmc.Status == "V" {
+		return dm.poorFix(FlagBad, "status V")
+	}
+	return nil
+}
+
+// poorFix applies the fix policy to lat and lon for a poor fix. flag is the
+// quality flag for lat and lon with FixFlag.
+func (dm *DataManager) poorFix(flag Flag, reason string) error {
+	err := fmt.Errorf("poor GPS fix: %s", reason)
+	switch dm.fix.policy {
+	case FixFlag:
+		for _, k := range []string{"lat", "lon"} {
+			if v, ok := dm.values[k]; ok && v.OK() && v.Flag < flag {
+				v.Flag = flag
+				dm.values[k] = v
+			}
+		}
+		dm.AddError(err)
+	case FixDrop:
+		for _, k := range append([]string{"lat", "lon"}, fixColumns(dm.fix.source)...) {
+			delete(dm.values, k)
+			delete(dm.stamps, k)
+		}
+		return err
+	}
+	return nil
+}
+
+// floatText returns the original text of an optional NMEA number, or
+// tsdata.NA if it is empty.
+func floatText(f nmea.Float) string {
+	if !f.Valid {
+		return tsdata.NA
+	}
+	return f.Text
+}
@@ -0,0 +1,116 @@
+package parse
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ctberthiaume/cruisemic/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFixPolicy(t *testing.T) {
+	assert := assert.New(t)
+	for name, expected := range FixPolicyNames {
+		policy, err := ParseFixPolicy(name)
+		assert.Nil(err)
+		assert.Equal(expected, policy)
+	}
+	_, err := ParseFixPolicy("reject")
+	assert.NotNil(err)
+}
+
+func TestGGAFix(t *testing.T) {
+	zda := "$SEAFLOW::$GPZDA,213309.00,12,01,2023,00,00*6D::"
+	tsg := ":: 12.3719,  3.64868,  31.2816::157.580\n"
+	rtk := zda + "$GPGGA,213309.00,4738.983141,N,12218.805824,W,4,17,0.7,15.773,M,-22.2,M,7.0,0402*44" + tsg
+	invalid := zda + "$GPGGA,213309.00,4738.983141,N,12218.805824,W,0,,,,M,-22.2,M,,*44" + tsg
+	highHDOP := zda + "$GPGGA,213309.00,4738.983141,N,12218.805824,W,1,4,5.2,15.773,M,-22.2,M,,*44" + tsg
+	testData := []struct {
+		name     string
+		columns  bool
+		policy   FixPolicy
+		maxHDOP  float64
+		flags    bool
+		input    string
+		expected map[string][]string
+	}{
+		{
+			"fix columns",
+			true, FixKeep, 0, false,
+			rtk + invalid,
+			map[string][]string{
+				"geo": {
+					"2023-01-12T21:33:09Z\t47.6497\t-122.3134\t12.3719\t3.64868\t31.2816\t157.580\t4\t17\t0.7\t15.773\n",
+					"2023-01-12T21:33:09Z\t47.6497\t-122.3134\t12.3719\t3.64868\t31.2816\t157.580\t0\tNA\tNA\tNA\n",
+				},
+			},
+		},
+		{
+			"drop fix quality 0",
+			false, FixDrop, 0, false,
+			rtk + invalid + highHDOP,
+			map[string][]string{
+				"geo": {
+					"2023-01-12T21:33:09Z\t47.6497\t-122.3134\t12.3719\t3.64868\t31.2816\t157.580\n",
+					"2023-01-12T21:33:09Z\t47.6497\t-122.3134\t12.3719\t3.64868\t31.2816\t157.580\n",
+				},
+			},
+		},
+		{
+			"drop high HDOP",
+			false, FixDrop, 2, false,
+			rtk + highHDOP,
+			map[string][]string{
+				"geo": {"2023-01-12T21:33:09Z\t47.6497\t-122.3134\t12.3719\t3.64868\t31.2816\t157.580\n"},
+			},
+		},
+		{
+			"flag",
+			false, FixFlag, 2, true,
+			rtk + invalid + highHDOP,
+			map[string][]string{
+				"geo": {
+					"2023-01-12T21:33:09Z\t47.6497\t1\t-122.3134\t1\t12.3719\t1\t3.64868\t1\t31.2816\t1\t157.580\t1\n",
+					"2023-01-12T21:33:09Z\t47.6497\t4\t-122.3134\t4\t12.3719\t1\t3.64868\t1\t31.2816\t1\t157.580\t1\n",
+					"2023-01-12T21:33:09Z\t47.6497\t3\t-122.3134\t3\t12.3719\t1\t3.64868\t1\t31.2816\t1\t157.580\t1\n",
+				},
+			},
+		},
+	}
+	for _, tt := range testData {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			p := NewTN427Parser("test", 0, time.Now).(*TN427Parser)
+			p.SetFlagColumns(tt.flags)
+			p.SetGPSFix(tt.columns, tt.policy, tt.maxHDOP)
+			store, _ := storage.NewMemStorage()
+			err := ParseLines(p, strings.NewReader(tt.input), store, true, false)
+			assert.Nil(err)
+			assert.Equal(tt.expected, store.Feeds)
+		})
+	}
+
+	// Fix columns are kept when options replace the metadata
+	p := NewTN427Parser("test", 0, time.Now).(*TN427Parser)
+	p.SetGPSFix(true, FixKeep, 0)
+	assert.Nil(t, p.Configure(Options{"tsg.sound_velocity": "true"}))
+	assert.Contains(t, p.Header(), "sound_velocity\tpar\tfix_quality\tsatellites\thdop\taltitude")
+}
+
+func TestRMCFix(t *testing.T) {
+	assert := assert.New(t)
+	p := NewTARAParser("test", 0, time.Now).(*TARAParser)
+	p.SetGPSFix(true, FixDrop, 0)
+	assert.Contains(p.Header(), "par\tfix_status")
+
+	store, _ := storage.NewMemStorage()
+	input := `$GPRMC,160331,V,4743.7690,N,00322.4408,W,0.0,182.6,071225,0.2,W,N*13
+$GPRMC,160332,A,4743.7694,N,00322.4405,W,0.0,182.6,071225,0.2,W,D*19
+`
+	err := ParseLines(p, strings.NewReader(input), store, true, false)
+	assert.Nil(err)
+	assert.Equal(map[string][]string{
+		"geo": {"2025-12-07T16:03:32Z\t47.7295\t-3.3740\tNA\tNA\tNA\tNA\tA\n"},
+	}, store.Feeds)
+}
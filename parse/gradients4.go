@@ -13,11 +13,69 @@ import (
 // Gradients4Parser is a parser for Gradients 4 Thompson underway feed lines.
 type Gradients4Parser struct {
 	Throttle
-	i   int // line number in current stanza, e.g. $SEAFLOW is 1, geo is 2
-	now func() time.Time
+	stanzas *StanzaAssembler
 	DataManager
 }
 
+// gradients4Stanza describes Gradients 4 stanzas, a $SEAFLOW line followed by
+// latitude, longitude, temperature, conductivity, salinity, and PAR lines,
+// and sometimes blank lines. PAR may be blank.
+var gradients4Stanza = StanzaConfig{
+	Start:      func(line string) bool { return line == "$SEAFLOW" },
+	MinLines:   5,
+	MaxLines:   6,
+	TrimBlank:  true,
+	Validators: []StanzaValidator{validateG4Hemisphere},
+}
+
+// validateG4Hemisphere checks that latitude and longitude lines end with a
+// hemisphere, which catches stanzas shifted by a lost or extra line.
+func validateG4Hemisphere(line string, previous []string) error {
+	var name, hemispheres string
+	switch len(previous) {
+	case 1:
+		name, hemispheres = "latitude", "NS"
+	case 2:
+		name, hemispheres = "longitude", "EW"
+	default:
+		return nil
+	}
+	clean := strings.TrimSpace(line)
+	if clean == "" || !strings.ContainsAny(clean[len(clean)-1:], hemispheres) {
+		return fmt.Errorf("expected %s ending in %s, got %q", name, hemispheres, line)
+	}
+	return nil
+}
+
+func init() {
+	Register(Registration{
+		Name:        "Gradients4",
+		Description: "Gradients 4 SeaFlow stanza feed with host clock timestamps",
+		Cruises:     "Gradients 4",
+		Aliases:     []string{"G4"},
+		Example:     gradients4Example,
+		New:         NewGradients4Parser,
+	})
+}
+
+// gradients4Example is example Gradients4 feed input.
+const gradients4Example = `$SEAFLOW
+2118.9043N
+15752.6526W
+26.8
+5.3
+30.4
+
+$SEAFLOW
+2118.9043N
+15752.6526W
+26.8
+5.3
+30.5
+157.580
+$SEAFLOW
+`
+
 // NewGradients4Parser returns a pointer to a Gradients4Parser struct. project is
 // the project or cruise name. interval is the per-feed rate limiting interval
 // in seconds.
@@ -26,18 +84,40 @@ func NewGradients4Parser(project string, interval time.Duration, now func() time
 		Project:         project,
 		FileType:        "geo",
 		FileDescription: "Gradients 4 Thompson underway feed",
-		Comments:        []string{"RFC3339", "Latitude Decimal format", "Longitude Decimal format", "TSG temperature", "TSG conductivity", "TSG salinity"},
-		Types:           []string{"time", "float", "float", "float", "float", "float"},
-		Units:           []string{"NA", "deg", "deg", "C", "S/m", "PSU"},
-		Headers:         []string{"time", "lat", "lon", "temp", "conductivity", "salinity"},
+		Comments:        []string{"RFC3339", "Latitude Decimal format", "Longitude Decimal format", "TSG temperature", "TSG conductivity", "TSG salinity", "PAR"},
+		Types:           []string{"time", "float", "float", "float", "float", "float", "float"},
+		Units:           []string{"NA", "deg", "deg", "C", "S/m", "PSU", "µE/m^2/s"},
+		Headers:         []string{"time", "lat", "lon", "temp", "conductivity", "salinity", "par"},
 	}
 	return &Gradients4Parser{
 		Throttle:    NewThrottle(interval),
-		now:         now,
+		stanzas:     NewStanzaAssembler(gradients4Stanza, now),
 		DataManager: *NewDataManager(metadata, interval),
 	}
 }
 
+// Configure sets the stanza timeout. The only valid option is
+// stanza_timeout, a duration, e.g. 5s. Stanzas not complete within
+// stanza_timeout of their $SEAFLOW line are discarded, or flagged with -flags.
+// By default there is no timeout.
+func (p *Gradients4Parser) Configure(opts Options) error {
+	if err := opts.Check("stanza_timeout"); err != nil {
+		return fmt.Errorf("Gradients4Parser: %w", err)
+	}
+	timeout, err := opts.Duration("stanza_timeout", 0)
+	if err != nil {
+		return fmt.Errorf("Gradients4Parser: %w", err)
+	}
+	p.stanzas.SetTimeout(timeout)
+	return nil
+}
+
+// HostClock returns true because Gradients 4 records are timestamped with the
+// host clock.
+func (p *Gradients4Parser) HostClock() bool {
+	return true
+}
+
 // ParseLine parses a single underway feed line. Only lines ending with \n are
 // examined.
 func (p *Gradients4Parser) ParseLine(line string) (d Data) {
@@ -48,72 +128,108 @@ func (p *Gradients4Parser) ParseLine(line string) (d Data) {
 	// Remove trailing \n for parsing
 	line = line[:len(line)-1]
 
-	if line == "$SEAFLOW" {
-		d = p.GetData()
-		// Reset state beyond DataManager reset
-		p.i = 0
-		p.t = p.now().UTC()
+	s, ok := p.stanzas.Add(line)
+	if !ok {
 		return
 	}
+	return p.parseStanza(s)
+}
 
-	p.i++
+// parseStanza parses a complete stanza. Malformed stanzas are discarded with
+// an error, unless flag columns are on, in which case their values are
+// flagged as suspect. Records are timestamped with the host time of the
+// $SEAFLOW line.
+func (p *Gradients4Parser) parseStanza(s Stanza) (d Data) {
+	if s.Err != nil {
+		err := fmt.Errorf("Gradients4Parser: malformed stanza: %w: lines=%q", s.Err, s.Lines)
+		if !p.FlagColumns() {
+			d.Errors = []error{err}
+			return
+		}
+		p.AddError(err)
+	}
+	p.SetTime(s.Start.UTC())
+	// PAR is NA if blank or trimmed as a trailing blank line
+	p.AddValue("par", tsdata.NA)
 
-	// Trim leading and trailing whitespace
-	clean := strings.TrimSpace(line)
+	for i, line := range s.Lines[1:] {
+		// Trim leading and trailing whitespace
+		clean := strings.TrimSpace(line)
 
-	switch {
-	case p.i == 1:
-		// Latitude
-		if len(clean) < 2 {
-			p.AddError(fmt.Errorf("Gradients4Parser: bad GPGGA latitude: line=%q", line))
-		} else {
-			latdd, latddErr := geo.GGALat2DD(clean[:len(clean)-1], clean[len(clean)-1:])
-			if latddErr != nil {
-				p.AddError(fmt.Errorf("Gradients4Parser: bad GPGGA lat: %v: line=%q", latddErr, line))
+		switch i + 1 {
+		case 1:
+			// Latitude
+			if len(clean) < 2 {
+				p.AddError(fmt.Errorf("Gradients4Parser: bad GPGGA latitude: line=%q", line))
 			} else {
-				p.AddValue("lat", latdd)
+				latdd, latddErr := geo.GGALat2DD(clean[:len(clean)-1], clean[len(clean)-1:])
+				if latddErr != nil {
+					p.AddError(fmt.Errorf("Gradients4Parser: bad GPGGA lat: %v: line=%q", latddErr, line))
+				} else {
+					p.AddValue("lat", latdd)
+				}
 			}
-		}
-	case p.i == 2:
-		// Longitude
-		if len(clean) < 2 {
-			p.AddError(fmt.Errorf("Gradients4Parser: bad GPGGA longitude: line=%q", line))
-		} else {
-			londd, londdErr := geo.GGALon2DD(clean[:len(clean)-1], clean[len(clean)-1:])
-			if londdErr != nil {
-				p.AddError(fmt.Errorf("Gradients4Parser: bad GPGGA lon: %v: line=%q", londdErr, line))
+		case 2:
+			// Longitude
+			if len(clean) < 2 {
+				p.AddError(fmt.Errorf("Gradients4Parser: bad GPGGA longitude: line=%q", line))
 			} else {
-				p.AddValue("lon", londd)
+				londd, londdErr := geo.GGALon2DD(clean[:len(clean)-1], clean[len(clean)-1:])
+				if londdErr != nil {
+					p.AddError(fmt.Errorf("Gradients4Parser: bad GPGGA lon: %v: line=%q", londdErr, line))
+				} else {
+					p.AddValue("lon", londd)
+				}
+			}
+		case 3:
+			// Temperature
+			_, floatErr := strconv.ParseFloat(clean, 64)
+			if floatErr != nil {
+				p.AddError(fmt.Errorf("Gradients4Parser: bad float: line=%q", line))
+				p.AddInvalid("temp", clean)
+			} else {
+				p.AddValue("temp", clean)
+			}
+		case 4:
+			// Conductivity
+			_, floatErr := strconv.ParseFloat(clean, 64)
+			if floatErr != nil {
+				p.AddError(fmt.Errorf("Gradients4Parser: bad float: line=%q", line))
+				p.AddInvalid("conductivity", clean)
+			} else {
+				p.AddValue("conductivity", clean)
+			}
+		case 5:
+			// Salinity
+			_, floatErr := strconv.ParseFloat(clean, 64)
+			if floatErr != nil {
+				p.AddError(fmt.Errorf("Gradients4Parser: bad float: line=%q", line))
+				p.AddInvalid("salinity", clean)
+			} else {
+				p.AddValue("salinity", clean)
+			}
+		case 6:
+			// PAR, may be blank
+			if clean == "" {
+				break
+			}
+			_, floatErr := strconv.ParseFloat(clean, 64)
+			if floatErr != nil {
+				p.AddError(fmt.Errorf("Gradients4Parser: bad PAR float: line=%q", line))
+				p.AddInvalid("par", clean)
+			} else {
+				p.AddValue("par", clean)
 			}
-		}
-	case p.i == 3:
-		// Temperature
-		_, floatErr := strconv.ParseFloat(clean, 64)
-		if floatErr != nil {
-			p.AddError(fmt.Errorf("Gradients4Parser: bad float: line=%q", line))
-			p.AddValue("temp", tsdata.NA)
-		} else {
-			p.AddValue("temp", clean)
-		}
-	case p.i == 4:
-		// Conductivity
-		_, floatErr := strconv.ParseFloat(clean, 64)
-		if floatErr != nil {
-			p.AddError(fmt.Errorf("Gradients4Parser: bad float: line=%q", line))
-			p.AddValue("conductivity", tsdata.NA)
-		} else {
-			p.AddValue("conductivity", clean)
-		}
-	case p.i == 5:
-		// Salinity
-		_, floatErr := strconv.ParseFloat(clean, 64)
-		if floatErr != nil {
-			p.AddError(fmt.Errorf("Gradients4Parser: bad float: line=%q", line))
-			p.AddValue("salinity", tsdata.NA)
-		} else {
-			p.AddValue("salinity", clean)
 		}
 	}
+	if s.Err != nil {
+		p.flagSuspect()
+	}
 
+	d = p.GetData()
+	if d.Time.IsZero() {
+		// Incomplete record
+		d.Errors = p.discard()
+	}
 	return
 }
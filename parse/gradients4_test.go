@@ -45,7 +45,7 @@ func TestG4Lines(t *testing.T) {
 $SEAFLOW
 `,
 			map[string][]string{
-				"geo": {t0.Format(time.RFC3339Nano) + "\t21.3151\t-157.8775\t26.8\t5.3\t30.4\n"},
+				"geo": {t0.Format(time.RFC3339Nano) + "\t21.3151\t-157.8775\t26.8\t5.3\t30.4\tNA\n"},
 			},
 		},
 		{
@@ -66,8 +66,8 @@ $SEAFLOW
 `,
 			map[string][]string{
 				"geo": {
-					t0.Format(time.RFC3339Nano) + "\t21.3151\t-157.8775\t26.8\t5.3\t30.5\n",
-					(t0.Add(time.Second)).Format(time.RFC3339Nano) + "\t21.3151\t-157.8775\t26.8\t5.3\t30.6\n",
+					t0.Format(time.RFC3339Nano) + "\t21.3151\t-157.8775\t26.8\t5.3\t30.5\tNA\n",
+					(t0.Add(time.Second)).Format(time.RFC3339Nano) + "\t21.3151\t-157.8775\t26.8\t5.3\t30.6\tNA\n",
 				},
 			},
 		},
@@ -91,8 +91,8 @@ $SEAFLOW
 `,
 			map[string][]string{
 				"geo": {
-					t0.Format(time.RFC3339Nano) + "\t21.3151\t-157.8775\t26.8\t5.3\t30.5\n",
-					(t0.Add(time.Second)).Format(time.RFC3339Nano) + "\t21.3151\t-157.8775\t26.8\t5.3\t30.6\n",
+					t0.Format(time.RFC3339Nano) + "\t21.3151\t-157.8775\t26.8\t5.3\t30.5\tNA\n",
+					(t0.Add(time.Second)).Format(time.RFC3339Nano) + "\t21.3151\t-157.8775\t26.8\t5.3\t30.6\tNA\n",
 				},
 			},
 		},
@@ -180,7 +180,7 @@ $SEAFLOW
 $SEAFLOW
 `,
 			map[string][]string{
-				"geo": {t0.Format(time.RFC3339Nano) + "\t21.3151\t-157.8775\tNA\t5.3\t30.9\n"},
+				"geo": {t0.Format(time.RFC3339Nano) + "\t21.3151\t-157.8775\tNA\t5.3\t30.9\tNA\n"},
 			},
 		},
 		{
@@ -194,7 +194,7 @@ $SEAFLOW
 $SEAFLOW
 `,
 			map[string][]string{
-				"geo": {t0.Format(time.RFC3339Nano) + "\t21.3151\t-157.8775\t26.8\tNA\t30.9\n"},
+				"geo": {t0.Format(time.RFC3339Nano) + "\t21.3151\t-157.8775\t26.8\tNA\t30.9\tNA\n"},
 			},
 		},
 		{
@@ -208,7 +208,7 @@ $SEAFLOW
 $SEAFLOW
 `,
 			map[string][]string{
-				"geo": {t0.Format(time.RFC3339Nano) + "\t21.3151\t-157.8775\t26.8\t5.3\tNA\n"},
+				"geo": {t0.Format(time.RFC3339Nano) + "\t21.3151\t-157.8775\t26.8\t5.3\tNA\tNA\n"},
 			},
 		},
 		{
@@ -238,7 +238,7 @@ $SEAFLOW
 $SEAFLOW
 `,
 			map[string][]string{
-				"geo": {(t0.Add(time.Second)).Format(time.RFC3339Nano) + "\t21.3151\t-157.8775\t26.8\t5.3\t30.5\n"},
+				"geo": {(t0.Add(time.Second)).Format(time.RFC3339Nano) + "\t21.3151\t-157.8775\t26.8\t5.3\t30.5\tNA\n"},
 			},
 		},
 		{
@@ -257,9 +257,80 @@ $SEAFLOW
 $SEAFLOW
 `,
 			map[string][]string{
-				"geo": {t0.Format(time.RFC3339Nano) + "\t21.3151\t-157.8775\t26.8\t5.3\t30.4\n"},
+				"geo": {t0.Format(time.RFC3339Nano) + "\t21.3151\t-157.8775\t26.8\t5.3\t30.4\tNA\n"},
 			},
 		},
+		{
+			"PAR",
+			`$SEAFLOW
+2118.9043N
+15752.6526W
+26.8
+5.3
+30.4
+157.580
+$SEAFLOW
+`,
+			map[string][]string{
+				"geo": {t0.Format(time.RFC3339Nano) + "\t21.3151\t-157.8775\t26.8\t5.3\t30.4\t157.580\n"},
+			},
+		},
+		{
+			"PAR with blank lines after",
+			`$SEAFLOW
+2118.9043N
+15752.6526W
+26.8
+5.3
+30.4
+ 157.580
+
+
+$SEAFLOW
+`,
+			map[string][]string{
+				"geo": {t0.Format(time.RFC3339Nano) + "\t21.3151\t-157.8775\t26.8\t5.3\t30.4\t157.580\n"},
+			},
+		},
+		{
+			"blank PAR",
+			"$SEAFLOW\n2118.9043N\n15752.6526W\n26.8\n5.3\n30.4\n  \n$SEAFLOW\n",
+			map[string][]string{
+				"geo": {t0.Format(time.RFC3339Nano) + "\t21.3151\t-157.8775\t26.8\t5.3\t30.4\tNA\n"},
+			},
+		},
+		{
+			"bad PAR",
+			`$SEAFLOW
+2118.9043N
+15752.6526W
+26.8
+5.3
+30.4
+157a.580
+$SEAFLOW
+`,
+			map[string][]string{
+				"geo": {t0.Format(time.RFC3339Nano) + "\t21.3151\t-157.8775\t26.8\t5.3\t30.4\tNA\n"},
+			},
+		},
+		{
+			"lost $SEAFLOW line",
+			`$SEAFLOW
+2118.9043N
+15752.6526W
+26.8
+5.3
+30.4
+2118.9043N
+15752.6526W
+26.8
+5.3
+30.5
+$SEAFLOW
+`,
+			map[string][]string{},
+		},
 	}
 	for _, tt := range testData {
 		t.Run(tt.name, createG4LinesTest(t, tt))
@@ -293,3 +364,71 @@ func createG4LinesTest(t *testing.T, tt testG4LineData) func(*testing.T) {
 		assert.Equal(tt.expected, store.Feeds, tt.name)
 	}
 }
+
+func TestG4MalformedStanza(t *testing.T) {
+	assert := assert.New(t)
+	t0, _ := time.Parse(time.RFC3339, g4TimeStartStr)
+	now := t0
+	p := NewGradients4Parser("test", 0, func() time.Time { return now }).(*Gradients4Parser)
+	assert.Nil(p.Configure(Options{"stanza_timeout": "5s"}))
+	assert.NotNil(p.Configure(Options{"stanza_timeout": "5"}))
+	assert.NotNil(p.Configure(Options{"timeout": "5s"}))
+
+	// Extra line
+	input := `$SEAFLOW
+2118.9043N
+15752.6526W
+26.8
+5.3
+30.4
+157.580
+30.5
+$SEAFLOW
+`
+	var records []Data
+	for _, line := range strings.SplitAfter(input, "\n") {
+		if d := p.ParseLine(line); d.OK() || len(d.Errors) > 0 {
+			records = append(records, d)
+		}
+	}
+	assert.Len(records, 1)
+	assert.False(records[0].OK())
+	assert.Len(records[0].Errors, 1)
+	assert.Contains(records[0].Errors[0].Error(), "7 lines after start marker, expected at most 6")
+
+	// Kept and flagged with flag columns
+	p.SetFlagColumns(true)
+	records = nil
+	for _, line := range strings.SplitAfter(input, "\n") {
+		if d := p.ParseLine(line); d.OK() {
+			records = append(records, d)
+		}
+	}
+	assert.Len(records, 1)
+	assert.Equal(t0.Format(time.RFC3339)+"\t21.3151\t3\t-157.8775\t3\t26.8\t3\t5.3\t3\t30.4\t3\t157.580\t3", records[0].Line("\t"))
+
+	// Timed out
+	p.SetFlagColumns(false)
+	p.ParseLine("2118.9043N\n")
+	now = now.Add(6 * time.Second)
+	d := p.ParseLine("15752.6526W\n")
+	assert.False(d.OK())
+	assert.Contains(d.Errors[0].Error(), "timed out after 5s")
+
+	// Stanzas further apart than the timeout aren't timed out
+	stanza := "$SEAFLOW\n2118.9043N\n15752.6526W\n26.8\n5.3\n30.4\n157.580\n"
+	records = nil
+	for i := 0; i < 3; i++ {
+		now = now.Add(10 * time.Second)
+		for _, line := range strings.SplitAfter(stanza, "\n") {
+			if d := p.ParseLine(line); d.OK() || len(d.Errors) > 0 {
+				records = append(records, d)
+			}
+		}
+	}
+	assert.Len(records, 2)
+	for _, d := range records {
+		assert.True(d.OK())
+		assert.Len(d.Errors, 0)
+	}
+}
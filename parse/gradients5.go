@@ -1,166 +1,43 @@
 package parse
 
-import (
-	"fmt"
-	"strconv"
-	"strings"
-	"time"
-
-	"github.com/ctberthiaume/cruisemic/geo"
-	"github.com/ctberthiaume/tsdata"
-)
+import "time"
 
 // Gradients5Parser is a parser for Gradients 4 Thompson underway feed lines.
 type Gradients5Parser struct {
-	DataManager
+	SeaflowParser
+}
+
+func init() {
+	Register(Registration{
+		Name:        "Gradients5",
+		Description: "Gradients 5 $SEAFLOW line feed with $PPAR PAR",
+		Cruises:     "Gradients 5",
+		Aliases:     []string{"G5"},
+		Example:     gradients5Example,
+		New:         NewGradients5Parser,
+	})
 }
 
+// gradients5Example is example Gradients5 feed input.
+const gradients5Example = `$SEAFLOW::$GPZDA,213309.00,12,01,2023,00,00*6D::$GPGGA,213309.00,4738.983141,N,12218.805824,W,2,17,0.7,15.773,M,-22.2,M,7.0,0402*44::::$PPAR, 157.580, 6.10, 5
+$SEAFLOW::$GPZDA,213310.00,12,01,2023,00,00*65::$GPGGA,213310.00,4738.983143,N,12218.805821,W,2,17,0.7,15.774,M,-22.2,M,8.0,0402*43::::$PPAR, 157.445, 5
+$SEAFLOW::$GPZDA,213311.00,12,01,2023,00,00*64::$GPGGA,213311.00,4738.983147,N,12218.805822,W,2,17,0.7,15.776,M,-22.2,M,5.0,0402*4A::::$PPAR, 157.3
+`
+
 // NewGradients5Parser returns a pointer to a Gradients5Parser struct. project is
 // the project or cruise name. interval is the per-feed rate limiting interval
 // in seconds.
 func NewGradients5Parser(project string, interval time.Duration, now func() time.Time) Parser {
-	metadata := tsdata.Tsdata{
-		Project:         project,
-		FileType:        "geo",
+	config := SeaflowConfig{
+		Name:            "Gradients5Parser",
+		Talker:          "GP",
 		FileDescription: "Gradients 5 Thompson underway feed",
-		Comments:        []string{"RFC3339", "Latitude Decimal format", "Longitude Decimal format", "TSG temperature", "TSG conductivity", "TSG salinity", "PAR"},
-		Types:           []string{"time", "float", "float", "float", "float", "float", "float"},
-		Units:           []string{"NA", "deg", "deg", "C", "S/m", "PSU", "µE/m^2/s"},
-		Headers:         []string{"time", "lat", "lon", "temp", "conductivity", "salinity", "par"},
+		RequireNewline:  true,
+		TSGFields:       []int{3, 4},
+		PARFormat:       SeaflowPARPPAR,
+		PARDecimals:     3,
 	}
-
 	return &Gradients5Parser{
-		DataManager: *NewDataManager(metadata, interval),
-	}
-}
-
-// ParseLine parses a single underway feed line. Only lines ending with \n are
-// examined.
-func (p *Gradients5Parser) ParseLine(line string) (d Data) {
-	if len(line) == 0 || line[len(line)-1] != '\n' {
-		return
-	}
-
-	// Remove trailing \n for parsing
-	line = line[:len(line)-1]
-
-	// Trim leading and trailing whitespace
-	clean := strings.TrimSpace(line)
-
-	if !strings.HasPrefix(clean, "$SEAFLOW") {
-		return
-	}
-
-	fields := strings.Split(clean, "::")
-
-	if len(fields) != 5 {
-		return
+		SeaflowParser: *NewSeaflowParser(config, project, interval),
 	}
-
-	// Parse time
-	timeFields := strings.Split(fields[1], ",")
-	if len(timeFields) != 7 {
-		p.AddError(fmt.Errorf("Gradients5Parser: bad GPZDA: line=%q", clean))
-		return
-	}
-	if len(timeFields[1]) != 9 {
-		p.AddError(fmt.Errorf("Gradients5Parser: bad GPZDA: line=%q", clean))
-		return
-	}
-	timestr := timeFields[1][:2] + ":" + timeFields[1][2:4] + ":" + timeFields[1][4:6]
-	datestr := timeFields[4] + "-" + timeFields[3] + "-" + timeFields[2]
-	t, err := time.Parse(time.RFC3339, datestr+"T"+timestr+"Z")
-	if err != nil {
-		p.AddError(fmt.Errorf("Gradients5Parser: bad GPZDA: %v: line=%q", err, clean))
-		return
-	}
-	p.SetTime(t)
-
-	// Latitude and Longitude
-	latLonFields := strings.Split(fields[2], ",")
-	if len(latLonFields) != 15 {
-		p.AddError(fmt.Errorf("Gradients5Parser: bad GPGGA: line=%q", clean))
-		return
-	} else {
-		latdd, latddErr := geo.GGALat2DD(latLonFields[2], latLonFields[3])
-		if latddErr != nil {
-			p.AddError(fmt.Errorf("Gradients5Parser: bad GPGGA lat: %v: line=%q", latddErr, line))
-			return
-		}
-		p.AddValue("lat", latdd)
-
-		// Longitude
-		londd, londdErr := geo.GGALon2DD(latLonFields[4], latLonFields[5])
-		if londdErr != nil {
-			p.AddError(fmt.Errorf("Gradients5Parser: bad GPGGA lon: %v: line=%q", londdErr, line))
-			return
-		}
-		p.AddValue("lon", londd)
-	}
-
-	// // Temperature
-	tsgFields := strings.Split(fields[3], ",")
-	if len(tsgFields) != 3 && len(tsgFields) != 4 {
-		p.AddError(fmt.Errorf("Gradients5Parser: bad TSG: line=%q", clean))
-		p.AddValue("temp", tsdata.NA)
-		p.AddValue("conductivity", tsdata.NA)
-		p.AddValue("salinity", tsdata.NA)
-	} else {
-		tempStr := strings.TrimSpace(tsgFields[0])
-		_, floatErr := strconv.ParseFloat(strings.TrimSpace(tsgFields[0]), 64)
-		if floatErr != nil {
-			p.AddError(fmt.Errorf("Gradients5Parser: bad float: line=%q", line))
-			p.AddValue("temp", tsdata.NA)
-		} else {
-			p.AddValue("temp", tempStr)
-		}
-
-		// Conductivity
-		condStr := strings.TrimSpace(tsgFields[1])
-		_, floatErr = strconv.ParseFloat(condStr, 64)
-		if floatErr != nil {
-			p.AddError(fmt.Errorf("Gradients5Parser: bad float: line=%q", line))
-			p.AddValue("conductivity", tsdata.NA)
-		} else {
-			p.AddValue("conductivity", condStr)
-		}
-
-		// Salinity
-		salStr := strings.TrimSpace(tsgFields[2])
-		_, floatErr = strconv.ParseFloat(salStr, 64)
-		if floatErr != nil {
-			p.AddError(fmt.Errorf("Gradients5Parser: bad float: line=%q", line))
-			p.AddValue("salinity", tsdata.NA)
-		} else {
-			p.AddValue("salinity", salStr)
-		}
-	}
-
-	// PAR
-	// Keep the line if PAR is simply not present, PAR feed may have stopped
-	// entirely and we should keep all other values if possible. As opposed to
-	// PAR being present but with possibly truncated numbers, in which case we
-	// want to reject the entire line in the hopes that a valid PAR value shows
-	// up within the throttled time interval.
-	parFields := strings.Split(fields[4], ",")
-	if len(parFields) < 2 {
-		p.AddError(fmt.Errorf("Gradients5Parser: bad PPAR: line=%q", clean))
-		p.AddValue("par", tsdata.NA)
-	} else {
-		parStr := strings.TrimSpace(parFields[1])
-		parNumberFields := strings.Split(parFields[1], ".")
-		_, floatErr := strconv.ParseFloat(parStr, 64)
-		if floatErr != nil || len(parNumberFields) != 2 || len(parNumberFields[1]) != 3 {
-			p.AddError(fmt.Errorf("Gradients5Parser: bad PAR float: line=%q", line))
-			p.AddValue("par", tsdata.NA)
-			// PAR is unreliable on G5. We'll be reading every second, so just completely
-			// reject the entire line if bad PAR. About 1 in 4 PAR is good.
-			return
-		} else {
-			p.AddValue("par", parStr)
-		}
-	}
-
-	// Populate Data
-	return p.GetData()
 }
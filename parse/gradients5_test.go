@@ -158,13 +158,17 @@ $SEAFLOW::$GPZDA,213310.00,12,01,2023,00,00*6D::$GPGGA,213310.00,4738.983141,N,1
 			"bad PAR, truncated",
 			`$SEAFLOW::$GPZDA,213309.00,12,01,2023,00,00*6D::$GPGGA,213309.00,4738.983141,N,12218.805824,W,2,17,0.7,15.773,M,-22.2,M,7.0,0402*44::::$PPAR, 157.58, 6.10, 5
 `,
-			map[string][]string{},
+			map[string][]string{
+				"geo": {"2023-01-12T21:33:09Z\t47.6497\t-122.3134\tNA\tNA\tNA\tNA\n"},
+			},
 		},
 		{
 			"bad PAR, not a number",
 			`$SEAFLOW::$GPZDA,213309.00,12,01,2023,00,00*6D::$GPGGA,213309.00,4738.983141,N,12218.805824,W,2,17,0.7,15.773,M,-22.2,M,7.0,0402*44::::$PPAR, 1a7.580, 6.10, 5
 `,
-			map[string][]string{},
+			map[string][]string{
+				"geo": {"2023-01-12T21:33:09Z\t47.6497\t-122.3134\tNA\tNA\tNA\tNA\n"},
+			},
 		},
 		{
 			"missing PAR text entirely",
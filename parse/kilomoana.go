@@ -2,24 +2,252 @@ package parse
 
 import (
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/ctberthiaume/cruisemic/geo"
+	"github.com/ctberthiaume/cruisemic/nmea"
 	"github.com/ctberthiaume/tsdata"
 )
 
-// KiloMoanaParser is a parser for Kilo Moana underway feed lines.
+// KiloMoanaParser is a parser for Kilo Moana underway feed lines. Gravimeter
+// and barometer lines can optionally be written to their own feeds.
 type KiloMoanaParser struct {
 	DataManager
+	project  string
+	interval time.Duration
+	feeds    map[string]*DataManager // extra feeds by name
+	stanzas  *StanzaAssembler
+	last     time.Time // time of the latest instrument line
 }
 
+// kmStanza describes Kilo Moana underway stanzas, which start with a bar1
+// line. There is no end marker, so a stanza ends at the next bar1 line.
+var kmStanza = StanzaConfig{
+	Start:      isKMBar1,
+	Validators: []StanzaValidator{validateKMUnique},
+}
+
+// isKMBar1 returns true for a bar1 instrument line.
+func isKMBar1(line string) bool {
+	fields := strings.Fields(line)
+	return len(fields) >= 7 && fields[6] == "bar1"
+}
+
+// kmUniqueLine returns a key for lines that should appear once per stanza,
+// or "" for other lines. A repeated line means a bar1 line was lost and two
+// stanzas have run together.
+func kmUniqueLine(line string) string {
+	if strings.HasPrefix(line, "$") {
+		if sen, err := nmea.Parse(line); err == nil && (sen.Type == "GGA" || sen.Type == "VTG") {
+			return sen.Type
+		}
+		return ""
+	}
+	fields := strings.Fields(line)
+	if len(fields) >= 7 {
+		switch fields[6] {
+		case "uthsl", "flor", "met":
+			return fields[6]
+		}
+	}
+	return ""
+}
+
+// validateKMUnique checks that line isn't a second uthsl, flor, met, GGA, or
+// VTG line in a stanza.
+func validateKMUnique(line string, previous []string) error {
+	key := kmUniqueLine(line)
+	if key == "" {
+		return nil
+	}
+	for _, prev := range previous {
+		if kmUniqueLine(prev) == key {
+			return fmt.Errorf("duplicate %s line, missing bar1 line?", key)
+		}
+	}
+	return nil
+}
+
+// Kilo Moana feeds that can be enabled with the feeds option.
+const (
+	KMGravimeterName = "gravimeter"
+	KMBarometerName  = "barometer"
+	KMMetName        = "met"
+	KMWindName       = "wind"
+)
+
+// kmColumn is a column of an extra Kilo Moana feed. pos is the position of
+// the value in a whitespace separated instrument line, where positions 0-5 are
+// the date stamp and 6 is the instrument name.
+type kmColumn struct {
+	name    string
+	typ     string
+	unit    string
+	comment string
+	pos     int
+}
+
+// kmFeed describes an extra Kilo Moana feed read from instrument lines.
+type kmFeed struct {
+	description string
+	instruments []string // instrument names, e.g. bar1
+	fields      int      // required field count
+	atLeast     bool     // fields is a minimum count
+	columns     []kmColumn
+	derived     []kmColumn                     // columns computed by derive, pos is unused
+	derive      func(fields []string) []string // values for derived columns
+}
+
+// kmMetColumn returns a met column with the same name as its position.
+// Only some met fields have been identified.
+func kmMetColumn(pos int) kmColumn {
+	return kmColumn{fmt.Sprintf("met_%d", pos), "float", tsdata.NA, fmt.Sprintf("Met line field %d", pos), pos}
+}
+
+// kmFeeds are the extra Kilo Moana feeds by name.
+var kmFeeds = map[string]kmFeed{
+	KMGravimeterName: {
+		description: "Kilo Moana gravimeter feed",
+		instruments: []string{"rbgm3"},
+		fields:      10,
+		columns: []kmColumn{
+			{"gravity", "float", "mGal", "Gravimeter (rbgm3) gravity", 9},
+		},
+	},
+	KMBarometerName: {
+		description: "Kilo Moana barometer feed",
+		instruments: []string{"bar1"},
+		fields:      9,
+		columns: []kmColumn{
+			{"pressure", "float", "mbar", "Barometer (bar1) pressure", 7},
+		},
+	},
+	// The met line has 16 fixed fields after the instrument name, then
+	// sometimes R-, then two more fields. Only fields identified in
+	// example-feeds/Kilo-Moana/README.md are named.
+	KMMetName: {
+		description: "Kilo Moana meteorology feed",
+		instruments: []string{"met"},
+		fields:      23,
+		atLeast:     true,
+		columns: []kmColumn{
+			kmMetColumn(7),
+			kmMetColumn(8),
+			kmMetColumn(9),
+			kmMetColumn(10),
+			kmMetColumn(11),
+			kmMetColumn(12),
+			kmMetColumn(13),
+			kmMetColumn(14),
+			kmMetColumn(15),
+			kmMetColumn(16),
+			kmMetColumn(17),
+			{"par", "float", "mV", "Surface PAR milliVolts", 18},
+			{"relative_wind_speed", "float", tsdata.NA, "Relative wind speed, as in rwd lines", 19},
+			{"relative_wind_direction", "float", "deg", "Relative wind direction, as in rwd lines", 20},
+			{"true_wind_speed", "float", tsdata.NA, "True wind speed, as in rwd lines", 21},
+			{"true_wind_direction", "float", "deg", "True wind direction, as in rwd lines", 22},
+		},
+	},
+	// Relative and true wind from the two anemometers, one record per line.
+	KMWindName: {
+		description: "Kilo Moana wind feed",
+		instruments: []string{"rwd1", "rwd2"},
+		fields:      14,
+		columns: []kmColumn{
+			{"sensor", "category", tsdata.NA, "Anemometer (rwd1 or rwd2)", 6},
+			{"relative_speed", "float", "kn", "Relative wind speed", 7},
+			{"relative_direction", "float", "deg", "Relative wind direction", 8},
+			{"ship_speed", "float", "kn", "Ship's speed", 9},
+			{"ship_course", "float", "deg", "Ship's course", 10},
+			{"ship_heading", "float", "deg", "Ship's heading", 11},
+			{"true_speed", "float", "kn", "True wind speed", 12},
+			{"true_direction", "float", "deg", "True wind direction", 13},
+		},
+		derived: []kmColumn{
+			{"computed_true_speed", "float", "kn", "True wind speed computed from relative wind, ship's heading, speed, and course", 0},
+			{"computed_true_direction", "float", "deg", "True wind direction computed from relative wind, ship's heading, speed, and course", 0},
+		},
+		derive: kmTrueWind,
+	},
+}
+
+// kmTrueWind returns true wind speed and direction computed from a rwd line
+// the same way as for MWV sentences, or NA if any value is bad.
+func kmTrueWind(fields []string) []string {
+	var v [5]float64
+	for i, pos := range []int{7, 8, 11, 9, 10} {
+		f, err := strconv.ParseFloat(fields[pos], 64)
+		if err != nil {
+			return []string{tsdata.NA, tsdata.NA}
+		}
+		v[i] = f
+	}
+	speed, dir := geo.TrueWind(v[0], v[1], v[2], v[3], v[4])
+	return []string{formatWind(speed), formatWind(dir)}
+}
+
+// kmFeedMetadata returns Tsdata metadata for an extra Kilo Moana feed.
+func kmFeedMetadata(project, name string) (tsdata.Tsdata, bool) {
+	feed, ok := kmFeeds[name]
+	if !ok {
+		return tsdata.Tsdata{}, false
+	}
+	md := tsdata.Tsdata{
+		Project:         project,
+		FileType:        name,
+		FileDescription: feed.description,
+		Comments:        []string{"RFC3339"},
+		Types:           []string{"time"},
+		Units:           []string{tsdata.NA},
+		Headers:         []string{"time"},
+	}
+	for _, c := range append(feed.columns, feed.derived...) {
+		md.Comments = append(md.Comments, c.comment)
+		md.Types = append(md.Types, c.typ)
+		md.Units = append(md.Units, c.unit)
+		md.Headers = append(md.Headers, c.name)
+	}
+	return md, true
+}
+
+func init() {
+	Register(Registration{
+		Name:        "Kilo Moana",
+		Description: "Kilo Moana instrument broadcast stanzas",
+		Cruises:     "R/V Kilo Moana",
+		Aliases:     []string{"KiloMoana", "KM"},
+		Example:     kilomoanaExample,
+		New:         NewKiloMoanaParser,
+	})
+}
+
+// kilomoanaExample is example Kilo Moana feed input.
+const kilomoanaExample = `2017 168 00 30 28 990 bar1   1016.07 mbar
+2017 168 00 30 28 998 uthsl 19.968599 0.040550 0.217500 27.397800
+$GPDTM,W84,,00.0000,N,00.0000,E,,W84*41
+$GPGGA,003029.00,2118.9043,N,15752.6526,W,2,7,0.8,27,M,,M,,*78
+2017 168 00 30 29 229 rbgm3 024723 00 978906.152511
+2017 168 00 30 29 285 rwd1  10 233   0.0  52.5 208.3  10.0  81.3 
+$GPDTM,W84,,00.0000,N,00.0000,E,,W84*41
+2017 168 00 30 29 285 rwd2  12 236   0.0  52.5 208.3  12.0  84.3 
+2017 168 00 30 29 365 flor 78.000000
+$GPGLL,2118.9043,N,15752.6526,W,003029.00,A,D*71
+$GPVTG,47.3,T,37.7,M,0.0,N,0.0,K,D*25
+$GPZDA,003029.00,17,06,2017,00,00*6A
+2017 168 00 30 29 909 met  0.000 28.680  50.900 28.470 24.766  3.758 -0.246  1.097  1.099  0.000 5040.000  1.016 11.9 235.0 11.9   83.3 R-  0.000  0.000
+$GPDTM,W84,,00.0000,N,00.0000,E,,W84*41
+2017 168 00 30 30 990 bar1   1016.07 mbar
+2017 168 00 30 30 998 uthsl 19.968599 0.040550 0.217500 27.397800
+`
+
 // NewKiloMoanaParser returns a pointer to a KiloMoanaParser struct. project is
 // the project or cruise name. interval is the per-feed rate limiting interval
 // in seconds.
 func NewKiloMoanaParser(project string, interval time.Duration, now func() time.Time) Parser {
-	_ = now // now is not sued in this function
 	metadata := tsdata.Tsdata{
 		Project:         project,
 		FileType:        "geo",
@@ -43,12 +271,111 @@ func NewKiloMoanaParser(project string, interval time.Duration, now func() time.
 	}
 	return &KiloMoanaParser{
 		DataManager: *NewDataManager(metadata, interval),
+		project:     project,
+		interval:    interval,
+		feeds:       make(map[string]*DataManager),
+		stanzas:     NewStanzaAssembler(kmStanza, now),
+	}
+}
+
+// Configure enables extra feeds and sets the maximum age of merged underway
+// values and the stanza timeout. The feeds option is a comma separated list of
+// gravimeter, barometer, met, and wind. The max_age option is a duration,
+// e.g. 2s. Values more than max_age from the stanza time are written as NA,
+// and values missing from a stanza are taken from earlier stanzas if within
+// max_age. By default only values from the current stanza are used. The
+// stanza_timeout option is a duration. Stanzas not complete within
+// stanza_timeout of their bar1 line are discarded, or flagged with -flags. By
+// default there is no timeout.
+func (p *KiloMoanaParser) Configure(opts Options) error {
+	if err := opts.Check("feeds", "max_age", "stanza_timeout"); err != nil {
+		return fmt.Errorf("KiloMoanaParser: %w", err)
+	}
+	maxAge, err := opts.Duration("max_age", p.MaxAge())
+	if err != nil {
+		return fmt.Errorf("KiloMoanaParser: %w", err)
+	}
+	p.SetMaxAge(maxAge)
+	timeout, err := opts.Duration("stanza_timeout", 0)
+	if err != nil {
+		return fmt.Errorf("KiloMoanaParser: %w", err)
 	}
+	p.stanzas.SetTimeout(timeout)
+	p.feeds = make(map[string]*DataManager)
+	for _, name := range strings.Split(opts.String("feeds", ""), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		metadata, ok := kmFeedMetadata(p.project, name)
+		if !ok {
+			return fmt.Errorf("KiloMoanaParser: unknown feed %q", name)
+		}
+		p.feeds[name] = NewDataManager(metadata, p.interval)
+		p.feeds[name].SetFlagColumns(p.FlagColumns())
+	}
+	return nil
+}
+
+// SetFlagColumns sets whether quality flag columns are written for all feeds.
+func (p *KiloMoanaParser) SetFlagColumns(on bool) {
+	p.DataManager.SetFlagColumns(on)
+	for _, dm := range p.feeds {
+		dm.SetFlagColumns(on)
+	}
+}
+
+// SetGPSFix sets GPS fix columns and policy for GGA positions in the underway
+// feed.
+func (p *KiloMoanaParser) SetGPSFix(columns bool, policy FixPolicy, maxHDOP float64) {
+	p.setGPSFix("GGA", columns, policy, maxHDOP)
+}
+
+// SetAttitude sets whether attitude columns are written to the underway feed.
+func (p *KiloMoanaParser) SetAttitude(on bool) {
+	p.setAttitude(on)
+}
+
+// SetDepth sets whether a depth column is written to the underway feed, and the
+// transducer offset.
+func (p *KiloMoanaParser) SetDepth(on bool, offset float64) {
+	p.setDepth(on, offset)
+}
+
+// SetWind sets whether wind columns from MWV sentences are written to the
+// underway feed.
+func (p *KiloMoanaParser) SetWind(on bool) {
+	p.setWind(on)
+}
+
+// FeedHeaders returns Tsdata headers for the underway feed and any enabled
+// extra feeds.
+func (p *KiloMoanaParser) FeedHeaders() map[string]string {
+	headers := map[string]string{UnderwayName: p.Header()}
+	for name, dm := range p.feeds {
+		headers[name] = dm.Header()
+	}
+	return headers
 }
 
 // ParseLine parses a single underway feed line. Only lines ending with \n are
 // examined.
 func (p *KiloMoanaParser) ParseLine(line string) (d Data) {
+	d, _ = p.parseLine(line)
+	return d
+}
+
+// ParseLineFeeds parses a single feed line and returns records for the
+// underway feed and any enabled extra feeds.
+func (p *KiloMoanaParser) ParseLineFeeds(line string) []Data {
+	d, extra := p.parseLine(line)
+	d.Feed = UnderwayName
+	return append([]Data{d}, extra...)
+}
+
+// parseLine parses a single feed line. d is an underway feed record and extra
+// contains records for extra feeds.
+func (p *KiloMoanaParser) parseLine(line string) (d Data, extra []Data) {
 	if len(line) == 0 || line[len(line)-1] != '\n' {
 		return
 	}
@@ -56,16 +383,75 @@ func (p *KiloMoanaParser) ParseLine(line string) (d Data) {
 	// Remove trailing \n for parsing
 	line = line[:len(line)-1]
 
+	if !strings.HasPrefix(line, "$") {
+		fields := strings.Fields(line)
+		if len(fields) >= 7 {
+			extra = p.parseFeeds(fields, line)
+		}
+	}
+	if s, ok := p.stanzas.Add(line); ok {
+		d = p.parseStanza(s)
+	}
+
+	return
+}
+
+// parseStanza parses a complete underway stanza. Malformed stanzas are
+// discarded with an error, unless flag columns are on, in which case their
+// values are flagged as suspect.
+func (p *KiloMoanaParser) parseStanza(s Stanza) (d Data) {
+	if s.Err != nil {
+		err := fmt.Errorf("KiloMoanaParser: malformed stanza: %w: line=%q", s.Err, s.Lines[0])
+		if !p.FlagColumns() {
+			d.Errors = []error{err}
+			return
+		}
+		p.AddError(err)
+	}
+	if err := p.parseDate(strings.Fields(s.Lines[0])); err != nil {
+		p.AddError(fmt.Errorf("KiloMoanaParser: bad bar1 date: %v: line=%q", err, s.Lines[0]))
+	}
+	for _, line := range s.Lines[1:] {
+		p.parseStanzaLine(line)
+	}
+	if s.Err != nil {
+		p.flagSuspect()
+	}
+
+	// Fill in all non-time, non-lat, non-lon values and set data.
+	p.Merge("lat", "lon")
+	d = p.GetData()
+	if d.Time.IsZero() {
+		// Incomplete record
+		d.Errors = p.discard()
+	}
+	return
+}
+
+// parseStanzaLine parses an underway stanza line after the bar1 line.
+func (p *KiloMoanaParser) parseStanzaLine(line string) {
 	var thisErr error
-	if strings.HasPrefix(line, "$GPGGA") {
-		fields := strings.Split(line, ",")
-		if thisErr = p.parseGeo(fields); thisErr != nil {
-			p.AddError(fmt.Errorf("KiloMoanaParser: bad GPGGA: %v: line=%q", thisErr, line))
+	if strings.HasPrefix(line, "$") {
+		sen, err := nmea.Parse(line)
+		if err != nil {
+			p.AddError(fmt.Errorf("KiloMoanaParser: bad NMEA: %v: line=%q", err, line))
+			return
 		}
-	} else if strings.HasPrefix(line, "$GPVTG") {
-		fields := strings.Split(line, ",")
-		if thisErr = p.parseHeading(fields); thisErr != nil {
-			p.AddError(fmt.Errorf("KiloMoanaParser: bad GPVTG: %v: line=%q", thisErr, line))
+		p.trackMotion(sen, p.last)
+		switch sen.Type {
+		case "GGA":
+			if thisErr = p.parseGeo(sen); thisErr != nil {
+				p.AddError(fmt.Errorf("KiloMoanaParser: bad %sGGA: %w: line=%q", sen.Talker, thisErr, line))
+			}
+		case "VTG":
+			if thisErr = p.parseHeading(sen); thisErr != nil {
+				p.AddError(fmt.Errorf("KiloMoanaParser: bad %sVTG: %w: line=%q", sen.Talker, thisErr, line))
+			}
+		default:
+			// NMEA sentences have no date, use the latest instrument time
+			if thisErr = p.addSentence(sen, p.last); thisErr != nil {
+				p.AddError(fmt.Errorf("KiloMoanaParser: bad %s%s: %w: line=%q", sen.Talker, sen.Type, thisErr, line))
+			}
 		}
 	} else {
 		fields := strings.Fields(line)
@@ -82,43 +468,99 @@ func (p *KiloMoanaParser) ParseLine(line string) (d Data) {
 			if thisErr = p.parseThermo(fields); thisErr != nil {
 				p.AddError(fmt.Errorf("KiloMoanaParser: bad uthsl: %v: line=%q", thisErr, line))
 			}
-		case len(fields) >= 7 && fields[6] == "bar1":
-			// Fill in all non-time, non-lat, non-lon values with NA if needed
-			// and set data.
-			for _, k := range p.metadata.Headers {
-				if k != "time" && k != "lat" && k != "lon" {
-					if val, ok := p.GetValue(k); !ok {
-						p.AddValue(k, tsdata.NA)
-					} else {
-						p.AddValue(k, val)
-					}
-				}
-			}
-			d = p.GetData()
-
-			// Start parsing the next stanza
-			if thisErr = p.parseDate(fields); thisErr != nil {
-				p.AddError(fmt.Errorf("KiloMoanaParser: bad bar1 date: %v: line=%q", thisErr, line))
-			}
 		}
 	}
+}
 
-	return d
+// stamp returns the time of an instrument line and records it as the latest
+// instrument time. It returns zero time if the date stamp can't be parsed.
+func (p *KiloMoanaParser) stamp(fields []string) time.Time {
+	t, err := kmTime(fields)
+	if err != nil {
+		return time.Time{}
+	}
+	p.last = t
+	return t
 }
 
 func (p *KiloMoanaParser) parseDate(fields []string) (err error) {
+	t, err := kmTime(fields)
+	if err != nil {
+		return err
+	}
+	p.SetTime(t)
+	p.last = t
+	return
+}
+
+// kmTime parses the YR DAY HR MIN SEC MSEC date stamp at the start of a Kilo
+// Moana instrument line.
+func kmTime(fields []string) (t time.Time, err error) {
 	if len(fields) < 6 {
-		return fmt.Errorf("bad date fields")
+		return t, fmt.Errorf("bad date fields")
 	}
 	parts := make([]int, 6)
 	for i, f := range fields[:6] {
 		parts[i], err = strconv.Atoi(f)
 		if err != nil {
-			return fmt.Errorf("bad date fields")
+			return t, fmt.Errorf("bad date fields")
 		}
 	}
 	t0 := time.Date(parts[0], time.January, 1, parts[2], parts[3], parts[4], parts[5]*1000000, time.UTC)
-	p.SetTime(t0.Add(time.Duration(24*(parts[1]-1)) * time.Duration(time.Hour)).Round(0))
+	return t0.Add(time.Duration(24*(parts[1]-1)) * time.Duration(time.Hour)).Round(0), nil
+}
+
+// parseFeeds parses an instrument line for all enabled extra feeds that read
+// it, in feed name order.
+func (p *KiloMoanaParser) parseFeeds(fields []string, line string) (extra []Data) {
+	names := make([]string, 0, len(p.feeds))
+	for name := range p.feeds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		feed := kmFeeds[name]
+		for _, inst := range feed.instruments {
+			if fields[6] == inst {
+				extra = append(extra, p.parseFeed(p.feeds[name], name, feed, fields, line))
+				break
+			}
+		}
+	}
+	return extra
+}
+
+// parseFeed parses an instrument line for an extra feed.
+func (p *KiloMoanaParser) parseFeed(dm *DataManager, name string, feed kmFeed, fields []string, line string) (d Data) {
+	d.Feed = name
+	if len(fields) != feed.fields && !(feed.atLeast && len(fields) > feed.fields) {
+		dm.AddError(fmt.Errorf("KiloMoanaParser: bad %s: incorrect field count %d: line=%q", fields[6], len(fields), line))
+		return
+	}
+	t, err := kmTime(fields)
+	if err != nil {
+		dm.AddError(fmt.Errorf("KiloMoanaParser: bad %s date: %v: line=%q", fields[6], err, line))
+		return
+	}
+	for _, c := range feed.columns {
+		val := fields[c.pos]
+		if c.typ == "float" {
+			if _, err := strconv.ParseFloat(val, 64); err != nil {
+				dm.AddError(fmt.Errorf("KiloMoanaParser: bad %s: %v: line=%q", fields[6], err, line))
+				dm.AddInvalid(c.name, val)
+				continue
+			}
+		}
+		dm.AddValue(c.name, val)
+	}
+	if feed.derive != nil {
+		for i, val := range feed.derive(fields) {
+			dm.AddValue(feed.derived[i].name, val)
+		}
+	}
+	dm.SetTime(t)
+	d = dm.GetData()
+	d.Feed = name
 	return
 }
 
@@ -129,7 +571,7 @@ func (p *KiloMoanaParser) parseFluor(fields []string) (err error) {
 	if _, err := strconv.ParseFloat(fields[7], 64); err != nil {
 		return err
 	}
-	p.AddValue("fluor", fields[7])
+	p.AddValueAt("fluor", fields[7], p.stamp(fields))
 	return
 }
 
@@ -145,7 +587,7 @@ func (p *KiloMoanaParser) parsePar(fields []string) (err error) {
 	if _, err := strconv.ParseFloat(fields[18], 64); err != nil {
 		return err
 	}
-	p.AddValue("par", fields[18])
+	p.AddValueAt("par", fields[18], p.stamp(fields))
 	return
 }
 
@@ -158,41 +600,40 @@ func (p *KiloMoanaParser) parseThermo(fields []string) (err error) {
 			return err
 		}
 	}
-	p.AddValue("lab_temp", fields[7])
-	p.AddValue("conductivity", fields[8])
-	p.AddValue("salinity", fields[9])
-	p.AddValue("temp", fields[10])
+	t := p.stamp(fields)
+	p.AddValueAt("lab_temp", fields[7], t)
+	p.AddValueAt("conductivity", fields[8], t)
+	p.AddValueAt("salinity", fields[9], t)
+	p.AddValueAt("temp", fields[10], t)
 	return
 }
 
-func (p *KiloMoanaParser) parseGeo(fields []string) (err error) {
-	if len(fields) != 15 {
-		return fmt.Errorf("incorrect field count %d", len(fields))
-	}
-	latdd, latdderr := geo.GGALat2DD(fields[2], fields[3])
-	if latdderr != nil {
-		return latdderr
+func (p *KiloMoanaParser) parseGeo(sen nmea.Sentence) (err error) {
+	if err = p.checkSentence(sen); err != nil {
+		return err
 	}
-	londd, londderr := geo.GGALon2DD(fields[4], fields[5])
-	if londderr != nil {
-		return londderr
+	gga, err := nmea.ParseGGA(sen)
+	if err != nil {
+		return err
 	}
-	p.AddValue("lat", latdd)
-	p.AddValue("lon", londd)
-	return
+	// NMEA sentences have no date, use the latest instrument time
+	p.AddValueAt("lat", geo.FormatDD(gga.Lat), p.last)
+	p.AddValueAt("lon", geo.FormatDD(gga.Lon), p.last)
+	return p.addGGAFix(gga, p.last)
 }
 
-func (p *KiloMoanaParser) parseHeading(fields []string) (err error) {
-	if len(fields) != 10 {
-		return fmt.Errorf("incorrect field count %d", len(fields))
-	}
-	if _, err := strconv.ParseFloat(fields[1], 64); err != nil { // track
+func (p *KiloMoanaParser) parseHeading(sen nmea.Sentence) (err error) {
+	if err = p.checkSentence(sen); err != nil {
 		return err
 	}
-	if _, err := strconv.ParseFloat(fields[5], 64); err != nil { // knots
+	vtg, err := nmea.ParseVTG(sen)
+	if err != nil {
 		return err
 	}
-	p.AddValue("heading_true_north", fields[1])
-	p.AddValue("knots", fields[5])
+	if !vtg.CourseTrue.Valid || !vtg.SpeedKnots.Valid {
+		return fmt.Errorf("missing course or speed")
+	}
+	p.AddValueAt("heading_true_north", vtg.CourseTrue.String(), p.last)
+	p.AddValueAt("knots", vtg.SpeedKnots.String(), p.last)
 	return
 }
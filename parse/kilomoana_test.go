@@ -282,3 +282,149 @@ func createKMLinesTest(t *testing.T, tt testKMLineData) func(*testing.T) {
 		assert.Equal(tt.expected, store.Feeds, tt.name)
 	}
 }
+
+func TestKMFeeds(t *testing.T) {
+	assert := assert.New(t)
+	input := `2017 168 00 30 28 990 bar1   1016.07 mbar
+2017 168 00 30 28 998 uthsl 19.968599 0.040550 0.217500 27.397800
+$GPGGA,003029.00,2118.9043,N,15752.6526,W,2,7,0.8,27,M,,M,,*78
+2017 168 00 30 29 229 rbgm3 024723 00 978906.152511
+2017 168 00 30 29 365 flor 78.000000
+$GPVTG,47.3,T,37.7,M,0.0,N,0.0,K,D*25
+2017 168 00 30 29 909 met  0.000 28.680  50.900 28.470 24.766  3.758 -0.246  1.097  1.099  0.000 5040.000  1.016 11.9 235.0 11.9   83.3 R-  0.000  0.000
+2017 168 00 30 30 229 rbgm3 024724 00 97890a.152511
+2017 168 00 30 31 229 rbgm3 024725 00
+2017 168 00 30 29 990 bar1   1016.05 mbar
+`
+	p := NewKiloMoanaParser("test", 0, time.Now).(*KiloMoanaParser)
+	err := p.Configure(Options{"feeds": "gravimeter, barometer"})
+	assert.Nil(err)
+	headers := FeedHeaders(p)
+	assert.Len(headers, 3)
+	assert.Contains(headers[KMGravimeterName], "gravity")
+	assert.Contains(headers[KMBarometerName], "pressure")
+
+	store, _ := storage.NewMemStorage()
+	err = ParseLines(p, strings.NewReader(input), store, true, false)
+	assert.Nil(err)
+	assert.Equal(map[string][]string{
+		"geo": {"2017-06-17T00:30:28.99Z\t19.968599\t0.040550\t0.217500\t27.397800\t47.3\t0.0\t78.000000\t1.016\t21.3151\t-157.8775\n"},
+		"gravimeter": {
+			"2017-06-17T00:30:29.229Z\t978906.152511\n",
+			"2017-06-17T00:30:30.229Z\tNA\n",
+		},
+		"barometer": {
+			"2017-06-17T00:30:28.99Z\t1016.07\n",
+			"2017-06-17T00:30:29.99Z\t1016.05\n",
+		},
+	}, store.Feeds)
+
+	// ParseLine only returns underway records
+	d := p.ParseLine("2017 168 00 30 32 229 rbgm3 024726 00 978906.152511\n")
+	assert.False(d.OK())
+
+	assert.NotNil(p.Configure(Options{"feeds": "sonar"}))
+	assert.NotNil(p.Configure(Options{"feed": "gravimeter"}))
+	assert.Nil(p.Configure(Options{}))
+	assert.Len(FeedHeaders(p), 1)
+}
+
+func TestKMMaxAge(t *testing.T) {
+	assert := assert.New(t)
+	input := `2017 168 00 30 28 990 bar1   1016.07 mbar
+2017 168 00 30 28 998 uthsl 19.968599 0.040550 0.217500 27.397800
+$GPGGA,003029.00,2118.9043,N,15752.6526,W,2,7,0.8,27,M,,M,,*78
+2017 168 00 30 29 365 flor 78.000000
+$GPVTG,47.3,T,37.7,M,0.0,N,0.0,K,D*25
+2017 168 00 30 29 909 met  0.000 28.680  50.900 28.470 24.766  3.758 -0.246  1.097  1.099  0.000 5040.000  1.016 11.9 235.0 11.9   83.3 R-  0.000  0.000
+2017 168 00 30 29 990 bar1   1016.05 mbar
+$GPGGA,003029.00,2118.9043,N,15752.6526,W,2,7,0.8,27,M,,M,,*78
+2017 168 00 30 30 909 met  0.000 28.680  50.900 28.470 24.766  3.758 -0.246  1.097  1.099  0.000 5040.000  1.020 11.9 235.0 11.9   83.3 R-  0.000  0.000
+2017 168 00 30 30 990 bar1   1016.05 mbar
+$GPGGA,003029.00,2118.9043,N,15752.6526,W,2,7,0.8,27,M,,M,,*78
+2017 168 00 30 31 990 bar1   1016.05 mbar
+`
+	p := NewKiloMoanaParser("test", 0, time.Now).(*KiloMoanaParser)
+	assert.Nil(p.Configure(Options{"max_age": "1.5s"}))
+	store, _ := storage.NewMemStorage()
+	err := ParseLines(p, strings.NewReader(input), store, true, false)
+	assert.Nil(err)
+	assert.Equal(map[string][]string{
+		"geo": {
+			"2017-06-17T00:30:28.99Z\t19.968599\t0.040550\t0.217500\t27.397800\t47.3\t0.0\t78.000000\t1.016\t21.3151\t-157.8775\n",
+			"2017-06-17T00:30:29.99Z\t19.968599\t0.040550\t0.217500\t27.397800\t47.3\t0.0\t78.000000\t1.020\t21.3151\t-157.8775\n",
+			"2017-06-17T00:30:30.99Z\tNA\tNA\tNA\tNA\tNA\tNA\tNA\t1.020\t21.3151\t-157.8775\n",
+		},
+	}, store.Feeds)
+
+	assert.NotNil(p.Configure(Options{"max_age": "1"}))
+}
+
+func TestKMMetWindFeeds(t *testing.T) {
+	assert := assert.New(t)
+	input := `2017 168 00 30 29 285 rwd1  10 233   0.0  52.5 208.3  10.0  81.3 
+2017 168 00 30 29 285 rwd2  12 236   0.0  52.5 208.3  12.0  84.3 
+2017 168 00 30 29 909 met  0.000 28.680  50.900 28.470 24.766  3.758 -0.246  1.097  1.099  0.000 5040.000  1.016 11.9 235.0 11.9   83.3 R-  0.000  0.000
+2017 168 00 30 30 909 met  0.000 28.680  50.900 28.470 24.766  3.758 -0.246  1.097  1.099  0.000 5040.000  1.016 11.9 235.0 11.9   83.3  0.000  0.000
+2017 168 00 30 31 285 rwd1  10 233   0.0  52.5 208.3
+`
+	p := NewKiloMoanaParser("test", 0, time.Now).(*KiloMoanaParser)
+	assert.Nil(p.Configure(Options{"feeds": "met,wind"}))
+	headers := FeedHeaders(p)
+	assert.Contains(headers[KMMetName], "\tpar\trelative_wind_speed\trelative_wind_direction\ttrue_wind_speed\ttrue_wind_direction")
+	assert.Contains(headers[KMWindName], "\tship_heading\ttrue_speed\ttrue_direction")
+
+	store, _ := storage.NewMemStorage()
+	err := ParseLines(p, strings.NewReader(input), store, true, false)
+	assert.Nil(err)
+	assert.Equal(map[string][]string{
+		"wind": {
+			"2017-06-17T00:30:29.285Z\trwd1\t10\t233\t0.0\t52.5\t208.3\t10.0\t81.3\t10.0\t81.3\n",
+			"2017-06-17T00:30:29.285Z\trwd2\t12\t236\t0.0\t52.5\t208.3\t12.0\t84.3\t12.0\t84.3\n",
+		},
+		"met": {
+			"2017-06-17T00:30:29.909Z\t0.000\t28.680\t50.900\t28.470\t24.766\t3.758\t-0.246\t1.097\t1.099\t0.000\t5040.000\t1.016\t11.9\t235.0\t11.9\t83.3\n",
+			"2017-06-17T00:30:30.909Z\t0.000\t28.680\t50.900\t28.470\t24.766\t3.758\t-0.246\t1.097\t1.099\t0.000\t5040.000\t1.016\t11.9\t235.0\t11.9\t83.3\n",
+		},
+	}, store.Feeds)
+}
+
+func TestKMMalformedStanza(t *testing.T) {
+	assert := assert.New(t)
+	// The second bar1 line is lost
+	input := `2017 168 00 30 28 990 bar1   1016.07 mbar
+2017 168 00 30 28 998 uthsl 19.968599 0.040550 0.217500 27.397800
+$GPGGA,003029.00,2118.9043,N,15752.6526,W,2,7,0.8,27,M,,M,,*78
+2017 168 00 30 29 365 flor 78.000000
+$GPVTG,47.3,T,37.7,M,0.0,N,0.0,K,D*25
+2017 168 00 30 29 909 met  0.000 28.680  50.900 28.470 24.766  3.758 -0.246  1.097  1.099  0.000 5040.000  1.016 11.9 235.0 11.9   83.3 R-  0.000  0.000
+2017 168 00 30 29 998 uthsl 20.968599 0.050550 0.227500 28.397800
+$GPGGA,003030.00,2218.9043,N,15852.6526,W,2,7,0.8,27,M,,M,,*78
+2017 168 00 30 31 990 bar1   1016.05 mbar
+`
+	p := NewKiloMoanaParser("test", 0, time.Now).(*KiloMoanaParser)
+	var errs []string
+	for _, line := range strings.SplitAfter(input, "\n") {
+		d := p.ParseLine(line)
+		assert.False(d.OK())
+		for _, err := range d.Errors {
+			errs = append(errs, err.Error())
+		}
+	}
+	assert.Equal([]string{
+		`KiloMoanaParser: malformed stanza: line 6: duplicate uthsl line, missing bar1 line?: line="2017 168 00 30 28 990 bar1   1016.07 mbar"`,
+	}, errs)
+
+	// Kept and flagged with flag columns
+	p = NewKiloMoanaParser("test", 0, time.Now).(*KiloMoanaParser)
+	p.SetFlagColumns(true)
+	store, _ := storage.NewMemStorage()
+	err := ParseLines(p, strings.NewReader(input), store, true, false)
+	assert.Nil(err)
+	assert.Equal(map[string][]string{
+		"geo": {"2017-06-17T00:30:28.99Z\t20.968599\t3\t0.050550\t3\t0.227500\t3\t28.397800\t3\t47.3\t3\t0.0\t3\t78.000000\t3\t1.016\t3\t22.3151\t3\t-158.8775\t3\n"},
+	}, store.Feeds)
+
+	assert.Nil(p.Configure(Options{"stanza_timeout": "2s"}))
+	assert.NotNil(p.Configure(Options{"stanza_timeout": "x"}))
+}
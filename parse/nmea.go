@@ -0,0 +1,111 @@
+package parse
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ctberthiaume/cruisemic/nmea"
+)
+
+// ChecksumPolicy selects how parsers handle NMEA sentences with a missing or
+// bad checksum.
+type ChecksumPolicy int
+
+const (
+	// ChecksumIgnore does not verify checksums.
+	ChecksumIgnore ChecksumPolicy = iota
+	// ChecksumFlag keeps data from sentences that fail verification and adds
+	// a *nmea.ChecksumError to the parsing errors.
+	ChecksumFlag
+	// ChecksumReject discards sentences that fail verification and adds a
+	// *nmea.ChecksumError to the parsing errors.
+	ChecksumReject
+)
+
+// ChecksumPolicyNames maps command-line names to checksum policies.
+var ChecksumPolicyNames = map[string]ChecksumPolicy{
+	"ignore": ChecksumIgnore,
+	"flag":   ChecksumFlag,
+	"reject": ChecksumReject,
+}
+
+// ParseChecksumPolicy returns the ChecksumPolicy for a command-line name.
+func ParseChecksumPolicy(name string) (ChecksumPolicy, error) {
+	policy, ok := ChecksumPolicyNames[name]
+	if !ok {
+		return ChecksumIgnore, fmt.Errorf("bad checksum policy %q, must be one of ignore, flag, reject", name)
+	}
+	return policy, nil
+}
+
+// ChecksumPolicySetter is implemented by parsers that verify NMEA checksums.
+type ChecksumPolicySetter interface {
+	SetChecksumPolicy(policy ChecksumPolicy)
+}
+
+// SetChecksumPolicy sets the NMEA checksum policy. The default is
+// ChecksumIgnore.
+func (dm *DataManager) SetChecksumPolicy(policy ChecksumPolicy) {
+	dm.checksum = policy
+}
+
+// checkSentence verifies an NMEA sentence checksum according to the checksum
+// policy. With ChecksumFlag failures are added to the DataManager's errors.
+// With ChecksumReject failures are returned as a *nmea.ChecksumError and the
+// sentence should not be used.
+func (dm *DataManager) checkSentence(sen nmea.Sentence) error {
+	if dm.checksum == ChecksumIgnore {
+		return nil
+	}
+	if err := sen.VerifyChecksum(); err != nil {
+		if dm.checksum == ChecksumReject {
+			return err
+		}
+		dm.AddError(err)
+	}
+	return nil
+}
+
+// parseSentence splits an NMEA sentence and verifies its checksum with
+// checkSentence.
+func (dm *DataManager) parseSentence(s string) (nmea.Sentence, error) {
+	sen, err := nmea.Parse(s)
+	if err != nil {
+		return sen, err
+	}
+	return sen, dm.checkSentence(sen)
+}
+
+// decodeGGA decodes s as a GGA sentence from any talker.
+func (dm *DataManager) decodeGGA(s string) (nmea.GGA, error) {
+	sen, err := dm.parseSentence(s)
+	if err != nil {
+		return nmea.GGA{}, err
+	}
+	return nmea.ParseGGA(sen)
+}
+
+// decodeZDA decodes s as a ZDA sentence from any talker.
+func (dm *DataManager) decodeZDA(s string) (nmea.ZDA, error) {
+	sen, err := dm.parseSentence(s)
+	if err != nil {
+		return nmea.ZDA{}, err
+	}
+	return nmea.ParseZDA(sen)
+}
+
+// addSentence adds values from sen for optional attitude, depth, and wind
+// columns. t is the measurement time for Merge. Sentences that aren't used for
+// any configured column are ignored.
+func (dm *DataManager) addSentence(sen nmea.Sentence, t time.Time) error {
+	for _, add := range []func(nmea.Sentence, time.Time) (bool, error){dm.addAttitude, dm.addDepth, dm.addWind} {
+		if used, err := add(sen, t); used {
+			return err
+		}
+	}
+	return nil
+}
+
+// seaflowZDATimeLen is the length of the hhmmss.ss ZDA time field in Thompson
+// $SEAFLOW lines.
+const seaflowZDATimeLen = 9
@@ -0,0 +1,96 @@
+package parse
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ctberthiaume/cruisemic/nmea"
+	"github.com/stretchr/testify/assert"
+)
+
+type testChecksumData struct {
+	name          string
+	parser        string
+	policy        ChecksumPolicy
+	lines         []string
+	expectRecords int
+	expectErrors  int
+}
+
+// Real TN450 line with correct checksums, and the same line with a corrupted
+// GGA latitude.
+var tn448GoodLine = "$SEAFLOW::$GNZDA,192824.00,08,01,2026,00,00*73::$GNGGA,192824.00,0959.090566,N,13112.849121,E,5,18,0.62,72.764,M,0.000,M,75,0000*43:: 29.6849,  5.64749,  33.9515, 1543.859::-0.005\n"
+var tn448BadLine = "$SEAFLOW::$GNZDA,192824.00,08,01,2026,00,00*73::$GNGGA,192824.00,0959.090566,N,13112.949121,E,5,18,0.62,72.764,M,0.000,M,75,0000*43:: 29.6849,  5.64749,  33.9515, 1543.859::-0.005\n"
+
+func TestParseChecksumPolicy(t *testing.T) {
+	assert := assert.New(t)
+	for name, policy := range ChecksumPolicyNames {
+		p, err := ParseChecksumPolicy(name)
+		assert.Nil(err)
+		assert.Equal(policy, p)
+	}
+	_, err := ParseChecksumPolicy("drop")
+	assert.NotNil(err)
+}
+
+func TestChecksumPolicy(t *testing.T) {
+	testData := []testChecksumData{
+		{"TN448 good, reject", "TN448", ChecksumReject, []string{tn448GoodLine}, 1, 0},
+		{"TN448 bad, reject", "TN448", ChecksumReject, []string{tn448BadLine, tn448GoodLine}, 1, 1},
+		{"TN448 bad, flag", "TN448", ChecksumFlag, []string{tn448BadLine}, 1, 1},
+		{"TN448 bad, ignore", "TN448", ChecksumIgnore, []string{tn448BadLine}, 1, 0},
+		{
+			"TARA bad RMC, reject", "TARA", ChecksumReject,
+			[]string{
+				"$GPRMC,160332,A,4743.7694,N,00322.4405,W,0.0,182.6,071225,0.2,W,D*18\n",
+				"$GPRMC,160333,A,4743.7696,N,00322.4403,W,0.0,182.6,071225,0.2,W,D*1C\n",
+			},
+			1, 1,
+		},
+		{
+			"TARA torn GLL is not consumed, reject", "TARA", ChecksumReject,
+			[]string{
+				"$GPGLL,4743.7694,N,00322.4405,W,160332,\n",
+				"$GPRMC,160333,A,4743.7696,N,00322.4403,W,0.0,182.6,071225,0.2,W,D*1C\n",
+			},
+			1, 0,
+		},
+		{
+			"Kilo Moana bad GGA, flag", "Kilo Moana", ChecksumFlag,
+			[]string{
+				"2017 168 00 30 28 990 bar1   1016.07 mbar\n",
+				"$GPGGA,003029.00,2118.9043,N,15752.6526,W,2,7,0.8,27,M,,M,,*79\n",
+				"2017 168 00 30 30 990 bar1   1016.07 mbar\n",
+			},
+			1, 1,
+		},
+	}
+	for _, tt := range testData {
+		t.Run(tt.name, createChecksumTest(t, tt))
+	}
+}
+
+func createChecksumTest(t *testing.T, tt testChecksumData) func(*testing.T) {
+	assert := assert.New(t)
+
+	return func(t *testing.T) {
+		p := ParserRegistry[tt.parser]("test", 0, time.Now)
+		p.(ChecksumPolicySetter).SetChecksumPolicy(tt.policy)
+		records := 0
+		var errs []error
+		for _, line := range tt.lines {
+			d := p.ParseLine(line)
+			if d.OK() {
+				records++
+				errs = append(errs, d.Errors...)
+			}
+		}
+		assert.Equal(tt.expectRecords, records, tt.name)
+		assert.Equal(tt.expectErrors, len(errs), tt.name)
+		for _, err := range errs {
+			var cerr *nmea.ChecksumError
+			assert.True(errors.As(err, &cerr), tt.name)
+		}
+	}
+}
@@ -0,0 +1,381 @@
+package parse
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ctberthiaume/cruisemic/geo"
+	"github.com/ctberthiaume/cruisemic/nmea"
+	"github.com/ctberthiaume/tsdata"
+)
+
+// NMEAParser is a parser for a plain feed of NMEA sentence lines. Columns are
+// fields selected from decoded sentences, e.g. GGA.lat or HDT.heading, and
+// one selected time field sets the record time. Values received since the
+// last record are merged into a record when the epoch sentence arrives, like
+// TARAParser does with GPRMC.
+type NMEAParser struct {
+	DataManager
+	project  string
+	selected []nmeaSelection
+	timeName string    // sentence with the selected time field
+	epoch    string    // sentence that closes a record
+	last     time.Time // time of the latest time sentence
+}
+
+// nmeaSelection is a sentence field selected as a column.
+type nmeaSelection struct {
+	sentence string
+	field    string
+	column   string
+}
+
+// nmeaField describes a column value from a decoded sentence.
+type nmeaField struct {
+	typ     string
+	unit    string
+	comment string
+	value   func(v interface{}) string // output text, tsdata.NA if empty
+}
+
+// nmeaSentence describes the fields that can be selected from a sentence.
+type nmeaSentence struct {
+	time   func(v interface{}) time.Time // UTC date and time, nil if not available
+	fields map[string]nmeaField
+}
+
+// nmeaTimeField is the field name that selects a sentence's date and time as
+// record time.
+const nmeaTimeField = "time"
+
+// nmeaDefaultFields is the default field selection.
+const nmeaDefaultFields = "RMC.time,RMC.lat,RMC.lon,RMC.sog,RMC.cog"
+
+// nmeaSentences are the selectable fields by sentence name. Proprietary
+// sentence names include the P prefix.
+var nmeaSentences = map[string]nmeaSentence{
+	"GGA": {fields: map[string]nmeaField{
+		"lat":        {"float", "deg", "Latitude Decimal format", func(v interface{}) string { return geo.FormatDD(v.(nmea.GGA).Lat) }},
+		"lon":        {"float", "deg", "Longitude Decimal format", func(v interface{}) string { return geo.FormatDD(v.(nmea.GGA).Lon) }},
+		"quality":    {"integer", tsdata.NA, "GGA fix quality, 0 invalid", func(v interface{}) string { return nmeaInt(v.(nmea.GGA).Quality) }},
+		"satellites": {"integer", tsdata.NA, "Satellites in use", func(v interface{}) string { return nmeaInt(v.(nmea.GGA).Satellites) }},
+		"hdop":       {"float", tsdata.NA, "Horizontal dilution of precision", func(v interface{}) string { return floatText(v.(nmea.GGA).HDOP) }},
+		"altitude":   {"float", "m", "Antenna altitude above mean sea level", func(v interface{}) string { return floatText(v.(nmea.GGA).Altitude) }},
+	}},
+	"RMC": {
+		time: func(v interface{}) time.Time { return v.(nmea.RMC).Time },
+		fields: map[string]nmeaField{
+			"lat":    {"float", "deg", "Latitude Decimal format", func(v interface{}) string { return geo.FormatDD(v.(nmea.RMC).Lat) }},
+			"lon":    {"float", "deg", "Longitude Decimal format", func(v interface{}) string { return geo.FormatDD(v.(nmea.RMC).Lon) }},
+			"sog":    {"float", "kn", "Speed over ground", func(v interface{}) string { return floatText(v.(nmea.RMC).SpeedKnots) }},
+			"cog":    {"float", "deg", "Course over ground, true", func(v interface{}) string { return floatText(v.(nmea.RMC).Course) }},
+			"status": {"text", tsdata.NA, "RMC status, A valid, V warning", func(v interface{}) string { return nmeaText(v.(nmea.RMC).Status) }},
+		},
+	},
+	"ZDA": {time: func(v interface{}) time.Time { return v.(nmea.ZDA).Time }},
+	"VTG": {fields: map[string]nmeaField{
+		"sog": {"float", "kn", "Speed over ground", func(v interface{}) string { return floatText(v.(nmea.VTG).SpeedKnots) }},
+		"cog": {"float", "deg", "Course over ground, true", func(v interface{}) string { return floatText(v.(nmea.VTG).CourseTrue) }},
+	}},
+	"GLL": {fields: map[string]nmeaField{
+		"lat": {"float", "deg", "Latitude Decimal format", func(v interface{}) string { return geo.FormatDD(v.(nmea.GLL).Lat) }},
+		"lon": {"float", "deg", "Longitude Decimal format", func(v interface{}) string { return geo.FormatDD(v.(nmea.GLL).Lon) }},
+	}},
+	"HDT": {fields: map[string]nmeaField{
+		"heading": {"float", "deg", "True heading", func(v interface{}) string { return floatText(v.(nmea.HDT).Heading) }},
+	}},
+	"HDG": {fields: map[string]nmeaField{
+		"heading": {"float", "deg", "True heading from magnetic heading, deviation, and variation", func(v interface{}) string {
+			if h, ok := v.(nmea.HDG).TrueHeading(); ok {
+				return formatHeading(h)
+			}
+			return tsdata.NA
+		}},
+	}},
+	"ROT": {fields: map[string]nmeaField{
+		"rate_of_turn": {"float", "deg/min", "Rate of turn, negative to port", func(v interface{}) string {
+			if rot := v.(nmea.ROT); rot.Status != "V" {
+				return floatText(rot.Rate)
+			}
+			return tsdata.NA
+		}},
+	}},
+	"PASHR": {fields: map[string]nmeaField{
+		"heading": {"float", "deg", "True heading", func(v interface{}) string { return floatText(v.(nmea.PASHR).Heading) }},
+		"roll":    {"float", "deg", "Roll, positive port side up", func(v interface{}) string { return floatText(v.(nmea.PASHR).Roll) }},
+		"pitch":   {"float", "deg", "Pitch, positive bow up", func(v interface{}) string { return floatText(v.(nmea.PASHR).Pitch) }},
+		"heave":   {"float", "m", "Heave", func(v interface{}) string { return floatText(v.(nmea.PASHR).Heave) }},
+	}},
+	"DBT": {fields: map[string]nmeaField{
+		"depth": {"float", "m", "Depth below transducer", func(v interface{}) string {
+			if m, ok := v.(nmea.DBT).DepthMeters(); ok {
+				return strconv.FormatFloat(m, 'f', 2, 64)
+			}
+			return tsdata.NA
+		}},
+	}},
+	"DPT": {fields: map[string]nmeaField{
+		"depth": {"float", "m", "Depth below transducer", func(v interface{}) string { return floatText(v.(nmea.DPT).Depth) }},
+	}},
+	"PSKPDPT": {fields: map[string]nmeaField{
+		"depth": {"float", "m", "Depth below transducer", func(v interface{}) string { return floatText(v.(nmea.PSKPDPT).Depth) }},
+	}},
+	"PKEL99": {fields: map[string]nmeaField{
+		"depth": {"float", "m", "Depth below transducer, high frequency if detected", func(v interface{}) string {
+			if depth, ok := v.(nmea.PKEL99).Depth(); ok {
+				return depth.Text
+			}
+			return tsdata.NA
+		}},
+	}},
+	"MWV": {fields: map[string]nmeaField{
+		"wind_speed": {"float", "kn", "Wind speed", func(v interface{}) string {
+			if s, ok := v.(nmea.MWV).SpeedKnots(); ok {
+				return formatWind(s)
+			}
+			return tsdata.NA
+		}},
+		"wind_angle": {"float", "deg", "Wind angle clockwise from the bow", func(v interface{}) string { return floatText(v.(nmea.MWV).Angle) }},
+		"wind_reference": {"text", tsdata.NA, "Wind reference, R relative, T theoretical", func(v interface{}) string {
+			return nmeaText(v.(nmea.MWV).Reference)
+		}},
+	}},
+}
+
+func init() {
+	Register(Registration{
+		Name:        "NMEA",
+		Description: "Generic NMEA sentence feed, columns selected with -option fields",
+		Cruises:     "any",
+		Example:     nmeaExample,
+		New:         NewNMEAParser,
+		NoDetect:    true,
+	})
+}
+
+// nmeaExample is example NMEA feed input.
+const nmeaExample = `$GPRMC,160331,A,4743.7690,N,00322.4408,W,0.0,182.6,071225,0.2,W,D*13
+$GPZDA,160331,07,12,2025,00,00*4F
+$GPGGA,160332,4743.7694,N,00322.4405,W,2,09,1.6,-10.2,M,,M,,*56
+$HEHDT,182.1,T*25
+$GPRMC,160332,A,4743.7694,N,00322.4405,W,0.0,182.6,071225,0.2,W,D*19
+$GPVTG,182.6,T,182.8,M,0.0,N,0.0,K,D*28
+$GPZDA,160332,07,12,2025,00,00*4C
+`
+
+// NewNMEAParser returns a pointer to an NMEAParser struct with the default
+// field selection, RMC time, lat, lon, sog, and cog closed by RMC. project is
+// the project or cruise name. interval is the per-feed rate limiting interval
+// in seconds.
+func NewNMEAParser(project string, interval time.Duration, now func() time.Time) Parser {
+	_ = now // now is not used in this function
+
+	p := &NMEAParser{
+		DataManager: *NewDataManager(tsdata.Tsdata{}, interval),
+		project:     project,
+	}
+	if err := p.selectFields(nmeaDefaultFields, ""); err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// Configure sets the field selection, the epoch sentence, and the maximum age
+// of merged values. Valid options are fields, a comma separated list of
+// <sentence>.<field> names with exactly one time field, e.g.
+// ZDA.time,GGA.lat,GGA.lon,VTG.sog,HDT.heading, epoch, the name of the
+// sentence that closes a record (default the time field's sentence), and
+// max_age. Sentences match any talker ID.
+func (p *NMEAParser) Configure(opts Options) (err error) {
+	if err = opts.Check("fields", "epoch", "max_age"); err != nil {
+		return fmt.Errorf("NMEAParser: %w", err)
+	}
+	maxAge, err := opts.Duration("max_age", p.MaxAge())
+	if err != nil {
+		return fmt.Errorf("NMEAParser: %w", err)
+	}
+	p.SetMaxAge(maxAge)
+	return p.selectFields(opts.String("fields", nmeaDefaultFields), opts.String("epoch", ""))
+}
+
+// selectFields sets selected columns and the time and epoch sentences from a
+// comma separated field list. An empty epoch is the time field's sentence.
+func (p *NMEAParser) selectFields(list string, epoch string) error {
+	var selected []nmeaSelection
+	timeName := ""
+	count := make(map[string]int) // selections by field name
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		parts := strings.SplitN(item, ".", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("NMEAParser: bad field %q, expected <sentence>.<field>", item)
+		}
+		name, field := strings.ToUpper(parts[0]), parts[1]
+		s, ok := nmeaSentences[name]
+		if !ok {
+			return fmt.Errorf("NMEAParser: unknown sentence %q, expected one of %s", parts[0], strings.Join(nmeaSentenceNames(), ", "))
+		}
+		if field == nmeaTimeField && s.time != nil {
+			if timeName != "" {
+				return fmt.Errorf("NMEAParser: more than one time field in %q", list)
+			}
+			timeName = name
+			continue
+		}
+		if _, ok := s.fields[field]; !ok {
+			return fmt.Errorf("NMEAParser: unknown field %q for %s, expected one of %s", field, name, strings.Join(nmeaFieldNames(s), ", "))
+		}
+		for _, sel := range selected {
+			if sel.sentence == name && sel.field == field {
+				return fmt.Errorf("NMEAParser: duplicate field %q", item)
+			}
+		}
+		selected = append(selected, nmeaSelection{sentence: name, field: field, column: field})
+		count[field]++
+	}
+	if timeName == "" {
+		return fmt.Errorf("NMEAParser: no time field in %q, add RMC.time or ZDA.time", list)
+	}
+	if epoch == "" {
+		epoch = timeName
+	}
+	epoch = strings.ToUpper(strings.TrimSpace(epoch))
+	if _, ok := nmeaSentences[epoch]; !ok {
+		return fmt.Errorf("NMEAParser: unknown epoch sentence %q, expected one of %s", epoch, strings.Join(nmeaSentenceNames(), ", "))
+	}
+
+	metadata := tsdata.Tsdata{
+		Project:         p.project,
+		FileType:        "geo",
+		FileDescription: "NMEA underway feed",
+		Comments:        []string{"RFC3339"},
+		Types:           []string{"time"},
+		Units:           []string{tsdata.NA},
+		Headers:         []string{"time"},
+	}
+	for i := range selected {
+		sel := &selected[i]
+		// Fields with the same name from different sentences are
+		// distinguished by sentence, e.g. gga_lat and rmc_lat.
+		if count[sel.field] > 1 {
+			sel.column = strings.ToLower(sel.sentence) + "_" + sel.field
+		}
+		f := nmeaSentences[sel.sentence].fields[sel.field]
+		metadata.Headers = append(metadata.Headers, sel.column)
+		metadata.Types = append(metadata.Types, f.typ)
+		metadata.Units = append(metadata.Units, f.unit)
+		metadata.Comments = append(metadata.Comments, f.comment+" from "+sel.sentence)
+	}
+	p.selected = selected
+	p.timeName = timeName
+	p.epoch = epoch
+	p.SetMetadata(metadata)
+	return nil
+}
+
+// ParseLine parses a single underway feed line. Only lines ending with \n are
+// examined.
+func (p *NMEAParser) ParseLine(line string) (d Data) {
+	// Discard empty or incomplete lines
+	if len(line) == 0 || line[len(line)-1] != '\n' {
+		return
+	}
+
+	// Remove trailing \n for parsing
+	line = line[:len(line)-1]
+
+	sen, err := nmea.Parse(line)
+	if err != nil {
+		return
+	}
+	name := sen.Type
+	if sen.Talker == "P" {
+		name = "P" + name
+	}
+	if err = p.parseSentence(name, sen); err != nil {
+		p.AddError(fmt.Errorf("NMEAParser: bad %s%s: %w: line=%q", sen.Talker, sen.Type, err, line))
+	}
+	if name != p.epoch {
+		return
+	}
+	// Values from the epoch sentence are required, others are NA if they
+	// haven't been received since the last record.
+	var required []string
+	for _, sel := range p.selected {
+		if sel.sentence == name {
+			required = append(required, sel.column)
+		}
+	}
+	p.Merge(required...)
+
+	return p.GetData()
+}
+
+// parseSentence adds the time and selected values from sentence name. Values
+// are timed by the latest time sentence.
+func (p *NMEAParser) parseSentence(name string, sen nmea.Sentence) error {
+	used := name == p.timeName
+	for _, sel := range p.selected {
+		used = used || sel.sentence == name
+	}
+	if !used {
+		return nil
+	}
+	if err := p.checkSentence(sen); err != nil {
+		return err
+	}
+	v, err := nmea.DecodeSentence(sen)
+	if err != nil {
+		return err
+	}
+	if name == p.timeName {
+		p.last = nmeaSentences[name].time(v)
+		p.SetTime(p.last)
+	}
+	for _, sel := range p.selected {
+		if sel.sentence == name {
+			p.AddValueAt(sel.column, nmeaSentences[name].fields[sel.field].value(v), p.last)
+		}
+	}
+	return nil
+}
+
+// nmeaInt returns i as text, or tsdata.NA if -1.
+func nmeaInt(i int) string {
+	if i < 0 {
+		return tsdata.NA
+	}
+	return strconv.Itoa(i)
+}
+
+// nmeaText returns s, or tsdata.NA if empty.
+func nmeaText(s string) string {
+	if s == "" {
+		return tsdata.NA
+	}
+	return s
+}
+
+// nmeaSentenceNames returns sorted selectable sentence names.
+func nmeaSentenceNames() (names []string) {
+	for name := range nmeaSentences {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// nmeaFieldNames returns sorted selectable field names for s.
+func nmeaFieldNames(s nmeaSentence) (names []string) {
+	if s.time != nil {
+		names = append(names, nmeaTimeField)
+	}
+	for name := range s.fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,90 @@
+package parse
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ctberthiaume/cruisemic/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNMEAParserDefault(t *testing.T) {
+	assert := assert.New(t)
+	p := NewNMEAParser("test", 0, time.Now)
+	assert.Contains(p.Header(), "time\tlat\tlon\tsog\tcog")
+	store, _ := storage.NewMemStorage()
+	err := ParseLines(p, strings.NewReader(nmeaExample), store, true, false)
+	assert.Nil(err)
+	assert.Equal(map[string][]string{
+		"geo": {
+			"2025-12-07T16:03:31Z\t47.7295\t-3.3740\t0.0\t182.6\n",
+			"2025-12-07T16:03:32Z\t47.7295\t-3.3740\t0.0\t182.6\n",
+		},
+	}, store.Feeds)
+}
+
+func TestNMEAParserFields(t *testing.T) {
+	assert := assert.New(t)
+	input := `$GPZDA,160332,07,12,2025,00,00*4C
+$GPGGA,160332,4743.7694,N,00322.4405,W,2,09,1.6,-10.2,M,,M,,*56
+$HEHDT,182.1,T*25
+$GPVTG,180.0,T,180.2,M,5.2,N,9.6,K,D*2C
+$GPZDA,160333,07,12,2025,00,00*4D
+$GPGGA,160333,4743.7700,N,00322.4400,W,2,09,1.6,-10.2,M,,M,,*5E
+$GPVTG,181.0,T,181.2,M,5.4,N,10.0,K,D*14
+$GPVTG,181.0,T,181.2,M,5.4,N,10.0,K,D*00
+`
+	p := NewNMEAParser("test", 0, time.Now).(*NMEAParser)
+	err := p.Configure(Options{"fields": "ZDA.time,GGA.lat,GGA.lon,VTG.sog,HDT.heading", "epoch": "VTG"})
+	assert.Nil(err)
+	p.SetChecksumPolicy(ChecksumReject)
+	assert.Contains(p.Header(), "time\tlat\tlon\tsog\theading")
+	store, _ := storage.NewMemStorage()
+	err = ParseLines(p, strings.NewReader(input), store, true, false)
+	assert.Nil(err)
+	assert.Equal(map[string][]string{
+		"geo": {
+			"2025-12-07T16:03:32Z\t47.7295\t-3.3740\t5.2\t182.1\n",
+			// No HDT since the last record
+			"2025-12-07T16:03:33Z\t47.7295\t-3.3740\t5.4\tNA\n",
+		},
+	}, store.Feeds)
+
+	// Fields with the same name from different sentences
+	err = p.Configure(Options{"fields": "RMC.time,RMC.lat,GGA.lat,GGA.quality"})
+	assert.Nil(err)
+	assert.Contains(p.Header(), "time\trmc_lat\tgga_lat\tquality")
+	assert.Equal("RMC", p.epoch)
+
+	// Proprietary sentences
+	err = p.Configure(Options{"fields": "RMC.time,PSKPDPT.depth,PKEL99.depth"})
+	assert.Nil(err)
+	assert.Contains(p.Header(), "time\tpskpdpt_depth\tpkel99_depth")
+	p.SetChecksumPolicy(ChecksumIgnore)
+	store, _ = storage.NewMemStorage()
+	err = ParseLines(p, strings.NewReader("$PKEL99,3.5,4633.52,1,12.0,,0,1500*00\n$GPRMC,160332,A,4743.7694,N,00322.4405,W,0.0,182.6,071225,0.2,W,D*19\n"), store, true, false)
+	assert.Nil(err)
+	assert.Equal([]string{"2025-12-07T16:03:32Z\tNA\t4633.52\n"}, store.Feeds["geo"])
+
+	// HDG heading is formatted like the attitude heading column
+	err = p.Configure(Options{"fields": "RMC.time,HDG.heading"})
+	assert.Nil(err)
+	store, _ = storage.NewMemStorage()
+	err = ParseLines(p, strings.NewReader("$HCHDG,358.5,1.0,W,3.5,E*00\n$GPRMC,160332,A,4743.7694,N,00322.4405,W,0.0,182.6,071225,0.2,W,D*19\n"), store, true, false)
+	assert.Nil(err)
+	assert.Equal([]string{"2025-12-07T16:03:32Z\t1.00\n"}, store.Feeds["geo"])
+
+	for _, opts := range []Options{
+		{"fields": "GGA.lat,GGA.lon"},
+		{"fields": "RMC.time,ZDA.time"},
+		{"fields": "RMC.time,XYZ.lat"},
+		{"fields": "RMC.time,GGA.speed"},
+		{"fields": "RMC.time,GGA.lat,GGA.lat"},
+		{"fields": "RMC.time,lat"},
+		{"fields": "RMC.time", "epoch": "XYZ"},
+		{"epoch": "GGA", "fields": "RMC.time", "other": "1"},
+	} {
+		assert.NotNil(p.Configure(opts), opts)
+	}
+}
@@ -0,0 +1,103 @@
+package parse
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Options holds parser specific settings as key value strings, e.g. from
+// repeated -option key=value command-line flags.
+type Options map[string]string
+
+// Configurable is implemented by parsers that accept Options.
+type Configurable interface {
+	Configure(opts Options) error
+}
+
+// ParseOptions creates Options from a list of key=value strings.
+func ParseOptions(kvs []string) (Options, error) {
+	opts := make(Options)
+	for _, kv := range kvs {
+		parts := strings.SplitN(kv, "=", 2)
+		key := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || key == "" {
+			return nil, fmt.Errorf("bad option %q, expected key=value", kv)
+		}
+		opts[key] = parts[1]
+	}
+	return opts, nil
+}
+
+// Check returns an error if opts contains a key not in known.
+func (o Options) Check(known ...string) error {
+	var unknown []string
+	for k := range o {
+		found := false
+		for _, kn := range known {
+			if k == kn {
+				found = true
+				break
+			}
+		}
+		if !found {
+			unknown = append(unknown, k)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unknown options %v, valid options are %v", unknown, known)
+	}
+	return nil
+}
+
+// String returns the value for key, or def if key is not set.
+func (o Options) String(key, def string) string {
+	if v, ok := o[key]; ok {
+		return v
+	}
+	return def
+}
+
+// Int returns the integer value for key, or def if key is not set.
+func (o Options) Int(key string, def int) (int, error) {
+	v, ok := o[key]
+	if !ok {
+		return def, nil
+	}
+	i, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil {
+		return 0, fmt.Errorf("bad integer option %s=%q", key, v)
+	}
+	return i, nil
+}
+
+// Bool returns the boolean value for key, or def if key is not set. Values are
+// parsed with strconv.ParseBool.
+func (o Options) Bool(key string, def bool) (bool, error) {
+	v, ok := o[key]
+	if !ok {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(strings.TrimSpace(v))
+	if err != nil {
+		return false, fmt.Errorf("bad boolean option %s=%q", key, v)
+	}
+	return b, nil
+}
+
+// Duration returns the time.ParseDuration value for key, or def if key is not
+// set.
+func (o Options) Duration(key string, def time.Duration) (time.Duration, error) {
+	v, ok := o[key]
+	if !ok {
+		return def, nil
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(v))
+	if err != nil {
+		return 0, fmt.Errorf("bad duration option %s=%q", key, v)
+	}
+	return d, nil
+}
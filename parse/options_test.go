@@ -0,0 +1,55 @@
+package parse
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseOptions(t *testing.T) {
+	assert := assert.New(t)
+	opts, err := ParseOptions([]string{"tsg.prefix=$TSG", "par.field= 2", "empty="})
+	assert.Nil(err)
+	assert.Equal(Options{"tsg.prefix": "$TSG", "par.field": " 2", "empty": ""}, opts)
+	assert.Equal("$TSG", opts.String("tsg.prefix", "x"))
+	assert.Equal("x", opts.String("missing", "x"))
+	i, err := opts.Int("par.field", 1)
+	assert.Nil(err)
+	assert.Equal(2, i)
+	i, err = opts.Int("missing", 1)
+	assert.Nil(err)
+	assert.Equal(1, i)
+	_, err = opts.Int("tsg.prefix", 1)
+	assert.NotNil(err)
+
+	opts["par.required"] = " true"
+	b, err := opts.Bool("par.required", false)
+	assert.Nil(err)
+	assert.True(b)
+	b, err = opts.Bool("missing", true)
+	assert.Nil(err)
+	assert.True(b)
+	_, err = opts.Bool("tsg.prefix", false)
+	assert.NotNil(err)
+	delete(opts, "par.required")
+
+	opts["max_age"] = "1.5s"
+	d, err := opts.Duration("max_age", 0)
+	assert.Nil(err)
+	assert.Equal(1500*time.Millisecond, d)
+	d, err = opts.Duration("missing", time.Second)
+	assert.Nil(err)
+	assert.Equal(time.Second, d)
+	_, err = opts.Duration("tsg.prefix", 0)
+	assert.NotNil(err)
+	delete(opts, "max_age")
+
+	assert.Nil(opts.Check("tsg.prefix", "par.field", "empty"))
+	assert.NotNil(opts.Check("tsg.prefix"))
+
+	for _, bad := range []string{"novalue", "=value"} {
+		_, err = ParseOptions([]string{bad})
+		assert.NotNil(err, bad)
+	}
+}
@@ -8,10 +8,10 @@ import (
 	"fmt"
 	"io"
 	"log"
-	"strings"
 	"time"
 
 	"github.com/ctberthiaume/cruisemic/storage"
+	"github.com/ctberthiaume/tsdata"
 )
 
 // RawName is the string designator for unparsed text data sent to storage
@@ -20,6 +20,9 @@ const RawName = "raw"
 // UnderwayName is the string designator for parsed underway text data sent to storage
 const UnderwayName = "geo"
 
+// RecvTimeName is the column name for the host clock time a line was received
+const RecvTimeName = "recv_time"
+
 // Parser is the interface that groups the ParseLine and RateLimit used to
 // parse a ship's underway feed.
 type Parser interface {
@@ -28,8 +31,67 @@ type Parser interface {
 	Limit(d *Data)
 }
 
+// MultiFeedParser is a Parser that writes records to feeds other than
+// UnderwayName, e.g. one feed per instrument. ParseLine only returns
+// UnderwayName records.
+type MultiFeedParser interface {
+	Parser
+	// FeedHeaders returns Tsdata header paragraphs by feed name for all feeds
+	// written, including UnderwayName.
+	FeedHeaders() map[string]string
+	// ParseLineFeeds parses a single line and returns records for all feeds.
+	// Data.Feed is set to the feed name for each record.
+	ParseLineFeeds(line string) []Data
+}
+
+// FeedHeaders returns Tsdata header paragraphs by feed name for all feeds
+// written by parser.
+func FeedHeaders(parser Parser) map[string]string {
+	if mp, ok := parser.(MultiFeedParser); ok {
+		return mp.FeedHeaders()
+	}
+	return map[string]string{UnderwayName: parser.Header()}
+}
+
+// RecvTimeHeaders returns a copy of Tsdata header paragraphs by feed name with
+// a RecvTimeName column after the time column, for use with ParseLinesRecv.
+// RawName headers are copied unchanged.
+func RecvTimeHeaders(headers map[string]string) (map[string]string, error) {
+	out := make(map[string]string, len(headers))
+	for feed, header := range headers {
+		if feed == RawName {
+			out[feed] = header
+			continue
+		}
+		var md tsdata.Tsdata
+		if err := md.ParseHeader(header); err != nil {
+			return nil, fmt.Errorf("bad %s header: %v", feed, err)
+		}
+		md.Headers = insert(md.Headers, 1, RecvTimeName)
+		md.Types = insert(md.Types, 1, "time")
+		md.Units = insert(md.Units, 1, tsdata.NA)
+		md.Comments = insert(md.Comments, 1, "RFC3339 host clock time the line was received")
+		out[feed] = md.Header()
+	}
+	return out, nil
+}
+
+// insert returns s with v inserted at index i.
+func insert(s []string, i int, v string) []string {
+	return append(s[:i:i], append([]string{v}, s[i:]...)...)
+}
+
 // ParseLines parses cruise feed lines and saves data to storage
 func ParseLines(parser Parser, r io.Reader, storer storage.Storer, flushFlag bool, noCleanFlag bool) (err error) {
+	return ParseLinesRecv(parser, r, storer, flushFlag, noCleanFlag, nil)
+}
+
+// ParseLinesRecv is like ParseLines, but also writes the time each record's
+// final line was received in a RecvTimeName column after the time column.
+// recv is called for each line to get its receive time, e.g. the host clock
+// time a UDP datagram arrived. If recv is nil no RecvTimeName column is
+// written. Storage headers should come from RecvTimeHeaders.
+func ParseLinesRecv(parser Parser, r io.Reader, storer storage.Storer, flushFlag bool, noCleanFlag bool, recv func() time.Time) (err error) {
 	scanner := bufio.NewScanner(r)
 	scanner.Split(scanLinesWithLF)
 	for scanner.Scan() {
@@ -42,15 +104,31 @@ func ParseLines(parser Parser, r io.Reader, storer storage.Storer, flushFlag boo
 
 		line := string(b[:n])
 
-		d := parser.ParseLine(line)
-		for _, err := range d.Errors {
-			log.Printf("%v", err)
+		var records []Data
+		if mp, ok := parser.(MultiFeedParser); ok {
+			records = mp.ParseLineFeeds(line)
+		} else {
+			records = []Data{parser.ParseLine(line)}
 		}
-		if d.OK() {
-			// Save data if properly parsed and not throttled
-			err = storer.WriteString(UnderwayName, d.Line("\t")+"\n")
-			if err != nil {
-				return fmt.Errorf("error writing parsed data: %v", err)
+		var recvTime time.Time
+		if recv != nil {
+			recvTime = recv().UTC()
+		}
+		for _, d := range records {
+			d.RecvTime = recvTime
+			for _, err := range d.Errors {
+				log.Printf("%v", err)
+			}
+			if d.OK() {
+				// Save data if properly parsed and not throttled
+				feed := d.Feed
+				if feed == "" {
+					feed = UnderwayName
+				}
+				err = storer.WriteString(feed, d.Line("\t")+"\n")
+				if err != nil {
+					return fmt.Errorf("error writing parsed data: %v", err)
+				}
 			}
 		}
 
@@ -96,22 +174,3 @@ func scanLinesWithLF(data []byte, atEOF bool) (advance int, token []byte, err er
 	// Request more data.
 	return 0, nil, nil
 }
-
-// ParserRegistry allows underway parser constructors to be retrieved by name.
-var ParserRegistry = map[string]func(string, time.Duration, func() time.Time) Parser{
-	"Gradients4": NewGradients4Parser,
-	"Gradients5": NewGradients5Parser,
-	"Kilo Moana": NewKiloMoanaParser,
-	"TN427":      NewTN427Parser,
-	"TARA":       NewTARAParser,
-	"TN448":      NewTN448Parser,
-}
-
-// RegistryChoices returns keys for ParserRegistry one per line.
-func RegistryChoices() string {
-	var choices []string
-	for k := range ParserRegistry {
-		choices = append(choices, k)
-	}
-	return strings.Join(choices, "\n")
-}
@@ -0,0 +1,46 @@
+package parse
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ctberthiaume/cruisemic/storage"
+	"github.com/ctberthiaume/tsdata"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLinesRecv(t *testing.T) {
+	assert := assert.New(t)
+	p := NewTN427Parser("test", 0, time.Now)
+	recvTime := time.Date(2023, 1, 12, 13, 33, 11, 500000000, time.FixedZone("PST", -8*60*60))
+	store, _ := storage.NewMemStorage()
+	input := `$SEAFLOW::$GPZDA,213309.00,12,01,2023,00,00*6D::$GPGGA,213309.00,4738.983141,N,12218.805824,W,2,17,0.7,15.773,M,-22.2,M,7.0,0402*44:: 12.3719,  3.64868,  31.2816::157.580
+`
+	err := ParseLinesRecv(p, strings.NewReader(input), store, true, false, func() time.Time { return recvTime })
+	assert.Nil(err)
+	assert.Equal(map[string][]string{
+		"geo": {"2023-01-12T21:33:09Z\t2023-01-12T21:33:11.5Z\t47.6497\t-122.3134\t12.3719\t3.64868\t31.2816\t157.580\n"},
+	}, store.Feeds)
+}
+
+func TestRecvTimeHeaders(t *testing.T) {
+	assert := assert.New(t)
+	p := NewKiloMoanaParser("test", 0, time.Now)
+	assert.Nil(p.(Configurable).Configure(Options{"feeds": "barometer"}))
+	headers := FeedHeaders(p)
+	headers[RawName] = ""
+	out, err := RecvTimeHeaders(headers)
+	assert.Nil(err)
+	assert.Equal("", out[RawName])
+	for _, feed := range []string{UnderwayName, KMBarometerName} {
+		var before, after tsdata.Tsdata
+		assert.Nil(before.ParseHeader(headers[feed]))
+		assert.Nil(after.ParseHeader(out[feed]))
+		assert.Equal(append([]string{"time", RecvTimeName}, before.Headers[1:]...), after.Headers)
+		assert.Equal("time", after.Types[1])
+	}
+
+	_, err = RecvTimeHeaders(map[string]string{UnderwayName: "bad"})
+	assert.NotNil(err)
+}
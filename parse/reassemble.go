@@ -0,0 +1,69 @@
+package parse
+
+import (
+	"bytes"
+	"time"
+)
+
+// maxFragmentSize is the largest incomplete line a Reassembler will hold for
+// one source.
+const maxFragmentSize = 1 << 16
+
+// Reassembler rebuilds feed lines that are split across UDP datagrams. It
+// holds any incomplete trailing line from each source, e.g. sender
+// "address:port", and prepends it to that source's next datagram.
+type Reassembler struct {
+	timeout   time.Duration
+	fragments map[string]fragment
+}
+
+// fragment is an incomplete line and the time it was received.
+type fragment struct {
+	data []byte
+	t    time.Time
+}
+
+// NewReassembler returns a pointer to a Reassembler. Fragments held for
+// longer than timeout are discarded.
+func NewReassembler(timeout time.Duration) *Reassembler {
+	return &Reassembler{
+		timeout:   timeout,
+		fragments: make(map[string]fragment),
+	}
+}
+
+// Add adds datagram data received from source at time t. It returns all
+// complete \n terminated lines now available for source, and any stale
+// fragments that were discarded from all sources.
+func (r *Reassembler) Add(source string, data []byte, t time.Time) (lines []byte, discarded [][]byte) {
+	discarded = r.Expire(t)
+
+	buf := data
+	if frag, ok := r.fragments[source]; ok {
+		buf = append(frag.data, data...)
+		delete(r.fragments, source)
+	}
+
+	i := bytes.LastIndexByte(buf, '\n')
+	rest := buf[i+1:]
+	if len(rest) > 0 {
+		if len(rest) > maxFragmentSize {
+			discarded = append(discarded, rest)
+		} else {
+			// Copy to avoid holding on to or modifying the caller's buffer
+			r.fragments[source] = fragment{data: append([]byte(nil), rest...), t: t}
+		}
+	}
+	return buf[:i+1], discarded
+}
+
+// Expire discards and returns fragments received more than timeout before t.
+func (r *Reassembler) Expire(t time.Time) (discarded [][]byte) {
+	for source, frag := range r.fragments {
+		if t.Sub(frag.t) > r.timeout {
+			discarded = append(discarded, frag.data)
+			delete(r.fragments, source)
+		}
+	}
+	return discarded
+}
@@ -0,0 +1,59 @@
+package parse
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ctberthiaume/cruisemic/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReassembler(t *testing.T) {
+	assert := assert.New(t)
+	t0 := time.Date(2025, 12, 7, 16, 3, 32, 0, time.UTC)
+	r := NewReassembler(2 * time.Second)
+
+	lines, discarded := r.Add("10.0.0.1:5000", []byte("$GPGLL,4743.7694,N,00322.4405,W,160332,"), t0)
+	assert.Equal("", string(lines))
+	assert.Empty(discarded)
+
+	// A second source doesn't interfere with the first
+	lines, _ = r.Add("10.0.0.2:5000", []byte("a\nb"), t0)
+	assert.Equal("a\n", string(lines))
+
+	lines, discarded = r.Add("10.0.0.1:5000", []byte("A,D*5B\r\n$GPVTG,182.6"), t0.Add(time.Second))
+	assert.Equal("$GPGLL,4743.7694,N,00322.4405,W,160332,A,D*5B\r\n", string(lines))
+	assert.Empty(discarded)
+
+	// Both held fragments are stale now
+	lines, discarded = r.Add("10.0.0.1:5000", []byte(",T,182.8,M,0.0,N,0.0,K,D*28\r\n"), t0.Add(4*time.Second))
+	assert.Equal(",T,182.8,M,0.0,N,0.0,K,D*28\r\n", string(lines))
+	assert.ElementsMatch([]string{"b", "$GPVTG,182.6"}, []string{string(discarded[0]), string(discarded[1])})
+
+	lines, discarded = r.Add("10.0.0.1:5000", []byte(strings.Repeat("x", maxFragmentSize+1)), t0)
+	assert.Equal("", string(lines))
+	assert.Len(discarded, 1)
+	assert.Empty(r.fragments)
+}
+
+func TestReassemblerTARA(t *testing.T) {
+	assert := assert.New(t)
+	datagrams := []string{
+		"$GPGLL,4743.7694,N,00322.4405,W,160332,",
+		"A,D*5B\r\n$GPRMC,160333,A,4743.7696,N,00322.4403,W,0.0,18",
+		"2.6,071225,0.2,W,D*1C\r\n",
+	}
+	r := NewReassembler(time.Second)
+	p := NewTARAParser("test", 0, time.Now)
+	p.(ChecksumPolicySetter).SetChecksumPolicy(ChecksumReject)
+	store, _ := storage.NewMemStorage()
+	for _, dg := range datagrams {
+		lines, _ := r.Add("10.0.0.1:5000", []byte(dg), time.Now())
+		err := ParseLines(p, strings.NewReader(string(lines)), store, true, false)
+		assert.Nil(err)
+	}
+	assert.Equal(map[string][]string{
+		"geo": {"2025-12-07T16:03:33Z\t47.7295\t-3.3740\tNA\tNA\tNA\tNA\n"},
+	}, store.Feeds)
+}
@@ -0,0 +1,136 @@
+package parse
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Constructor creates a Parser. project is the project or cruise name.
+// interval is the per-feed rate limiting interval. now returns the current
+// time for parsers that timestamp with the host clock.
+type Constructor func(project string, interval time.Duration, now func() time.Time) Parser
+
+// Registration describes a parser registered with Register.
+type Registration struct {
+	Name        string      // name used with -parser
+	Description string      // short description of the feed
+	Cruises     string      // ships or cruises the parser supports
+	Aliases     []string    // alternate names used with -parser
+	Example     string      // example feed input, which should produce records
+	New         Constructor // parser constructor
+	NoDetect    bool        // needs -option settings for a feed, not used by -parser auto
+}
+
+// registrations holds all registered parsers by name.
+var registrations = map[string]Registration{}
+
+// ParserRegistry allows underway parser constructors to be retrieved by name
+// or alias. It is populated by Register.
+var ParserRegistry = map[string]Constructor{}
+
+// Register makes a parser available by name and aliases. It should be called
+// from the init function of the file that defines the parser. Register panics
+// if a name or alias is already registered, or if New is nil.
+func Register(r Registration) {
+	if r.New == nil {
+		panic("parse: Register constructor is nil for " + r.Name)
+	}
+	names := append([]string{r.Name}, r.Aliases...)
+	for _, name := range names {
+		if name == "" || name == AutoParser || strings.HasPrefix(name, DefinitionPrefix) {
+			panic(fmt.Sprintf("parse: Register called with reserved name %q", name))
+		}
+		if _, dup := ParserRegistry[name]; dup {
+			panic("parse: Register called twice for " + name)
+		}
+	}
+	for _, name := range names {
+		ParserRegistry[name] = r.New
+	}
+	registrations[r.Name] = r
+}
+
+// Lookup returns the registration for a parser name or alias.
+func Lookup(name string) (Registration, bool) {
+	if r, ok := registrations[name]; ok {
+		return r, true
+	}
+	for _, r := range registrations {
+		for _, alias := range r.Aliases {
+			if alias == name {
+				return r, true
+			}
+		}
+	}
+	return Registration{}, false
+}
+
+// Registrations returns all registered parsers sorted by name.
+func Registrations() []Registration {
+	regs := make([]Registration, 0, len(registrations))
+	for _, r := range registrations {
+		regs = append(regs, r)
+	}
+	sort.Slice(regs, func(i, j int) bool { return regs[i].Name < regs[j].Name })
+	return regs
+}
+
+// RegistryChoices returns a table of registered parsers sorted by name, one
+// per line, with aliases, supported cruises, and descriptions.
+func RegistryChoices() string {
+	var b bytes.Buffer
+	w := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tALIASES\tCRUISES\tDESCRIPTION")
+	for _, r := range Registrations() {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Name, strings.Join(r.Aliases, ","), r.Cruises, r.Description)
+	}
+	w.Flush()
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// SelfTestResult is the result of running a parser against its example
+// input.
+type SelfTestResult struct {
+	Name    string
+	Records int   // valid records parsed
+	Errors  int   // errors in valid records
+	Err     error // non-nil if the self test failed
+}
+
+func (r SelfTestResult) String() string {
+	status := "ok"
+	if r.Err != nil {
+		status = "FAIL: " + r.Err.Error()
+	}
+	return fmt.Sprintf("%s\trecords=%d\terrors=%d\t%s", r.Name, r.Records, r.Errors, status)
+}
+
+// SelfTest runs every registered parser against its example input and checks
+// that it produces records. Results are sorted by name.
+func SelfTest() (results []SelfTestResult) {
+	for _, r := range Registrations() {
+		res := SelfTestResult{Name: r.Name}
+		if r.Example == "" {
+			res.Err = fmt.Errorf("no example input")
+			results = append(results, res)
+			continue
+		}
+		lines, err := scanLines(strings.NewReader(r.Example), false)
+		if err != nil {
+			res.Err = err
+			results = append(results, res)
+			continue
+		}
+		c := scoreParser(r.Name, r.New, lines)
+		res.Records, res.Errors = c.Records, c.Errors
+		if res.Records == 0 {
+			res.Err = fmt.Errorf("no records parsed from example")
+		}
+		results = append(results, res)
+	}
+	return results
+}
@@ -0,0 +1,56 @@
+package parse
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelfTest(t *testing.T) {
+	assert := assert.New(t)
+	results := SelfTest()
+	assert.Len(results, len(Registrations()))
+	for _, r := range results {
+		assert.Nil(r.Err, r.Name)
+		assert.Greater(r.Records, 0, r.Name)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	assert := assert.New(t)
+	r, ok := Lookup("KM")
+	assert.True(ok)
+	assert.Equal("Kilo Moana", r.Name)
+	r, ok = Lookup("TN427")
+	assert.True(ok)
+	assert.Equal("TN427", r.Name)
+	_, ok = Lookup("Thompson")
+	assert.False(ok)
+
+	// Aliases are available in ParserRegistry
+	_, ok = ParserRegistry["G5"]("test", 0, time.Now).(*Gradients5Parser)
+	assert.True(ok)
+}
+
+func TestRegistrations(t *testing.T) {
+	assert := assert.New(t)
+	regs := Registrations()
+	for i := 1; i < len(regs); i++ {
+		assert.Less(regs[i-1].Name, regs[i].Name)
+	}
+	choices := strings.Split(RegistryChoices(), "\n")
+	assert.Len(choices, len(regs)+1)
+	assert.True(strings.HasPrefix(choices[1], "Gradients4"))
+}
+
+func TestRegisterPanics(t *testing.T) {
+	assert := assert.New(t)
+	assert.Panics(func() { Register(Registration{Name: "TN427", New: NewTN427Parser}) }, "duplicate name")
+	assert.Panics(func() { Register(Registration{Name: "New", Aliases: []string{"KM"}, New: NewTN427Parser}) }, "duplicate alias")
+	assert.Panics(func() { Register(Registration{Name: "auto", New: NewTN427Parser}) }, "reserved name")
+	assert.Panics(func() { Register(Registration{Name: "New"}) }, "nil constructor")
+	_, ok := ParserRegistry["New"]
+	assert.False(ok)
+}
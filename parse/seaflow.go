@@ -0,0 +1,363 @@
+package parse
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ctberthiaume/cruisemic/geo"
+	"github.com/ctberthiaume/cruisemic/nmea"
+	"github.com/ctberthiaume/tsdata"
+)
+
+// SeaflowPARFormat is the format of the PAR field in a $SEAFLOW line.
+type SeaflowPARFormat int
+
+const (
+	// SeaflowPARBare is a bare PAR number, e.g. "157.580"
+	SeaflowPARBare SeaflowPARFormat = iota
+	// SeaflowPARPPAR is a $PPAR sentence with PAR in the first field, e.g.
+	// "$PPAR, 157.580, 6.10, 5"
+	SeaflowPARPPAR
+)
+
+// SeaflowConfig describes one variant of the Thompson $SEAFLOW::ZDA::GGA::TSG::PAR
+// underway feed.
+type SeaflowConfig struct {
+	Name            string // parser name for error messages
+	Talker          string // expected GNSS talker ID, e.g. GP
+	FileDescription string
+	RequireNewline  bool  // only examine lines that end with \n
+	TSGFields       []int // allowed counts of comma separated TSG subfields
+	SoundVelocity   bool  // write the fourth TSG subfield as sound_velocity
+	PARFormat       SeaflowPARFormat
+	PARRequired     bool // reject lines without PAR
+	PARDecimals     int  // decimal places in a complete PAR number, -1 for any
+	PARReject       bool // reject lines with bad PAR rather than flag PAR
+	PARSubfields    bool // write all $PPAR subfields
+}
+
+// SeaflowParser is a parser for Thompson $SEAFLOW underway feed lines. Each
+// line has five "::" separated fields: "$SEAFLOW", a ZDA sentence, a GGA
+// sentence, comma separated TSG temperature, conductivity, and salinity, and
+// PAR. Other NMEA sentences in the feed are used for optional columns, and
+// are merged into the next $SEAFLOW record.
+type SeaflowParser struct {
+	DataManager
+	config SeaflowConfig
+	last   time.Time // time of the latest $SEAFLOW line
+}
+
+// NewSeaflowParser returns a pointer to a SeaflowParser struct for the feed
+// variant described by config. project is the project or cruise name.
+// interval is the per-feed rate limiting interval in seconds.
+func NewSeaflowParser(config SeaflowConfig, project string, interval time.Duration) *SeaflowParser {
+	return &SeaflowParser{
+		DataManager: *NewDataManager(seaflowMetadata(config, project), interval),
+		config:      config,
+	}
+}
+
+// seaflowMetadata returns Tsdata metadata for the feed variant described by
+// config.
+func seaflowMetadata(config SeaflowConfig, project string) tsdata.Tsdata {
+	metadata := tsdata.Tsdata{
+		Project:         project,
+		FileType:        "geo",
+		FileDescription: config.FileDescription,
+		Comments:        []string{"RFC3339", "Latitude Decimal format", "Longitude Decimal format", "TSG temperature", "TSG conductivity", "TSG salinity", "PAR"},
+		Types:           []string{"time", "float", "float", "float", "float", "float", "float"},
+		Units:           []string{"NA", "deg", "deg", "C", "S/m", "PSU", "µE/m^2/s"},
+		Headers:         []string{"time", "lat", "lon", "temp", "conductivity", "salinity", "par"},
+	}
+	if config.SoundVelocity {
+		metadata.Comments = insert(metadata.Comments, 6, "TSG sound velocity")
+		metadata.Types = insert(metadata.Types, 6, "float")
+		metadata.Units = insert(metadata.Units, 6, "m/s")
+		metadata.Headers = insert(metadata.Headers, 6, "sound_velocity")
+	}
+	if config.PARSubfields {
+		metadata.Comments = append(metadata.Comments, "PAR sensor voltage or temperature", "PAR sensor count or status")
+		metadata.Types = append(metadata.Types, "float", "integer")
+		metadata.Units = append(metadata.Units, "NA", "NA")
+		metadata.Headers = append(metadata.Headers, "par_sensor", "par_status")
+	}
+	return metadata
+}
+
+// Configure sets tsg.fields, a comma separated list of allowed TSG subfield
+// counts, tsg.sound_velocity, which adds a sound_velocity column for the
+// fourth TSG subfield, par.required, par.decimals, the number of decimal
+// places in a complete PAR number or -1 for any, par.reject, which rejects
+// lines with bad PAR instead of flagging PAR, and par.subfields, which adds
+// par_sensor and par_status columns for the other $PPAR subfields.
+func (p *SeaflowParser) Configure(opts Options) (err error) {
+	if err = opts.Check("tsg.fields", "tsg.sound_velocity", "par.required", "par.decimals", "par.reject", "par.subfields"); err != nil {
+		return fmt.Errorf("%s: %w", p.config.Name, err)
+	}
+	if v, ok := opts["tsg.fields"]; ok {
+		var counts []int
+		for _, s := range strings.Split(v, ",") {
+			n, err := strconv.Atoi(strings.TrimSpace(s))
+			if err != nil || n < 3 {
+				return fmt.Errorf("%s: bad option tsg.fields=%q, expected counts >= 3", p.config.Name, v)
+			}
+			counts = append(counts, n)
+		}
+		p.config.TSGFields = counts
+	}
+	if p.config.SoundVelocity, err = opts.Bool("tsg.sound_velocity", p.config.SoundVelocity); err != nil {
+		return fmt.Errorf("%s: %w", p.config.Name, err)
+	}
+	if p.config.PARRequired, err = opts.Bool("par.required", p.config.PARRequired); err != nil {
+		return fmt.Errorf("%s: %w", p.config.Name, err)
+	}
+	if p.config.PARDecimals, err = opts.Int("par.decimals", p.config.PARDecimals); err != nil {
+		return fmt.Errorf("%s: %w", p.config.Name, err)
+	}
+	if p.config.PARDecimals < -1 {
+		return fmt.Errorf("%s: par.decimals must be >= -1", p.config.Name)
+	}
+	if p.config.PARReject, err = opts.Bool("par.reject", p.config.PARReject); err != nil {
+		return fmt.Errorf("%s: %w", p.config.Name, err)
+	}
+	if p.config.PARSubfields, err = opts.Bool("par.subfields", p.config.PARSubfields); err != nil {
+		return fmt.Errorf("%s: %w", p.config.Name, err)
+	}
+	if p.config.PARSubfields && p.config.PARFormat != SeaflowPARPPAR {
+		return fmt.Errorf("%s: par.subfields requires $PPAR sentences", p.config.Name)
+	}
+	p.SetMetadata(seaflowMetadata(p.config, p.metadata.Project))
+	return nil
+}
+
+// SetGPSFix sets GPS fix columns and policy for GGA positions.
+func (p *SeaflowParser) SetGPSFix(columns bool, policy FixPolicy, maxHDOP float64) {
+	p.setGPSFix("GGA", columns, policy, maxHDOP)
+}
+
+// SetAttitude sets whether attitude columns are written.
+func (p *SeaflowParser) SetAttitude(on bool) {
+	p.setAttitude(on)
+}
+
+// SetDepth sets whether a depth column is written, and the transducer offset.
+func (p *SeaflowParser) SetDepth(on bool, offset float64) {
+	p.setDepth(on, offset)
+}
+
+// SetWind sets whether wind columns are written.
+func (p *SeaflowParser) SetWind(on bool) {
+	p.setWind(on)
+}
+
+// ParseLine parses a single underway feed line. If the feed variant requires
+// it, only lines ending with \n are examined.
+func (p *SeaflowParser) ParseLine(line string) (d Data) {
+	if len(line) == 0 {
+		return
+	}
+	if p.config.RequireNewline {
+		if line[len(line)-1] != '\n' {
+			return
+		}
+		// Remove trailing \n for parsing
+		line = line[:len(line)-1]
+	}
+
+	// Trim leading and trailing whitespace
+	clean := strings.TrimSpace(line)
+
+	if !strings.HasPrefix(clean, "$SEAFLOW") {
+		p.parseOther(clean)
+		return
+	}
+
+	fields := strings.Split(clean, "::")
+
+	if len(fields) != 5 {
+		return
+	}
+
+	// Parse time
+	zda, err := p.decodeZDA(fields[1])
+	if err != nil {
+		p.AddError(fmt.Errorf("%s: bad %sZDA: %w: line=%q", p.config.Name, p.config.Talker, err, clean))
+		return
+	}
+	if len(zda.Fields[0]) != seaflowZDATimeLen {
+		p.AddError(fmt.Errorf("%s: bad %sZDA: line=%q", p.config.Name, p.config.Talker, clean))
+		return
+	}
+	if zda.Talker != p.config.Talker {
+		// Feed variants differ in GNSS talker ID, so this tells similar
+		// variants apart without rejecting the line.
+		p.AddError(fmt.Errorf("%s: unexpected %sZDA talker, expected %s: line=%q", p.config.Name, zda.Talker, p.config.Talker, clean))
+	}
+
+	// Latitude and Longitude
+	gga, err := p.decodeGGA(fields[2])
+	if err != nil {
+		p.AddError(fmt.Errorf("%s: bad %sGGA: %w: line=%q", p.config.Name, p.config.Talker, err, clean))
+		return
+	}
+	p.AddValue("lat", geo.FormatDD(gga.Lat))
+	p.AddValue("lon", geo.FormatDD(gga.Lon))
+	if err := p.addGGAFix(gga, time.Time{}); err != nil {
+		p.AddError(fmt.Errorf("%s: bad %sGGA: %w: line=%q", p.config.Name, p.config.Talker, err, clean))
+		return
+	}
+
+	p.parseTSG(fields[3], clean, line)
+	if !p.parsePAR(fields[4], clean, line) {
+		return
+	}
+
+	p.SetTime(zda.Time)
+	p.last = zda.Time
+	// Fill optional columns without a sentence since the last record
+	p.Merge("lat", "lon")
+	return p.GetData()
+}
+
+// parseOther adds values from an NMEA sentence other than $SEAFLOW for
+// optional columns. Sentences are timed by the $SEAFLOW line before them.
+func (p *SeaflowParser) parseOther(clean string) {
+	sen, err := nmea.Parse(clean)
+	if err != nil {
+		return
+	}
+	p.trackMotion(sen, p.last)
+	if err := p.addSentence(sen, p.last); err != nil {
+		p.AddError(fmt.Errorf("%s: bad %s%s: %w: line=%q", p.config.Name, sen.Talker, sen.Type, err, clean))
+	}
+}
+
+// parseTSG adds temperature, conductivity, salinity, and if configured sound
+// velocity from the TSG field. Sound velocity is NA if there are only three
+// subfields.
+func (p *SeaflowParser) parseTSG(field string, clean string, line string) {
+	keys := []string{"temp", "conductivity", "salinity"}
+	if p.config.SoundVelocity {
+		keys = append(keys, "sound_velocity")
+	}
+	tsgFields := strings.Split(field, ",")
+	if !p.tsgFieldsOK(len(tsgFields)) {
+		p.AddError(fmt.Errorf("%s: bad TSG: line=%q", p.config.Name, clean))
+		for _, k := range keys {
+			if strings.TrimSpace(field) == "" {
+				p.AddValue(k, tsdata.NA)
+			} else {
+				p.AddInvalid(k, field)
+			}
+		}
+		return
+	}
+	for i, k := range keys {
+		if i >= len(tsgFields) {
+			p.AddValue(k, tsdata.NA)
+			continue
+		}
+		str := strings.TrimSpace(tsgFields[i])
+		if _, floatErr := strconv.ParseFloat(str, 64); floatErr != nil {
+			p.AddError(fmt.Errorf("%s: bad float: line=%q", p.config.Name, line))
+			p.AddInvalid(k, str)
+		} else {
+			p.AddValue(k, str)
+		}
+	}
+}
+
+func (p *SeaflowParser) tsgFieldsOK(n int) bool {
+	for _, count := range p.config.TSGFields {
+		if n == count {
+			return true
+		}
+	}
+	return false
+}
+
+// parsePAR adds PAR, and if configured the other $PPAR subfields, from the
+// PAR field. It returns false if the line should be rejected.
+//
+// Keep the line if PAR is simply not present, PAR feed may have stopped
+// entirely and we should keep all other values if possible, unless PAR is
+// required. PAR that is present but can't be parsed or has the wrong number
+// of decimal places, e.g. a truncated number, is flagged as bad or suspect.
+// On G5 only about 1 in 4 PAR numbers was complete, so rejecting these lines,
+// as with par.reject, loses most records when PAR is flaky. Truncated numbers
+// are wrong, so without flag columns to mark them they're written as NA.
+func (p *SeaflowParser) parsePAR(field string, clean string, line string) bool {
+	var parField string
+	var subfields []string
+	present := false
+	switch p.config.PARFormat {
+	case SeaflowPARPPAR:
+		if parFields := strings.Split(field, ","); len(parFields) >= 2 {
+			parField, present = parFields[1], true
+			subfields = parFields[2:]
+		}
+	default:
+		parField, present = field, field != ""
+	}
+	if p.config.PARSubfields {
+		p.parsePARSubfields(subfields, line)
+	}
+	if !present {
+		p.AddError(fmt.Errorf("%s: bad PPAR: line=%q", p.config.Name, clean))
+		if p.config.PARRequired {
+			return false
+		}
+		p.AddValue("par", tsdata.NA)
+		return true
+	}
+
+	parStr := strings.TrimSpace(parField)
+	_, floatErr := strconv.ParseFloat(parStr, 64)
+	if floatErr == nil && p.parPrecisionOK(parField) {
+		p.AddValue("par", parStr)
+		return true
+	}
+	p.AddError(fmt.Errorf("%s: bad PAR float: line=%q", p.config.Name, line))
+	if p.config.PARReject && !p.FlagColumns() {
+		p.AddInvalid("par", parStr)
+		return false
+	}
+	// A truncated number is suspect, a number that can't be parsed is bad.
+	if floatErr == nil && p.FlagColumns() {
+		p.AddSuspect("par", parStr)
+	} else {
+		p.AddInvalid("par", parStr)
+	}
+	return true
+}
+
+// parsePARSubfields adds par_sensor and par_status from the $PPAR subfields
+// after PAR. Missing subfields are NA.
+func (p *SeaflowParser) parsePARSubfields(subfields []string, line string) {
+	for i, k := range []string{"par_sensor", "par_status"} {
+		if i >= len(subfields) || strings.TrimSpace(subfields[i]) == "" {
+			p.AddValue(k, tsdata.NA)
+			continue
+		}
+		str := strings.TrimSpace(subfields[i])
+		p.AddValue(k, str)
+		if !p.values[k].OK() {
+			p.AddError(fmt.Errorf("%s: bad PPAR %s: line=%q", p.config.Name, k, line))
+		}
+	}
+}
+
+// parPrecisionOK returns true if parField has the configured number of decimal
+// places.
+func (p *SeaflowParser) parPrecisionOK(parField string) bool {
+	if p.config.PARDecimals < 0 {
+		return true
+	}
+	parNumberFields := strings.Split(parField, ".")
+	if p.config.PARDecimals == 0 {
+		return len(parNumberFields) == 1
+	}
+	return len(parNumberFields) == 2 && len(parNumberFields[1]) == p.config.PARDecimals
+}
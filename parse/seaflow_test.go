@@ -0,0 +1,106 @@
+package parse
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ctberthiaume/cruisemic/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeaflowConfigure(t *testing.T) {
+	header := "$SEAFLOW::$GPZDA,213309.00,12,01,2023,00,00*6D::$GPGGA,213309.00,4738.983141,N,12218.805824,W,2,17,0.7,15.773,M,-22.2,M,7.0,0402*44::"
+	testData := []struct {
+		name     string
+		opts     Options
+		input    string
+		expected map[string][]string
+	}{
+		{
+			"any PAR precision",
+			Options{"par.decimals": "-1"},
+			header + " 12.3719,  3.64868,  31.2816::157.5\n",
+			map[string][]string{
+				"geo": {"2023-01-12T21:33:09Z\t47.6497\t-122.3134\t12.3719\t3.64868\t31.2816\t157.5\n"},
+			},
+		},
+		{
+			"integer PAR",
+			Options{"par.decimals": "0", "par.reject": "true"},
+			header + " 12.3719,  3.64868,  31.2816::157\n" + header + " 12.3719,  3.64868,  31.2816::157.580\n",
+			map[string][]string{
+				"geo": {"2023-01-12T21:33:09Z\t47.6497\t-122.3134\t12.3719\t3.64868\t31.2816\t157\n"},
+			},
+		},
+		{
+			"required PAR",
+			Options{"par.required": "true"},
+			header + " 12.3719,  3.64868,  31.2816::\n",
+			map[string][]string{},
+		},
+		{
+			"sound velocity",
+			Options{"tsg.sound_velocity": "true"},
+			header + " 12.3719,  3.64868,  31.2816, 1501.506::157.580\n" + header + " 12.3720,  3.64869,  31.2817::157.580\n",
+			map[string][]string{
+				"geo": {
+					"2023-01-12T21:33:09Z\t47.6497\t-122.3134\t12.3719\t3.64868\t31.2816\t1501.506\t157.580\n",
+					"2023-01-12T21:33:09Z\t47.6497\t-122.3134\t12.3720\t3.64869\t31.2817\tNA\t157.580\n",
+				},
+			},
+		},
+		{
+			"only 3 TSG fields",
+			Options{"tsg.fields": "3"},
+			header + " 12.3719,  3.64868,  31.2816, 1501.506::157.580\n",
+			map[string][]string{
+				"geo": {"2023-01-12T21:33:09Z\t47.6497\t-122.3134\tNA\tNA\tNA\t157.580\n"},
+			},
+		},
+	}
+	for _, tt := range testData {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			p := NewTN427Parser("test", 0, time.Now).(*TN427Parser)
+			assert.Nil(p.Configure(tt.opts))
+			store, _ := storage.NewMemStorage()
+			err := ParseLines(p, strings.NewReader(tt.input), store, true, false)
+			assert.Nil(err)
+			assert.Equal(tt.expected, store.Feeds)
+		})
+	}
+
+	p := NewGradients5Parser("test", 0, time.Now).(*Gradients5Parser)
+	assert.NotContains(t, p.Header(), "sound_velocity")
+	assert.Nil(t, p.Configure(Options{"tsg.sound_velocity": "true"}))
+	assert.Contains(t, p.Header(), "TSG salinity\tTSG sound velocity\tPAR")
+	assert.NotNil(t, p.Configure(Options{"tsg.sound_velocity": "yes please"}), "bad boolean")
+	assert.NotNil(t, NewTN427Parser("test", 0, time.Now).(Configurable).Configure(Options{"par.subfields": "true"}), "no $PPAR")
+	assert.NotNil(t, p.Configure(Options{"par.prefix": "$PPAR"}), "unknown option")
+	assert.NotNil(t, p.Configure(Options{"tsg.fields": "2"}), "too few TSG fields")
+	assert.NotNil(t, p.Configure(Options{"tsg.fields": "3,a"}), "bad TSG field count")
+	assert.NotNil(t, p.Configure(Options{"par.required": "maybe"}), "bad boolean")
+	assert.NotNil(t, p.Configure(Options{"par.decimals": "-2"}), "bad decimals")
+}
+
+func TestSeaflowPARSubfields(t *testing.T) {
+	assert := assert.New(t)
+	p := NewGradients5Parser("test", 0, time.Now).(*Gradients5Parser)
+	assert.Nil(p.Configure(Options{"par.subfields": "true"}))
+	assert.Contains(p.Header(), "par\tpar_sensor\tpar_status")
+	input := `$SEAFLOW::$GPZDA,213309.00,12,01,2023,00,00*6D::$GPGGA,213309.00,4738.983141,N,12218.805824,W,2,17,0.7,15.773,M,-22.2,M,7.0,0402*44::::$PPAR, 157.580, 6.10, 5
+$SEAFLOW::$GPZDA,213310.00,12,01,2023,00,00*65::$GPGGA,213310.00,4738.983143,N,12218.805821,W,2,17,0.7,15.774,M,-22.2,M,8.0,0402*43::::$PPAR, 157.3
+$SEAFLOW::$GPZDA,213311.00,12,01,2023,00,00*64::$GPGGA,213311.00,4738.983147,N,12218.805822,W,2,17,0.7,15.776,M,-22.2,M,5.0,0402*4A::::$PPAR, 157.445, 5.a, 5.5
+`
+	store, _ := storage.NewMemStorage()
+	err := ParseLines(p, strings.NewReader(input), store, true, false)
+	assert.Nil(err)
+	assert.Equal(map[string][]string{
+		"geo": {
+			"2023-01-12T21:33:09Z\t47.6497\t-122.3134\tNA\tNA\tNA\t157.580\t6.10\t5\n",
+			"2023-01-12T21:33:10Z\t47.6497\t-122.3134\tNA\tNA\tNA\tNA\tNA\tNA\n",
+			"2023-01-12T21:33:11Z\t47.6497\t-122.3134\tNA\tNA\tNA\t157.445\tNA\tNA\n",
+		},
+	}, store.Feeds)
+}
@@ -0,0 +1,129 @@
+package parse
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// StanzaValidator checks a line added to a stanza. previous holds the stanza
+// lines before line, starting with the start marker line.
+type StanzaValidator func(line string, previous []string) error
+
+// StanzaConfig describes the stanzas of a multi-line feed.
+type StanzaConfig struct {
+	Start      func(line string) bool // start marker line
+	End        func(line string) bool // end marker line, nil if stanzas end at the next start marker
+	MinLines   int                    // minimum line count after the start marker, 0 for no minimum
+	MaxLines   int                    // maximum line count after the start marker, 0 for no maximum
+	TrimBlank  bool                   // drop trailing blank lines before counting
+	Validators []StanzaValidator      // checks for every line after the start marker
+	Timeout    time.Duration          // host clock limit from start marker to last line, 0 for none
+}
+
+// Stanza is a multi-line record assembled by a StanzaAssembler.
+type Stanza struct {
+	Lines []string  // lines from the start marker line on
+	Start time.Time // host time the start marker line was received
+	Err   error     // why the stanza is malformed, nil if it isn't
+}
+
+// StanzaAssembler groups feed lines into stanzas. A stanza begins with a
+// start marker line and ends with an end marker line, or if there is no end
+// marker, with the next start marker line. Lines outside a stanza are
+// discarded. Stanzas with the wrong number of lines, a line that fails
+// validation, or that take longer than the timeout are returned with Err
+// set, so that parsers can discard them, or keep and flag them, instead of
+// silently shifting values into the wrong columns.
+type StanzaAssembler struct {
+	config  StanzaConfig
+	now     func() time.Time
+	current Stanza
+	open    bool // current stanza has started
+}
+
+// NewStanzaAssembler returns a pointer to a StanzaAssembler struct. now
+// returns the current host time, used for stanza start times and timeouts.
+func NewStanzaAssembler(config StanzaConfig, now func() time.Time) *StanzaAssembler {
+	return &StanzaAssembler{config: config, now: now}
+}
+
+// SetTimeout sets the stanza timeout, 0 for none.
+func (a *StanzaAssembler) SetTimeout(timeout time.Duration) {
+	a.config.Timeout = timeout
+}
+
+// Add adds a line without its line ending. It returns a stanza and true when
+// a stanza ends, or times out. A stanza times out when one of its lines is
+// received more than the timeout after its start marker line. That line is
+// discarded.
+func (a *StanzaAssembler) Add(line string) (s Stanza, ok bool) {
+	switch {
+	case a.config.Start(line):
+		if a.open {
+			if a.config.End != nil {
+				a.fail(fmt.Errorf("no end marker before next start marker"))
+			}
+			s, ok = a.finish()
+		}
+		a.current = Stanza{Lines: []string{line}, Start: a.now()}
+		a.open = true
+	case !a.open:
+		// Discard lines outside a stanza
+	default:
+		if a.timedOut(line) {
+			a.fail(fmt.Errorf("timed out after %v", a.config.Timeout))
+			return a.finish()
+		}
+		for _, v := range a.config.Validators {
+			if err := v(line, a.current.Lines); err != nil {
+				a.fail(fmt.Errorf("line %d: %w", len(a.current.Lines), err))
+			}
+		}
+		a.current.Lines = append(a.current.Lines, line)
+		if a.config.End != nil && a.config.End(line) {
+			s, ok = a.finish()
+		}
+	}
+	return s, ok
+}
+
+// timedOut returns true if line is received too long after the start marker
+// of the current stanza. Blank lines never time out when trailing blank lines
+// are dropped.
+func (a *StanzaAssembler) timedOut(line string) bool {
+	if a.config.Timeout <= 0 || (a.config.TrimBlank && strings.TrimSpace(line) == "") {
+		return false
+	}
+	return a.now().Sub(a.current.Start) > a.config.Timeout
+}
+
+// fail records err as the reason the current stanza is malformed, unless an
+// earlier reason was recorded.
+func (a *StanzaAssembler) fail(err error) {
+	if a.current.Err == nil {
+		a.current.Err = err
+	}
+}
+
+// finish checks the line count of the current stanza and returns it.
+func (a *StanzaAssembler) finish() (Stanza, bool) {
+	lines := a.current.Lines
+	n := len(lines) - 1
+	if a.config.TrimBlank {
+		for n > 0 && strings.TrimSpace(lines[n]) == "" {
+			n--
+		}
+		a.current.Lines = lines[:n+1]
+	}
+	if a.config.MinLines > 0 && n < a.config.MinLines {
+		a.fail(fmt.Errorf("%d lines after start marker, expected at least %d", n, a.config.MinLines))
+	}
+	if a.config.MaxLines > 0 && n > a.config.MaxLines {
+		a.fail(fmt.Errorf("%d lines after start marker, expected at most %d", n, a.config.MaxLines))
+	}
+	s := a.current
+	a.current = Stanza{}
+	a.open = false
+	return s, true
+}
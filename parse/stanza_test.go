@@ -0,0 +1,99 @@
+package parse
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStanzaAssembler(t *testing.T) {
+	assert := assert.New(t)
+	t0 := time.Date(2022, 5, 27, 0, 0, 0, 0, time.UTC)
+	now := t0
+	clock := func() time.Time { return now }
+	config := StanzaConfig{
+		Start:     func(line string) bool { return line == "start" },
+		End:       func(line string) bool { return line == "end" },
+		MinLines:  3,
+		MaxLines:  4,
+		TrimBlank: true,
+		Validators: []StanzaValidator{
+			func(line string, previous []string) error {
+				if len(previous) == 1 && line != "a" {
+					return fmt.Errorf("expected a")
+				}
+				return nil
+			},
+		},
+	}
+	a := NewStanzaAssembler(config, clock)
+	add := func(lines string) (stanzas []Stanza) {
+		for _, line := range strings.Split(lines, "\n") {
+			if s, ok := a.Add(line); ok {
+				stanzas = append(stanzas, s)
+			}
+		}
+		return stanzas
+	}
+
+	// Lines outside a stanza are discarded
+	s := add("x\nstart\na\n\nend")
+	assert.Len(s, 1)
+	assert.Nil(s[0].Err)
+	assert.Equal([]string{"start", "a", "", "end"}, s[0].Lines)
+	assert.Equal(t0, s[0].Start)
+
+	s = add("start\nb\nc\nend")
+	assert.Len(s, 1)
+	assert.EqualError(s[0].Err, "line 1: expected a")
+
+	s = add("start\na\nend")
+	assert.EqualError(s[0].Err, "2 lines after start marker, expected at least 3")
+
+	s = add("start\na\nb\nc\nd\nend")
+	assert.EqualError(s[0].Err, "5 lines after start marker, expected at most 4")
+
+	s = add("start\na\nstart\na\nb\nend")
+	assert.Len(s, 2)
+	assert.EqualError(s[0].Err, "no end marker before next start marker")
+	assert.Nil(s[1].Err)
+
+	// Without an end marker stanzas end at the next start marker, and
+	// trailing blank lines aren't counted
+	config.End = nil
+	a = NewStanzaAssembler(config, clock)
+	s = add("start\na\nb\nc\n\n\nstart\na")
+	assert.Len(s, 1)
+	assert.Nil(s[0].Err)
+	assert.Equal([]string{"start", "a", "b", "c"}, s[0].Lines)
+
+	// Timeouts
+	a.SetTimeout(2 * time.Second)
+	now = now.Add(time.Second)
+	s = add("b")
+	assert.Len(s, 0)
+	now = now.Add(2 * time.Second)
+	s = add("c")
+	assert.Len(s, 1)
+	assert.EqualError(s[0].Err, "timed out after 2s")
+	assert.Equal([]string{"start", "a", "b"}, s[0].Lines)
+	// The line after a timeout is outside a stanza
+	s = add("start\na\nb\nc")
+	assert.Len(s, 0)
+	// Timeouts are measured to the last line of a stanza, not the next start
+	// marker
+	now = now.Add(3 * time.Second)
+	s = add("start\na\nb\nc")
+	assert.Len(s, 1)
+	assert.Nil(s[0].Err)
+	start := now
+	now = now.Add(3 * time.Second)
+	s = add("\nstart")
+	assert.Len(s, 1)
+	assert.Nil(s[0].Err)
+	assert.Equal([]string{"start", "a", "b", "c"}, s[0].Lines)
+	assert.Equal(start, s[0].Start)
+}
@@ -7,14 +7,53 @@ import (
 	"time"
 
 	"github.com/ctberthiaume/cruisemic/geo"
+	"github.com/ctberthiaume/cruisemic/nmea"
 	"github.com/ctberthiaume/tsdata"
 )
 
-// TARAParser is a parser for only NMEA GPGGA lines.
+// TARAParser is a parser for NMEA GPRMC lines with optional TSG and PAR
+// sentences. TSG and PAR values received since the last GPRMC line are merged
+// into the record for the next GPRMC time.
 type TARAParser struct {
 	DataManager
+	sentences []taraSentence
+	last      time.Time // time of the latest GPRMC line
 }
 
+// taraSentence describes a comma separated TSG or PAR sentence in the TARA
+// feed. Field positions count from the first field after the sentence prefix,
+// starting at 1.
+type taraSentence struct {
+	name      string   // option key prefix, e.g. tsg
+	prefix    string   // first field of the sentence, e.g. $PPAR
+	columns   []string // column names
+	keys      []string // option keys for column positions
+	positions []int    // field positions for columns
+}
+
+func init() {
+	Register(Registration{
+		Name:        "TARA",
+		Description: "TARA NMEA GPRMC feed with optional TSG and PAR sentences",
+		Cruises:     "TARA 2026",
+		Example:     taraExample,
+		New:         NewTARAParser,
+	})
+}
+
+// taraExample is example TARA feed input.
+const taraExample = `$GPRMC,160331,A,4743.7690,N,00322.4408,W,0.0,182.6,071225,0.2,W,D*13
+$GPZDA,160331,07,12,2025,00,00*4F
+$GPDTM,W84,,00.0000,N,00.0000,E,,W84*41
+$GPGGA,160332,4743.7694,N,00322.4405,W,2,09,1.6,-10.2,M,,M,,*56
+$GPGLL,4743.7694,N,00322.4405,W,160332,
+A,D*5B
+$GPRMC,160332,A,4743.7694,N,00322.4405,W,0.0,182.6,071225,0.2,W,D*19
+$GPVTG,182.6,T,182.8,M,0.0
+,N,0.0,K,D*28
+$GPZDA,160332,07,12,2025,00,00*4C
+`
+
 // NewTARAParser returns a pointer to a TARAParser struct. project is
 // the project or cruise name. interval is the per-feed rate limiting interval
 // in seconds.
@@ -38,9 +77,85 @@ func NewTARAParser(project string, interval time.Duration, now func() time.Time)
 		Units:   []string{"NA", "deg", "deg", "C", "S/m", "PSU", "µE/m^2/s"},
 		Headers: []string{"time", "lat", "lon", "temp", "conductivity", "salinity", "par"},
 	}
+	// We don't have a final example of the TARA TSG and PAR sentences yet, so
+	// these defaults can be changed with Configure.
 	return &TARAParser{
 		DataManager: *NewDataManager(metadata, interval),
+		sentences: []taraSentence{
+			{
+				name:      "tsg",
+				prefix:    "$TSG",
+				columns:   []string{"temp", "conductivity", "salinity"},
+				keys:      []string{"tsg.temp", "tsg.conductivity", "tsg.salinity"},
+				positions: []int{1, 2, 3},
+			},
+			{
+				name:      "par",
+				prefix:    "$PPAR",
+				columns:   []string{"par"},
+				keys:      []string{"par.field"},
+				positions: []int{1},
+			},
+		},
+	}
+}
+
+// Configure sets TSG and PAR sentence prefixes and field positions, and the
+// maximum age of merged TSG and PAR values. Valid options are tsg.prefix,
+// tsg.temp, tsg.conductivity, tsg.salinity, par.prefix, par.field, and
+// max_age. TSG and PAR sentences have no time, so they are timed by the GPRMC
+// line before them. With max_age, values too old for a record are written as
+// NA, and missing values are taken from earlier records if within max_age.
+func (p *TARAParser) Configure(opts Options) (err error) {
+	known := []string{"max_age"}
+	for _, s := range p.sentences {
+		known = append(known, s.name+".prefix")
+		known = append(known, s.keys...)
+	}
+	if err = opts.Check(known...); err != nil {
+		return fmt.Errorf("TARAParser: %w", err)
+	}
+	maxAge, err := opts.Duration("max_age", p.MaxAge())
+	if err != nil {
+		return fmt.Errorf("TARAParser: %w", err)
 	}
+	p.SetMaxAge(maxAge)
+	for i := range p.sentences {
+		s := &p.sentences[i]
+		s.prefix = strings.TrimSpace(opts.String(s.name+".prefix", s.prefix))
+		if s.prefix == "" {
+			return fmt.Errorf("TARAParser: empty %s.prefix", s.name)
+		}
+		for j, key := range s.keys {
+			if s.positions[j], err = opts.Int(key, s.positions[j]); err != nil {
+				return fmt.Errorf("TARAParser: %w", err)
+			}
+			if s.positions[j] < 1 {
+				return fmt.Errorf("TARAParser: %s must be >= 1", key)
+			}
+		}
+	}
+	return nil
+}
+
+// SetGPSFix sets GPS fix columns and policy for RMC positions.
+func (p *TARAParser) SetGPSFix(columns bool, policy FixPolicy, maxHDOP float64) {
+	p.setGPSFix("RMC", columns, policy, maxHDOP)
+}
+
+// SetAttitude sets whether attitude columns are written.
+func (p *TARAParser) SetAttitude(on bool) {
+	p.setAttitude(on)
+}
+
+// SetDepth sets whether a depth column is written, and the transducer offset.
+func (p *TARAParser) SetDepth(on bool, offset float64) {
+	p.setDepth(on, offset)
+}
+
+// SetWind sets whether wind columns from MWV sentences are written.
+func (p *TARAParser) SetWind(on bool) {
+	p.setWind(on)
 }
 
 // ParseLine parses a single underway feed line. Only lines ending with \n are
@@ -55,87 +170,89 @@ func (p *TARAParser) ParseLine(line string) (d Data) {
 	line = line[:len(line)-1]
 
 	var thisErr error
-	if strings.HasPrefix(line, "$GPRMC") {
-		fields := strings.Split(line, ",")
-		if thisErr = p.parseGPRMC(fields); thisErr != nil {
-			p.AddError(fmt.Errorf("TARAParser: bad GPRMC: %v: line=%q", thisErr, line))
+	clean := strings.TrimSpace(line)
+	prefix := strings.TrimSpace(strings.SplitN(clean, ",", 2)[0])
+	for _, s := range p.sentences {
+		if prefix == s.prefix {
+			p.parseSentence(s, clean)
+			return
 		}
-		// If there is no TSG data by the time we receive a GPRMC line, set to
-		// NA.
-		if _, ok := p.GetValue("temp"); !ok {
-			p.AddValue("temp", tsdata.NA)
-		}
-		if _, ok := p.GetValue("conductivity"); !ok {
-			p.AddValue("conductivity", tsdata.NA)
-		}
-		if _, ok := p.GetValue("salinity"); !ok {
-			p.AddValue("salinity", tsdata.NA)
-		}
-		if _, ok := p.GetValue("par"); !ok {
-			p.AddValue("par", tsdata.NA)
+	}
+
+	sen, err := nmea.Parse(line)
+	if err != nil {
+		return
+	}
+	if sen.Type != "RMC" {
+		// Attitude, depth, and wind sentences are timed by the GPRMC line
+		// before them, like TSG and PAR sentences.
+		p.trackMotion(sen, p.last)
+		if thisErr = p.addSentence(sen, p.last); thisErr != nil {
+			p.AddError(fmt.Errorf("TARAParser: bad %s%s: %w: line=%q", sen.Talker, sen.Type, thisErr, line))
 		}
+		return
+	}
+	if thisErr = p.parseRMC(sen); thisErr != nil {
+		p.AddError(fmt.Errorf("TARAParser: bad %sRMC: %w: line=%q", sen.Talker, thisErr, line))
 	}
+	p.trackMotion(sen, p.last)
+	// If there is no TSG or PAR data by the time we receive a GPRMC
+	// line, set to NA.
+	p.Merge("lat", "lon")
 
 	return p.GetData()
 }
 
-func (p *TARAParser) parseGPRMC(fields []string) (err error) {
-	if len(fields) < 13 {
-		return fmt.Errorf("bad GPRMC fields")
+func (p *TARAParser) parseRMC(sen nmea.Sentence) (err error) {
+	if err = p.checkSentence(sen); err != nil {
+		return err
 	}
-
-	// Parse lat/lon
-	latdd, latdderr := geo.GGALat2DD(fields[3], fields[4])
-	if latdderr != nil {
-		return latdderr
+	rmc, err := nmea.ParseRMC(sen)
+	if err != nil {
+		return err
 	}
-	londd, londderr := geo.GGALon2DD(fields[5], fields[6])
-	if londderr != nil {
-		return londderr
+	p.AddValueAt("lat", geo.FormatDD(rmc.Lat), rmc.Time)
+	p.AddValueAt("lon", geo.FormatDD(rmc.Lon), rmc.Time)
+	p.last = rmc.Time
+	if err = p.addRMCFix(rmc, rmc.Time); err != nil {
+		return err
 	}
+	p.SetTime(rmc.Time)
+	return
+}
 
-	// Parse date/time
-	if len(fields[9]) != 6 || len(fields[1]) != 6 {
-		return fmt.Errorf("bad GPRMC date/time")
-	}
-	dateFields := []string{
-		"20" + fields[9][4:6], // year
-		fields[9][2:4],        // month
-		fields[9][0:2],        // day
-		fields[1][0:2],        // hour
-		fields[1][2:4],        // minute
-		fields[1][4:6],        // second
-	}
-	dateVals := make([]int, 7)
-	for i, f := range dateFields {
-		dateVals[i], err = strconv.Atoi(f)
-		if err != nil {
-			return fmt.Errorf("bad GPRMC date/time")
+// parseSentence adds values for a TSG or PAR sentence. Columns with missing or
+// bad values are set to NA. A checksum, if present, is checked according to
+// the checksum policy.
+func (p *TARAParser) parseSentence(s taraSentence, line string) {
+	body := line
+	if i := strings.LastIndexByte(line, '*'); i >= 0 && strings.HasPrefix(line, "$") {
+		if sen, err := nmea.Parse(line); err == nil {
+			if err = p.checkSentence(sen); err != nil {
+				p.AddError(fmt.Errorf("TARAParser: bad %s: %w: line=%q", s.prefix, err, line))
+				return
+			}
 		}
+		body = line[:i]
 	}
-	dateVals[6] = 0 // nanoseconds
-	t := time.Date(
-		dateVals[0],
-		time.Month(dateVals[1]),
-		dateVals[2],
-		dateVals[3],
-		dateVals[4],
-		dateVals[5],
-		dateVals[6],
-		time.UTC,
-	)
-	if t.Year() != dateVals[0] || int(t.Month()) != dateVals[1] || t.Day() != dateVals[2] {
-		return fmt.Errorf("bad GPRMC date/time")
-	}
-	if t.Hour() != dateVals[3] || t.Minute() != dateVals[4] || t.Second() != dateVals[5] {
-		return fmt.Errorf("bad GPRMC date/time")
+	fields := strings.Split(body, ",")
+	for i, col := range s.columns {
+		pos := s.positions[i]
+		if pos >= len(fields) {
+			p.AddError(fmt.Errorf("TARAParser: missing %s field %d: line=%q", s.prefix, pos, line))
+			p.AddValue(col, tsdata.NA)
+			continue
+		}
+		val := strings.TrimSpace(fields[pos])
+		if val == "" {
+			p.AddValue(col, tsdata.NA)
+			continue
+		}
+		if _, err := strconv.ParseFloat(val, 64); err != nil {
+			p.AddError(fmt.Errorf("TARAParser: bad %s float: line=%q", s.prefix, line))
+			p.AddInvalid(col, val)
+			continue
+		}
+		p.AddValueAt(col, val, p.last)
 	}
-
-	p.AddValue("lat", latdd)
-	p.AddValue("lon", londd)
-	p.SetTime(t)
-
-	return
 }
-
-// TODO: add TSG parsing when we have an exmaple of the feed
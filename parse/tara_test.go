@@ -178,6 +178,31 @@ $GPRMC,170332,A,4743.7694,N,00322.4405,W,0.0,182.6,071225,0.2,W,D*19
 			"$GPRMC,1603322,A,4743.7694,N,00A22.4405,X,0.0,182.6,071225,0.2,W,D*19\n",
 			map[string][]string{},
 		},
+		{
+			"TSG and PAR merged into next GPRMC",
+			`$TSG, 15.0526,  3.78840,  30.4126, 1501.506
+$PPAR, 157.580, 6.10, 5
+$GPRMC,160332,A,4743.7694,N,00322.4405,W,0.0,182.6,071225,0.2,W,D*19
+$PPAR, 157.581, 6.10, 5
+$GPRMC,160333,A,4743.7696,N,00322.4403,W,0.0,182.6,071225,0.2,W,D*1C
+`,
+			map[string][]string{
+				"geo": {
+					"2025-12-07T16:03:32Z\t47.7295\t-3.3740\t15.0526\t3.78840\t30.4126\t157.580\n",
+					"2025-12-07T16:03:33Z\t47.7295\t-3.3740\tNA\tNA\tNA\t157.581\n",
+				},
+			},
+		},
+		{
+			"bad TSG values",
+			`$TSG, 15.0a526,,  30.4126
+$PPAR
+$GPRMC,160332,A,4743.7694,N,00322.4405,W,0.0,182.6,071225,0.2,W,D*19
+`,
+			map[string][]string{
+				"geo": {"2025-12-07T16:03:32Z\t47.7295\t-3.3740\tNA\tNA\t30.4126\tNA\n"},
+			},
+		},
 	}
 	for _, tt := range testData {
 		t.Run(tt.name, createTARALinesTest(t, tt))
@@ -200,3 +225,34 @@ func createTARALinesTest(t *testing.T, tt testTARALineData) func(*testing.T) {
 		assert.Equal(tt.expected, store.Feeds, tt.name)
 	}
 }
+
+func TestTARAConfigure(t *testing.T) {
+	assert := assert.New(t)
+	p := NewTARAParser("test", 0, time.Now).(*TARAParser)
+	err := p.Configure(Options{
+		"tsg.prefix":       "$SBE45",
+		"tsg.temp":         "2",
+		"tsg.conductivity": "3",
+		"tsg.salinity":     "4",
+		"par.prefix":       "$LICOR",
+		"par.field":        "2",
+	})
+	assert.Nil(err)
+
+	store, _ := storage.NewMemStorage()
+	input := `$SBE45,160332,15.0526,3.78840,30.4126*00
+$LICOR,160332,1201.5
+$TSG, 15.0526,  3.78840,  30.4126, 1501.506
+$GPRMC,160332,A,4743.7694,N,00322.4405,W,0.0,182.6,071225,0.2,W,D*19
+`
+	err = ParseLines(p, strings.NewReader(input), store, true, false)
+	assert.Nil(err)
+	assert.Equal(map[string][]string{
+		"geo": {"2025-12-07T16:03:32Z\t47.7295\t-3.3740\t15.0526\t3.78840\t30.4126\t1201.5\n"},
+	}, store.Feeds)
+
+	assert.NotNil(p.Configure(Options{"tsg.depth": "1"}), "unknown option")
+	assert.NotNil(p.Configure(Options{"par.field": "0"}), "bad position")
+	assert.NotNil(p.Configure(Options{"par.field": "a"}), "bad integer")
+	assert.NotNil(p.Configure(Options{"par.prefix": " "}), "empty prefix")
+}
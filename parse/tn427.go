@@ -1,167 +1,42 @@
 package parse
 
-import (
-	"fmt"
-	"strconv"
-	"strings"
-	"time"
-
-	"github.com/ctberthiaume/cruisemic/geo"
-	"github.com/ctberthiaume/tsdata"
-)
+import "time"
 
 // TN427Parser is a parser for TN427 (and possibly TN428 ...) Thompson underway feed lines.
 type TN427Parser struct {
-	DataManager
+	SeaflowParser
+}
+
+func init() {
+	Register(Registration{
+		Name:        "TN427",
+		Description: "Thompson $SEAFLOW line feed with bare PAR value",
+		Cruises:     "Thompson TN427-TN447",
+		Example:     tn427Example,
+		New:         NewTN427Parser,
+	})
 }
 
+// tn427Example is example TN427 feed input.
+const tn427Example = `$SEAFLOW::$GPZDA,201337.00,19,12,2023,00,00*6A::$GPGGA,201337.00,1701.436745,S,17056.502003,W,2,17,0.7,14.756,M,28.4,M,7.0,0643*73:: 28.2858,  5.73202,  35.5513, 1542.537::2606.001
+$SEAFLOW::$GPZDA,201338.00,19,12,2023,00,00*65::$GPGGA,201338.00,1701.436894,S,17056.501840,W,2,17,0.7,14.542,M,28.4,M,4.0,0643*77:: 28.2858,  5.73202,  35.5513, 1542.537::2606.276
+$SEAFLOW::$GPZDA,201339.00,19,12,2023,00,00*64::$GPGGA,201339.00,1701.437095,S,17056.501722,W,2,17,0.7,14.391,M,28.4,M,4.0,0643*7D:: 28.2858,  5.73202,  35.5513, 1542.537::2605.584
+`
+
 // NewTN427Parser returns a pointer to a TN427Parser struct. project is
 // the project or cruise name. interval is the per-feed rate limiting interval
 // in seconds.
 func NewTN427Parser(project string, interval time.Duration, now func() time.Time) Parser {
-	metadata := tsdata.Tsdata{
-		Project:         project,
-		FileType:        "geo",
+	config := SeaflowConfig{
+		Name:            "TN427Parser",
+		Talker:          "GP",
 		FileDescription: "TN427+ Thompson underway feed",
-		Comments:        []string{"RFC3339", "Latitude Decimal format", "Longitude Decimal format", "TSG temperature", "TSG conductivity", "TSG salinity", "PAR"},
-		Types:           []string{"time", "float", "float", "float", "float", "float", "float"},
-		Units:           []string{"NA", "deg", "deg", "C", "S/m", "PSU", "µE/m^2/s"},
-		Headers:         []string{"time", "lat", "lon", "temp", "conductivity", "salinity", "par"},
+		RequireNewline:  true,
+		TSGFields:       []int{3, 4},
+		PARFormat:       SeaflowPARBare,
+		PARDecimals:     3,
 	}
 	return &TN427Parser{
-		DataManager: *NewDataManager(metadata, interval),
-	}
-}
-
-// ParseLine parses a single underway feed line. Only lines ending with \n are
-// examined.
-func (p *TN427Parser) ParseLine(line string) (d Data) {
-	if len(line) == 0 || line[len(line)-1] != '\n' {
-		return
-	}
-
-	// Remove trailing \n for parsing
-	line = line[:len(line)-1]
-
-	// Trim leading and trailing whitespace
-	clean := strings.TrimSpace(line)
-
-	if !strings.HasPrefix(clean, "$SEAFLOW") {
-		return
-	}
-
-	fields := strings.Split(clean, "::")
-
-	if len(fields) != 5 {
-		return
+		SeaflowParser: *NewSeaflowParser(config, project, interval),
 	}
-
-	// Parse time
-	timeFields := strings.Split(fields[1], ",")
-	if len(timeFields) != 7 {
-		p.AddError(fmt.Errorf("TN427Parser: bad GPZDA: line=%q", clean))
-		return
-	}
-	if len(timeFields[1]) != 9 {
-		p.AddError(fmt.Errorf("TN427Parser: bad GPZDA: line=%q", clean))
-		return
-	}
-	timestr := timeFields[1][:2] + ":" + timeFields[1][2:4] + ":" + timeFields[1][4:6]
-	datestr := timeFields[4] + "-" + timeFields[3] + "-" + timeFields[2]
-	t, err := time.Parse(time.RFC3339, datestr+"T"+timestr+"Z")
-	if err != nil {
-		p.AddError(fmt.Errorf("TN427Parser: bad GPZDA: %v: line=%q", err, clean))
-		return
-	}
-
-	// Latitude and Longitude
-	latLonFields := strings.Split(fields[2], ",")
-	if len(latLonFields) != 15 {
-		p.AddError(fmt.Errorf("TN427Parser: bad GPGGA: line=%q", clean))
-		return
-	} else {
-		latdd, latddErr := geo.GGALat2DD(latLonFields[2], latLonFields[3])
-		if latddErr != nil {
-			p.AddError(fmt.Errorf("TN427Parser: bad GPGGA lat: %v: line=%q", latddErr, line))
-			return
-		}
-		p.AddValue("lat", latdd)
-
-		// Longitude
-		londd, londdErr := geo.GGALon2DD(latLonFields[4], latLonFields[5])
-		if londdErr != nil {
-			p.AddError(fmt.Errorf("TN427Parser: bad GPGGA lon: %v: line=%q", londdErr, line))
-			return
-		}
-		p.AddValue("lon", londd)
-	}
-
-	// // Temperature
-	tsgFields := strings.Split(fields[3], ",")
-	if len(tsgFields) != 3 && len(tsgFields) != 4 {
-		p.AddError(fmt.Errorf("TN427Parser: bad TSG: line=%q", clean))
-		p.AddValue("temp", tsdata.NA)
-		p.AddValue("conductivity", tsdata.NA)
-		p.AddValue("salinity", tsdata.NA)
-	} else {
-		tempStr := strings.TrimSpace(tsgFields[0])
-		_, floatErr := strconv.ParseFloat(strings.TrimSpace(tsgFields[0]), 64)
-		if floatErr != nil {
-			p.AddError(fmt.Errorf("TN427Parser: bad float: line=%q", line))
-			p.AddValue("temp", tsdata.NA)
-		} else {
-			p.AddValue("temp", tempStr)
-		}
-
-		// Conductivity
-		condStr := strings.TrimSpace(tsgFields[1])
-		_, floatErr = strconv.ParseFloat(condStr, 64)
-		if floatErr != nil {
-			p.AddError(fmt.Errorf("TN427Parser: bad float: line=%q", line))
-			p.AddValue("conductivity", tsdata.NA)
-		} else {
-			p.AddValue("conductivity", condStr)
-		}
-
-		// Salinity
-		salStr := strings.TrimSpace(tsgFields[2])
-		_, floatErr = strconv.ParseFloat(salStr, 64)
-		if floatErr != nil {
-			p.AddError(fmt.Errorf("TN427Parser: bad float: line=%q", line))
-			p.AddValue("salinity", tsdata.NA)
-		} else {
-			p.AddValue("salinity", salStr)
-		}
-	}
-
-	// PAR
-	// Keep the line if PAR is simply not present, PAR feed may have stopped
-	// entirely and we should keep all other values if possible. As opposed to
-	// PAR being present but with possibly truncated numbers, in which case we
-	// want to reject the entire line in the hopes that a valid PAR value shows
-	// up within the throttled time interval. A full PAR number has 3 decimal
-	// places.
-	parField := fields[4]
-	if parField == "" {
-		p.AddError(fmt.Errorf("TN427Parser: bad PPAR: line=%q", clean))
-		p.AddValue("par", tsdata.NA)
-	} else {
-		parStr := strings.TrimSpace(parField)
-		parNumberFields := strings.Split(parField, ".")
-		_, floatErr := strconv.ParseFloat(parStr, 64)
-		if floatErr != nil || len(parNumberFields) != 2 || len(parNumberFields[1]) != 3 {
-			p.AddError(fmt.Errorf("TN427Parser: bad PAR float: line=%q", line))
-			p.AddValue("par", tsdata.NA)
-			// PAR may be unreliable on TN427+. We'll be reading every second, so just completely
-			// reject the entire line if bad PAR. On G5 about 1 in 4 PAR was good, so if
-			// we encounter the same issue on TN427+ we'll be ready.
-			return
-		} else {
-			p.AddValue("par", parStr)
-		}
-	}
-
-	p.SetTime(t)
-	d = p.GetData()
-	return
 }
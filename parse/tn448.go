@@ -1,164 +1,43 @@
 package parse
 
-import (
-	"fmt"
-	"strconv"
-	"strings"
-	"time"
-
-	"github.com/ctberthiaume/cruisemic/geo"
-	"github.com/ctberthiaume/tsdata"
-)
+import "time"
 
 // TN448Parser is a parser for TN448 (and possibly TN449 ...) Thompson underway feed lines.
 type TN448Parser struct {
-	DataManager
+	SeaflowParser
+}
+
+func init() {
+	Register(Registration{
+		Name:        "TN448",
+		Description: "Thompson $SEAFLOW line feed, lines may lack a trailing newline",
+		Cruises:     "Thompson TN448+",
+		Aliases:     []string{"TN450"},
+		Example:     tn448Example,
+		New:         NewTN448Parser,
+	})
 }
 
+// tn448Example is example TN448 feed input.
+const tn448Example = `$SEAFLOW::$GNZDA,192824.00,08,01,2026,00,00*73::$GNGGA,192824.00,0959.090566,N,13112.849121,E,5,18,0.62,72.764,M,0.000,M,75,0000*43:: 29.6849,  5.64749,  33.9515, 1543.859::-0.005
+$SEAFLOW::$GNZDA,192844.00,08,01,2026,00,00*75::$GNGGA,192844.00,0959.129627,N,13112.900471,E,5,18,0.62,71.503,M,0.000,M,35,0000*48:: 29.6866,  5.64754,  33.9507, 1543.861::-0.002
+$SEAFLOW::$GNZDA,192845.00,08,01,2026,00,00*74::$GNGGA,192845.00,0959.131607,N,13112.903197,E,5,18,0.62,72.097,M,0.000,M,36,0000*44:: 29.6866,  5.64757,  33.9509, 1543.862::-0.001
+`
+
 // NewTN448Parser returns a pointer to a TN448Parser struct. project is
 // the project or cruise name. interval is the per-feed rate limiting interval
 // in seconds.
 func NewTN448Parser(project string, interval time.Duration, now func() time.Time) Parser {
-	metadata := tsdata.Tsdata{
-		Project:         project,
-		FileType:        "geo",
+	config := SeaflowConfig{
+		Name:            "TN448Parser",
+		Talker:          "GN",
 		FileDescription: "TN448+ Thompson underway feed",
-		Comments:        []string{"RFC3339", "Latitude Decimal format", "Longitude Decimal format", "TSG temperature", "TSG conductivity", "TSG salinity", "PAR"},
-		Types:           []string{"time", "float", "float", "float", "float", "float", "float"},
-		Units:           []string{"NA", "deg", "deg", "C", "S/m", "PSU", "µE/m^2/s"},
-		Headers:         []string{"time", "lat", "lon", "temp", "conductivity", "salinity", "par"},
+		RequireNewline:  false,
+		TSGFields:       []int{3, 4},
+		PARFormat:       SeaflowPARBare,
+		PARDecimals:     3,
 	}
 	return &TN448Parser{
-		DataManager: *NewDataManager(metadata, interval),
-	}
-}
-
-// ParseLine parses a single underway feed line. Only lines ending with \n are
-// examined.
-func (p *TN448Parser) ParseLine(line string) (d Data) {
-	if len(line) == 0 {
-		return
+		SeaflowParser: *NewSeaflowParser(config, project, interval),
 	}
-
-	// Trim leading and trailing whitespace
-	clean := strings.TrimSpace(line)
-
-	if !strings.HasPrefix(clean, "$SEAFLOW") {
-		return
-	}
-
-	fields := strings.Split(clean, "::")
-
-	if len(fields) != 5 {
-		return
-	}
-
-	// Parse time
-	timeFields := strings.Split(fields[1], ",")
-	if len(timeFields) != 7 {
-		p.AddError(fmt.Errorf("TN448Parser: bad GNZDA: line=%q", clean))
-		return
-	}
-	if len(timeFields[1]) != 9 {
-		p.AddError(fmt.Errorf("TN448Parser: bad GNZDA: line=%q", clean))
-		return
-	}
-	timestr := timeFields[1][:2] + ":" + timeFields[1][2:4] + ":" + timeFields[1][4:6]
-	datestr := timeFields[4] + "-" + timeFields[3] + "-" + timeFields[2]
-	t, err := time.Parse(time.RFC3339, datestr+"T"+timestr+"Z")
-	if err != nil {
-		p.AddError(fmt.Errorf("TN448Parser: bad GNZDA: %v: line=%q", err, clean))
-		return
-	}
-
-	// Latitude and Longitude
-	latLonFields := strings.Split(fields[2], ",")
-	if len(latLonFields) != 15 {
-		p.AddError(fmt.Errorf("TN448Parser: bad GNGGA: line=%q", clean))
-		return
-	} else {
-		latdd, latddErr := geo.GGALat2DD(latLonFields[2], latLonFields[3])
-		if latddErr != nil {
-			p.AddError(fmt.Errorf("TN448Parser: bad GNGGA lat: %v: line=%q", latddErr, line))
-			return
-		}
-		p.AddValue("lat", latdd)
-
-		// Longitude
-		londd, londdErr := geo.GGALon2DD(latLonFields[4], latLonFields[5])
-		if londdErr != nil {
-			p.AddError(fmt.Errorf("TN448Parser: bad GNGGA lon: %v: line=%q", londdErr, line))
-			return
-		}
-		p.AddValue("lon", londd)
-	}
-
-	// // Temperature
-	tsgFields := strings.Split(fields[3], ",")
-	if len(tsgFields) != 3 && len(tsgFields) != 4 {
-		p.AddError(fmt.Errorf("TN448Parser: bad TSG: line=%q", clean))
-		p.AddValue("temp", tsdata.NA)
-		p.AddValue("conductivity", tsdata.NA)
-		p.AddValue("salinity", tsdata.NA)
-	} else {
-		tempStr := strings.TrimSpace(tsgFields[0])
-		_, floatErr := strconv.ParseFloat(strings.TrimSpace(tsgFields[0]), 64)
-		if floatErr != nil {
-			p.AddError(fmt.Errorf("TN448Parser: bad float: line=%q", line))
-			p.AddValue("temp", tsdata.NA)
-		} else {
-			p.AddValue("temp", tempStr)
-		}
-
-		// Conductivity
-		condStr := strings.TrimSpace(tsgFields[1])
-		_, floatErr = strconv.ParseFloat(condStr, 64)
-		if floatErr != nil {
-			p.AddError(fmt.Errorf("TN448Parser: bad float: line=%q", line))
-			p.AddValue("conductivity", tsdata.NA)
-		} else {
-			p.AddValue("conductivity", condStr)
-		}
-
-		// Salinity
-		salStr := strings.TrimSpace(tsgFields[2])
-		_, floatErr = strconv.ParseFloat(salStr, 64)
-		if floatErr != nil {
-			p.AddError(fmt.Errorf("TN448Parser: bad float: line=%q", line))
-			p.AddValue("salinity", tsdata.NA)
-		} else {
-			p.AddValue("salinity", salStr)
-		}
-	}
-
-	// PAR
-	// Keep the line if PAR is simply not present, PAR feed may have stopped
-	// entirely and we should keep all other values if possible. As opposed to
-	// PAR being present but with possibly truncated numbers, in which case we
-	// want to reject the entire line in the hopes that a valid PAR value shows
-	// up within the throttled time interval. A full PAR number has 3 decimal
-	// places.
-	parField := fields[4]
-	if parField == "" {
-		p.AddError(fmt.Errorf("TN448Parser: bad PPAR: line=%q", clean))
-		p.AddValue("par", tsdata.NA)
-	} else {
-		parStr := strings.TrimSpace(parField)
-		parNumberFields := strings.Split(parField, ".")
-		_, floatErr := strconv.ParseFloat(parStr, 64)
-		if floatErr != nil || len(parNumberFields) != 2 || len(parNumberFields[1]) != 3 {
-			p.AddError(fmt.Errorf("TN448Parser: bad PAR float: line=%q", line))
-			p.AddValue("par", tsdata.NA)
-			// PAR may be unreliable on TN448+. We'll be reading every second, so just completely
-			// reject the entire line if bad PAR. On G5 about 1 in 4 PAR was good, so if
-			// we encounter the same issue on TN448+ we'll be ready.
-			return
-		} else {
-			p.AddValue("par", parStr)
-		}
-	}
-
-	p.SetTime(t)
-	d = p.GetData()
-	return
 }
@@ -159,13 +159,17 @@ $SEAFLOW::$GNZDA,213310.00,12,01,2023,00,00*6D::$GNGGA,213310.00,4738.983141,N,1
 			"bad PAR, truncated",
 			`$SEAFLOW::$GNZDA,213309.00,12,01,2023,00,00*6D::$GNGGA,213309.00,4738.983141,N,12218.805824,W,2,17,0.7,15.773,M,-22.2,M,7.0,0402*44::::157.58
 `,
-			map[string][]string{},
+			map[string][]string{
+				"geo": {"2023-01-12T21:33:09Z\t47.6497\t-122.3134\tNA\tNA\tNA\tNA\n"},
+			},
 		},
 		{
 			"bad PAR, not a number",
 			`$SEAFLOW::$GNZDA,213309.00,12,01,2023,00,00*6D::$GNGGA,213309.00,4738.983141,N,12218.805824,W,2,17,0.7,15.773,M,-22.2,M,7.0,0402*44::::1a7.580
 `,
-			map[string][]string{},
+			map[string][]string{
+				"geo": {"2023-01-12T21:33:09Z\t47.6497\t-122.3134\tNA\tNA\tNA\tNA\n"},
+			},
 		},
 		{
 			"missing PAR text entirely",
@@ -0,0 +1,138 @@
+package parse
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/ctberthiaume/tsdata"
+)
+
+// State distinguishes real values from missing or unparseable ones.
+type State int
+
+const (
+	// Present is a real value
+	Present State = iota
+	// Missing is a value that was NA or not present in the feed
+	Missing
+	// Invalid is a value that was present in the feed but could not be parsed
+	Invalid
+)
+
+func (s State) String() string {
+	switch s {
+	case Present:
+		return "present"
+	case Missing:
+		return "missing"
+	case Invalid:
+		return "invalid"
+	}
+	return "unknown"
+}
+
+// Flag is a per-value quality control flag using IOOS QARTOD flag values.
+type Flag int
+
+const (
+	// FlagGood is a value that passed all checks
+	FlagGood Flag = 1
+	// FlagSuspect is a value that was kept but may be wrong, e.g. truncated
+	FlagSuspect Flag = 3
+	// FlagBad is a value that failed checks, e.g. could not be parsed
+	FlagBad Flag = 4
+	// FlagMissing is a value that was NA or not present in the feed
+	FlagMissing Flag = 9
+)
+
+// String returns the QARTOD flag number written to flag columns.
+func (f Flag) String() string {
+	return strconv.Itoa(int(f))
+}
+
+// Value is a single typed column value. Type is a TSDATA column type, e.g.
+// float or integer. Only the field for Type is set, and only if State is
+// Present. Text is the original text of the value, which is written to output
+// files for Present values to keep formatting, e.g. trailing zeros, intact.
+// Flag is the quality control flag for the value.
+type Value struct {
+	Type  string
+	State State
+	Flag  Flag
+	Float float64
+	Int   int64
+	Bool  bool
+	Time  time.Time
+	Text  string
+}
+
+// NewValue parses text as a value of TSDATA column type typ. tsdata.NA
+// creates a Missing value. Text that can't be parsed as typ creates an Invalid
+// value. Unknown types are treated as text.
+func NewValue(typ, text string) (v Value) {
+	v.Type = typ
+	v.Text = text
+	v.Flag = FlagGood
+	if text == tsdata.NA {
+		v.State = Missing
+		v.Flag = FlagMissing
+		return
+	}
+	var err error
+	switch typ {
+	case "float":
+		v.Float, err = strconv.ParseFloat(text, 64)
+	case "integer":
+		v.Int, err = strconv.ParseInt(text, 10, 64)
+	case "boolean":
+		v.Bool, err = text == "TRUE", nil
+		if text != "TRUE" && text != "FALSE" {
+			err = strconv.ErrSyntax
+		}
+	case "time":
+		v.Time, err = time.Parse(time.RFC3339Nano, text)
+	case "category":
+		if text == "" {
+			err = strconv.ErrSyntax
+		}
+	}
+	if err != nil {
+		v = InvalidValue(typ, text)
+	}
+	return
+}
+
+// MissingValue returns a Missing value of TSDATA column type typ.
+func MissingValue(typ string) Value {
+	return Value{Type: typ, State: Missing, Flag: FlagMissing, Text: tsdata.NA}
+}
+
+// InvalidValue returns an Invalid value of TSDATA column type typ. text is the
+// unparseable text from the feed.
+func InvalidValue(typ, text string) Value {
+	return Value{Type: typ, State: Invalid, Flag: FlagBad, Text: text}
+}
+
+// SuspectValue parses text as a value of TSDATA column type typ, flagged as
+// suspect if it parses.
+func SuspectValue(typ, text string) Value {
+	v := NewValue(typ, text)
+	if v.Flag == FlagGood {
+		v.Flag = FlagSuspect
+	}
+	return v
+}
+
+// OK returns true if v is Present.
+func (v Value) OK() bool {
+	return v.State == Present
+}
+
+// String returns the text for v as written to output files, tsdata.NA if v
+// is not Present.
+func (v Value) String() string {
+	if v.State != Present {
+		return tsdata.NA
+	}
+	return v.Text
+}
@@ -0,0 +1,112 @@
+package parse
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ctberthiaume/cruisemic/storage"
+	"github.com/ctberthiaume/tsdata"
+	"github.com/stretchr/testify/assert"
+)
+
+type testValueData struct {
+	name     string
+	typ      string
+	text     string
+	expected Value
+}
+
+func TestNewValue(t *testing.T) {
+	t0 := time.Date(2023, 1, 12, 21, 33, 9, 500000000, time.UTC)
+	testData := []testValueData{
+		{"float", "float", "15.0500", Value{Type: "float", Float: 15.05, Flag: FlagGood, Text: "15.0500"}},
+		{"bad float", "float", "15.0a", Value{Type: "float", State: Invalid, Flag: FlagBad, Text: "15.0a"}},
+		{"NA float", "float", "NA", Value{Type: "float", State: Missing, Flag: FlagMissing, Text: "NA"}},
+		{"integer", "integer", "-3", Value{Type: "integer", Int: -3, Flag: FlagGood, Text: "-3"}},
+		{"bad integer", "integer", "3.0", Value{Type: "integer", State: Invalid, Flag: FlagBad, Text: "3.0"}},
+		{"boolean", "boolean", "TRUE", Value{Type: "boolean", Bool: true, Flag: FlagGood, Text: "TRUE"}},
+		{"bad boolean", "boolean", "true", Value{Type: "boolean", State: Invalid, Flag: FlagBad, Text: "true"}},
+		{"time", "time", "2023-01-12T21:33:09.5Z", Value{Type: "time", Time: t0, Flag: FlagGood, Text: "2023-01-12T21:33:09.5Z"}},
+		{"text", "text", "", Value{Type: "text", Flag: FlagGood, Text: ""}},
+		{"empty category", "category", "", Value{Type: "category", State: Invalid, Flag: FlagBad, Text: ""}},
+		{"unknown type", "", "abc", Value{Flag: FlagGood, Text: "abc"}},
+	}
+	for _, tt := range testData {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			v := NewValue(tt.typ, tt.text)
+			assert.Equal(tt.expected, v, tt.name)
+			if v.OK() {
+				assert.Equal(tt.text, v.String(), tt.name)
+			} else {
+				assert.Equal(tsdata.NA, v.String(), tt.name)
+			}
+		})
+	}
+}
+
+func TestValueStates(t *testing.T) {
+	assert := assert.New(t)
+	line := "$SEAFLOW::$GPZDA,213309.00,12,01,2023,00,00*6D::$GPGGA,213309.00,4738.983141,N,12218.805824,W,2,17,0.7,15.773,M,-22.2,M,7.0,0402*44:: 12.371a9,  3.64868,  31.2816::157.580\n"
+	p := NewTN427Parser("test", 0, time.Now)
+	d := p.ParseLine(line)
+	assert.True(d.OK())
+	assert.Equal([]State{Present, Present, Invalid, Present, Present, Present}, states(d.Values))
+	assert.Equal("12.371a9", d.Values[2].Text)
+	assert.InDelta(47.6497, d.Values[0].Float, 1e-9)
+	assert.True(strings.Contains(d.Line("\t"), "\tNA\t3.64868\t"))
+
+	tara := NewTARAParser("test", 0, time.Now)
+	d = tara.ParseLine("$GPRMC,160332,A,4743.7694,N,00322.4405,W,0.0,182.6,071225,0.2,W,D*19\n")
+	assert.Equal([]State{Present, Present, Missing, Missing, Missing, Missing}, states(d.Values))
+}
+
+func states(values []Value) (s []State) {
+	for _, v := range values {
+		s = append(s, v.State)
+	}
+	return s
+}
+
+func TestFlagColumns(t *testing.T) {
+	assert := assert.New(t)
+	input := `$SEAFLOW::$GPZDA,213309.00,12,01,2023,00,00*6D::$GPGGA,213309.00,4738.983141,N,12218.805824,W,2,17,0.7,15.773,M,-22.2,M,7.0,0402*44:: 12.371a9,  3.64868,  31.2816::157.58
+$SEAFLOW::$GPZDA,213310.00,12,01,2023,00,00*6D::$GPGGA,213310.00,4738.983141,N,12218.805824,W,2,17,0.7,15.773,M,-22.2,M,7.0,0402*44:: 12.3719, 3.64868::157.5a0
+$SEAFLOW::$GPZDA,213311.00,12,01,2023,00,00*6D::$GPGGA,213311.00,4738.983141,N,12218.805824,W,2,17,0.7,15.773,M,-22.2,M,7.0,0402*44::::
+`
+	p := NewTN427Parser("test", 0, time.Now)
+	p.(FlagColumnsSetter).SetFlagColumns(true)
+	header := p.Header()
+	assert.Contains(header, "time\tlat\tlat_flag\tlon\tlon_flag\ttemp\ttemp_flag")
+	assert.Contains(header, "QARTOD flag for par")
+
+	store, _ := storage.NewMemStorage()
+	err := ParseLines(p, strings.NewReader(input), store, true, false)
+	assert.Nil(err)
+	assert.Equal(map[string][]string{
+		"geo": {
+			"2023-01-12T21:33:09Z\t47.6497\t1\t-122.3134\t1\tNA\t4\t3.64868\t1\t31.2816\t1\t157.58\t3\n",
+			"2023-01-12T21:33:10Z\t47.6497\t1\t-122.3134\t1\tNA\t4\tNA\t4\tNA\t4\tNA\t4\n",
+			"2023-01-12T21:33:11Z\t47.6497\t1\t-122.3134\t1\tNA\t9\tNA\t9\tNA\t9\tNA\t9\n",
+		},
+	}, store.Feeds)
+
+	// Without flag columns records with bad or truncated PAR are still kept,
+	// with PAR written as NA
+	p = NewTN427Parser("test", 0, time.Now)
+	store, _ = storage.NewMemStorage()
+	err = ParseLines(p, strings.NewReader(input), store, true, false)
+	assert.Nil(err)
+	assert.Equal(3, len(store.Feeds["geo"]))
+	assert.Equal("2023-01-12T21:33:09Z\t47.6497\t-122.3134\tNA\t3.64868\t31.2816\tNA\n", store.Feeds["geo"][0])
+	assert.NotContains(p.Header(), "_flag")
+
+	// unless par.reject is set
+	p = NewTN427Parser("test", 0, time.Now)
+	assert.Nil(p.(Configurable).Configure(Options{"par.reject": "true"}))
+	store, _ = storage.NewMemStorage()
+	err = ParseLines(p, strings.NewReader(input), store, true, false)
+	assert.Nil(err)
+	assert.Equal(1, len(store.Feeds["geo"]))
+}
@@ -0,0 +1,186 @@
+package parse
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ctberthiaume/cruisemic/geo"
+	"github.com/ctberthiaume/cruisemic/nmea"
+	"github.com/ctberthiaume/tsdata"
+)
+
+// WindSetter is implemented by parsers that can write relative and true wind
+// columns from MWV sentences in their feed. True wind is computed from
+// relative wind and the latest ship heading from HDT, HDG, or PASHR sentences
+// and speed and course over ground from VTG or RMC sentences. Wind columns are
+// added after all other columns.
+type WindSetter interface {
+	SetWind(on bool)
+}
+
+// windColumns are the wind column names.
+var windColumns = []string{"relative_wind_speed", "relative_wind_direction", "true_wind_speed", "true_wind_direction"}
+
+// motionMaxAge is the maximum age of heading and velocity used for true wind
+// when the parser has no max age.
+const motionMaxAge = 10 * time.Second
+
+// motion is the latest ship heading and velocity for true wind.
+type motion struct {
+	heading     float64   // degrees true
+	sog         float64   // knots
+	cog         float64   // degrees true
+	headingAt   time.Time // measurement time of heading
+	velocityAt  time.Time // measurement time of sog and cog
+	hasHeading  bool
+	hasVelocity bool
+}
+
+// fresh returns true if a heading or velocity measured at at can be used
+// for wind measured at t. Values more than the parser's max age, or
+// motionMaxAge without one, before or after t are too old.
+func (dm *DataManager) fresh(at, t time.Time) bool {
+	limit := dm.maxAge
+	if limit <= 0 {
+		limit = motionMaxAge
+	}
+	age := t.Sub(at)
+	return age <= limit && age >= -limit
+}
+
+// setWind sets whether wind columns are written.
+func (dm *DataManager) setWind(on bool) {
+	dm.wind = on
+	dm.SetMetadata(dm.base)
+}
+
+// windMetadata returns a copy of metadata with wind columns appended.
+func windMetadata(metadata tsdata.Tsdata) tsdata.Tsdata {
+	return appendColumns(metadata, windColumns,
+		[]string{"float", "float", "float", "float"},
+		[]string{"kn", "deg", "kn", "deg"},
+		[]string{"Relative wind speed from MWV", "Relative wind direction from MWV, clockwise from bow", "True wind speed", "True wind direction, from"})
+}
+
+// trackMotion records ship heading from HDT, HDG, and PASHR sentences and
+// speed and course over ground from VTG and RMC sentences for true wind, if
+// wind columns are on. t is the measurement time, as for addWind. Sentences
+// that fail checksum verification with ChecksumReject, or can't be decoded,
+// are ignored. Errors for these sentences
+// are reported by the code that adds their values.
+func (dm *DataManager) trackMotion(sen nmea.Sentence, t time.Time) {
+	if !dm.wind {
+		return
+	}
+	if dm.checksum == ChecksumReject && sen.VerifyChecksum() != nil {
+		return
+	}
+	setHeading := func(f nmea.Float) {
+		if f.Valid {
+			dm.motion.heading, dm.motion.hasHeading = f.Value, true
+			dm.motion.headingAt = t
+		}
+	}
+	setVelocity := func(sog, cog nmea.Float) {
+		if sog.Valid && cog.Valid {
+			dm.motion.sog, dm.motion.cog, dm.motion.hasVelocity = sog.Value, cog.Value, true
+			dm.motion.velocityAt = t
+		}
+	}
+	switch sen.Type {
+	case "HDT":
+		if hdt, err := nmea.ParseHDT(sen); err == nil {
+			setHeading(hdt.Heading)
+		}
+	case "HDG":
+		if hdg, err := nmea.ParseHDG(sen); err == nil {
+			if heading, ok := hdg.TrueHeading(); ok {
+				setHeading(nmea.Float{Value: heading, Valid: true})
+			}
+		}
+	case "ASHR":
+		if ashr, err := nmea.ParsePASHR(sen); err == nil && sen.Talker == "P" {
+			setHeading(ashr.Heading)
+		}
+	case "VTG":
+		if vtg, err := nmea.ParseVTG(sen); err == nil {
+			setVelocity(vtg.SpeedKnots, vtg.CourseTrue)
+		}
+	case "RMC":
+		if rmc, err := nmea.ParseRMC(sen); err == nil && rmc.Status != "V" {
+			setVelocity(rmc.SpeedKnots, rmc.Course)
+		}
+	}
+}
+
+// addWind adds wind values from sen if it's an MWV sentence and wind columns
+// are on. t is the measurement time for Merge. It returns false if sen was
+// not used. The checksum is checked according to the checksum policy. True
+// wind is NA until heading and velocity have been seen, and when they're too
+// old for t, see fresh.
+func (dm *DataManager) addWind(sen nmea.Sentence, t time.Time) (bool, error) {
+	if !dm.wind || sen.Type != "MWV" {
+		return false, nil
+	}
+	if err := dm.checkSentence(sen); err != nil {
+		return true, err
+	}
+	mwv, err := nmea.ParseMWV(sen)
+	if err != nil {
+		return true, err
+	}
+	if strings.TrimSpace(mwv.Status) == "V" {
+		return true, fmt.Errorf("wind status V")
+	}
+	speed, ok := mwv.SpeedKnots()
+	if !ok || !mwv.Angle.Valid {
+		return true, fmt.Errorf("missing wind speed or angle")
+	}
+	m := dm.motion
+	hasHeading := m.hasHeading && dm.fresh(m.headingAt, t)
+	hasVelocity := m.hasVelocity && dm.fresh(m.velocityAt, t)
+	switch mwv.Reference {
+	case "R":
+		dm.AddValueAt("relative_wind_speed", formatWind(speed), t)
+		dm.AddValueAt("relative_wind_direction", formatWind(mwv.Angle.Value), t)
+		if hasHeading && hasVelocity {
+			trueSpeed, trueDir := geo.TrueWind(speed, mwv.Angle.Value, m.heading, m.sog, m.cog)
+			dm.AddValueAt("true_wind_speed", formatWind(trueSpeed), t)
+			dm.AddValueAt("true_wind_direction", formatWind(trueDir), t)
+		} else {
+			dm.addWindNA(t, "true_wind_speed", "true_wind_direction")
+		}
+	case "T":
+		// Theoretical wind is relative to the bow but corrected for ship
+		// velocity, so only heading is needed.
+		if hasHeading {
+			trueSpeed, trueDir := geo.TrueWind(speed, mwv.Angle.Value, m.heading, 0, 0)
+			dm.AddValueAt("true_wind_speed", formatWind(trueSpeed), t)
+			dm.AddValueAt("true_wind_direction", formatWind(trueDir), t)
+		} else {
+			dm.addWindNA(t, "true_wind_speed", "true_wind_direction")
+		}
+		dm.addWindNA(t, "relative_wind_speed", "relative_wind_direction")
+	default:
+		return true, fmt.Errorf("bad wind reference %q", mwv.Reference)
+	}
+	return true, nil
+}
+
+// addWindNA sets columns keys to NA if they don't already have a value, so
+// that relative and theoretical MWV sentences from the same anemometer don't
+// erase each other's values.
+func (dm *DataManager) addWindNA(t time.Time, keys ...string) {
+	for _, k := range keys {
+		if _, ok := dm.values[k]; !ok {
+			dm.AddValueAt(k, tsdata.NA, t)
+		}
+	}
+}
+
+// formatWind formats a wind speed or direction with one decimal place.
+func formatWind(v float64) string {
+	return strconv.FormatFloat(v, 'f', 1, 64)
+}
@@ -0,0 +1,101 @@
+package parse
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ctberthiaume/cruisemic/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTARAWind(t *testing.T) {
+	assert := assert.New(t)
+	input := `$WIMWV,45.0,R,10.0,N,A*00
+$GPRMC,160331,A,4743.7690,N,00322.4408,W,0.0,182.6,071225,0.2,W,D*13
+$HEHDT,90.0,T*2F
+$WIMWV,45.0,R,10.0,N,A*00
+$GPRMC,160332,A,4743.7694,N,00322.4405,W,10.0,0.0,071225,0.2,W,D*19
+$WIMWV,45.0,R,18.52,K,A*00
+$WIMWV,45.0,T,12.0,N,A*00
+$GPRMC,160333,A,4743.7694,N,00322.4405,W,10.0,0.0,071225,0.2,W,D*19
+$WIMWV,45.0,R,10.0,N,V*00
+$GPRMC,160334,A,4743.7694,N,00322.4405,W,10.0,0.0,071225,0.2,W,D*19
+`
+	p := NewTARAParser("test", 0, time.Now).(*TARAParser)
+	p.SetWind(true)
+	assert.Contains(p.Header(), "par\trelative_wind_speed\trelative_wind_direction\ttrue_wind_speed\ttrue_wind_direction")
+	store, _ := storage.NewMemStorage()
+	err := ParseLines(p, strings.NewReader(input), store, true, false)
+	assert.Nil(err)
+	na := "NA\tNA\tNA\tNA"
+	assert.Equal(map[string][]string{
+		"geo": {
+			// No heading or velocity yet
+			"2025-12-07T16:03:31Z\t47.7295\t-3.3740\t" + na + "\t10.0\t45.0\tNA\tNA\n",
+			// Stationary, heading east
+			"2025-12-07T16:03:32Z\t47.7295\t-3.3740\t" + na + "\t10.0\t45.0\t10.0\t135.0\n",
+			// Theoretical wind doesn't erase relative wind
+			"2025-12-07T16:03:33Z\t47.7295\t-3.3740\t" + na + "\t10.0\t45.0\t12.0\t135.0\n",
+			"2025-12-07T16:03:34Z\t47.7295\t-3.3740\t" + na + "\t" + na + "\n",
+		},
+	}, store.Feeds)
+}
+
+func TestTARAWindAge(t *testing.T) {
+	assert := assert.New(t)
+	input := `$GPRMC,160331,A,4743.7694,N,00322.4405,W,10.0,0.0,071225,0.2,W,D*19
+$HEHDT,90.0,T*2F
+$WIMWV,45.0,R,10.0,N,A*00
+$GPRMC,160345,A,4743.7694,N,00322.4405,W,10.0,0.0,071225,0.2,W,D*19
+$WIMWV,45.0,R,10.0,N,A*00
+$GPRMC,160346,A,4743.7694,N,00322.4405,W,10.0,0.0,071225,0.2,W,D*19
+`
+	na := "NA\tNA\tNA\tNA"
+
+	// Heading is too old after the default limit
+	p := NewTARAParser("test", 0, time.Now).(*TARAParser)
+	p.SetWind(true)
+	store, _ := storage.NewMemStorage()
+	err := ParseLines(p, strings.NewReader(input), store, true, false)
+	assert.Nil(err)
+	assert.Equal(map[string][]string{
+		"geo": {
+			"2025-12-07T16:03:31Z\t47.7295\t-3.3740\t" + na + "\t" + na + "\n",
+			"2025-12-07T16:03:45Z\t47.7295\t-3.3740\t" + na + "\t10.0\t45.0\t18.5\t157.5\n",
+			"2025-12-07T16:03:46Z\t47.7295\t-3.3740\t" + na + "\t10.0\t45.0\tNA\tNA\n",
+		},
+	}, store.Feeds)
+
+	// max_age sets the limit
+	p = NewTARAParser("test", 0, time.Now).(*TARAParser)
+	p.SetWind(true)
+	assert.Nil(p.Configure(Options{"max_age": "30s"}))
+	store, _ = storage.NewMemStorage()
+	err = ParseLines(p, strings.NewReader(input), store, true, false)
+	assert.Nil(err)
+	assert.Equal("2025-12-07T16:03:46Z\t47.7295\t-3.3740\t"+na+"\t10.0\t45.0\t18.5\t157.5\n", store.Feeds["geo"][2])
+}
+
+func TestSeaflowWind(t *testing.T) {
+	assert := assert.New(t)
+	input := `$HEHDT,90.0,T*2F
+$GPVTG,0.0,T,,M,0.0,N,0.0,K,A*00
+$WIMWV,45.0,R,10.0,N,A*00
+$SEAFLOW::$GPZDA,213309.00,12,01,2023,00,00*6D::$GPGGA,213309.00,4738.983141,N,12218.805824,W,2,17,0.7,15.773,M,-22.2,M,7.0,0402*44:: 12.3719,  3.64868,  31.2816::157.580
+$SEAFLOW::$GPZDA,213310.00,12,01,2023,00,00*65::$GPGGA,213310.00,4738.983143,N,12218.805821,W,2,17,0.7,15.774,M,-22.2,M,8.0,0402*43:: 12.3719,  3.64868,  31.2816::157.580
+`
+	p := NewTN427Parser("test", 0, time.Now).(*TN427Parser)
+	p.SetWind(true)
+	assert.Contains(p.Header(), "par\trelative_wind_speed\trelative_wind_direction\ttrue_wind_speed\ttrue_wind_direction")
+	store, _ := storage.NewMemStorage()
+	err := ParseLines(p, strings.NewReader(input), store, true, false)
+	assert.Nil(err)
+	assert.Equal(map[string][]string{
+		"geo": {
+			"2023-01-12T21:33:09Z\t47.6497\t-122.3134\t12.3719\t3.64868\t31.2816\t157.580\t10.0\t45.0\t10.0\t135.0\n",
+			// No MWV sentence since the last record
+			"2023-01-12T21:33:10Z\t47.6497\t-122.3134\t12.3719\t3.64868\t31.2816\t157.580\tNA\tNA\tNA\tNA\n",
+		},
+	}, store.Feeds)
+}
@@ -7,14 +7,22 @@ import (
 	"io"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
 // RawUDPReader reads raw UDP payloads wrapped with RAWUDP headers.
 type RawUDPReader struct {
-	scanner *bufio.Scanner
-	buffer  bytes.Buffer
-	eof     bool
+	// RequireTime makes a RAWUDP header time that can't be parsed an error.
+	// Otherwise the payload's lines get a zero LineTime.
+	RequireTime bool
+	scanner     *bufio.Scanner
+	buffer      bytes.Buffer
+	eof         bool
+	scanTime    time.Time // header time of the latest scanned payload
+	mu          sync.Mutex
+	lineTimes   []time.Time // header times of lines read but not yet claimed by LineTime
+	last        time.Time   // header time of the latest payload read
 }
 
 // TimeSource is an interface that provides the current time.
@@ -32,11 +40,26 @@ func (r RealTime) Now() time.Time {
 
 // NewRawUDPReader returns a pointer to a RawUDPReader that reads from r.
 func NewRawUDPReader(r io.Reader) *RawUDPReader {
-	scanner := bufio.NewScanner(r)
-	scanner.Split(scanRawUDP)
-	return &RawUDPReader{
-		scanner: scanner,
+	rr := &RawUDPReader{}
+	rr.scanner = bufio.NewScanner(r)
+	rr.scanner.Split(rr.scan)
+	return rr
+}
+
+// LineTime returns the RAWUDP header time of the payload that held the end of
+// the next line read from r, i.e. when it was received. Lines are counted in
+// the order they were read, so call LineTime once per line read. A final line
+// without a newline gets the time of the last payload. It's safe to call
+// LineTime while another goroutine reads from r.
+func (r *RawUDPReader) LineTime() time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.lineTimes) == 0 {
+		return r.last
 	}
+	t := r.lineTimes[0]
+	r.lineTimes = r.lineTimes[1:]
+	return t
 }
 
 // Read reads data into p from the raw UDP payloads.
@@ -61,6 +84,12 @@ func (r *RawUDPReader) Read(p []byte) (n int, err error) {
 	for r.scanner.Scan() {
 		b := r.scanner.Bytes() // a complete payload
 		r.buffer.Write(b)
+		r.mu.Lock()
+		for i := bytes.Count(b, []byte{'\n'}); i > 0; i-- {
+			r.lineTimes = append(r.lineTimes, r.scanTime)
+		}
+		r.last = r.scanTime
+		r.mu.Unlock()
 		// Satisfy the read if possible
 		if r.buffer.Len() >= len(p) {
 			return r.buffer.Read(p)
@@ -79,6 +108,27 @@ func (r *RawUDPReader) Read(p []byte) (n int, err error) {
 	return
 }
 
+// scan is a split function for a Scanner that returns each raw UDP payload
+// like scanRawUDP, and saves the time in the payload's RAWUDP header, or a
+// zero time if it can't be parsed and RequireTime is false.
+func (r *RawUDPReader) scan(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	advance, token, err = scanRawUDP(data, atEOF)
+	if err != nil || token == nil {
+		return advance, token, err
+	}
+	// scanRawUDP has checked that the header is complete with three fields
+	header := string(data[:bytes.IndexByte(data, '\n')])
+	t, err := time.Parse(time.RFC3339, strings.Split(header, ",")[1])
+	if err != nil {
+		if r.RequireTime {
+			return 0, nil, fmt.Errorf("bad RAWUDP time")
+		}
+		t = time.Time{}
+	}
+	r.scanTime = t
+	return advance, token, nil
+}
+
 // scanRawUDP is a split function for a Scanner that returns each raw UDP payload
 // wrapped with a RAWUDP header.
 func scanRawUDP(data []byte, atEOF bool) (advance int, token []byte, err error) {
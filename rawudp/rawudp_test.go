@@ -143,6 +143,45 @@ func createRawUDPReaderTest(t *testing.T, tt testRawUDPReaderData) func(*testing
 	}
 }
 
+func TestRawUDPReaderLineTime(t *testing.T) {
+	assert := assert.New(t)
+	input := `=== RAWUDP,2024-06-01T12:00:00Z,12
+hello world
+
+=== RAWUDP,2024-06-01T12:00:01Z,7
+good
+by
+=== RAWUDP,2024-06-01T12:00:02Z,2
+e
+
+=== RAWUDP,2024-06-01T12:00:03Z,5
+again
+`
+	rudpr := NewRawUDPReader(strings.NewReader(input))
+	b, err := io.ReadAll(rudpr)
+	assert.Nil(err)
+	assert.Equal("hello world\ngood\nbye\nagain", string(b))
+	t0 := time.Date(2024, time.June, 1, 12, 0, 0, 0, time.UTC)
+	// A line split across payloads gets the time of the payload it ends in,
+	// and a final line without a newline gets the time of the last payload
+	for _, sec := range []int{0, 1, 2, 3} {
+		assert.Equal(t0.Add(time.Duration(sec)*time.Second), rudpr.LineTime())
+	}
+
+	// A bad header time is only an error if times are required
+	bad := "=== RAWUDP,2024-06-01 12:00:00,12\nhello world\n\n"
+	rudpr = NewRawUDPReader(strings.NewReader(bad))
+	b, err = io.ReadAll(rudpr)
+	assert.Nil(err)
+	assert.Equal("hello world\n", string(b))
+	assert.True(rudpr.LineTime().IsZero())
+
+	rudpr = NewRawUDPReader(strings.NewReader(bad))
+	rudpr.RequireTime = true
+	_, err = io.ReadAll(rudpr)
+	assert.NotNil(err)
+}
+
 func TestRawUDPWrap(t *testing.T) {
 	testData := []testRawUDPWrapData{
 		{